@@ -2,11 +2,21 @@ package main
 
 import (
 	"Code_executor/internal/config"
+	"Code_executor/internal/domain"
 	localhttp "Code_executor/internal/http"
+	"Code_executor/internal/pgtx"
+	"Code_executor/internal/queue"
+	pgqueue "Code_executor/internal/queue/postgres"
 	redisqueue "Code_executor/internal/queue/redis"
+	"Code_executor/internal/repository"
 	postgresrepo "Code_executor/internal/repository/postgres"
+	"Code_executor/internal/repository/schema"
+	sqliterepo "Code_executor/internal/repository/sqlite"
+	"Code_executor/internal/scheduler"
 	"Code_executor/internal/service"
 	"context"
+	"flag"
+	"fmt"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/google/uuid"
@@ -14,50 +24,194 @@ import (
 	"github.com/redis/go-redis/v9"
 	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
 func main() {
+	adminToken := flag.String("admin-token", "", "bearer token for the /admin endpoints (empty disables them)")
+	store := flag.String("store", "postgres", "where executions and schedules are kept, postgres or sqlite (single node only)")
+	sqlitePath := flag.String("sqlite-path", "code_executor.db", "database file of the sqlite store; must match the workers'")
+	sqliteBusyTimeout := flag.Duration("sqlite-busy-timeout", 5*time.Second, "how long the sqlite store waits for a write lock held by another process")
+	queueBackend := flag.String("queue-backend", "list", "queue backend, list or stream (redis) or postgres; must match the workers'")
+	maxPriority := flag.Int("max-priority", domain.MaxPriority, "highest priority users may request")
+	userMaxPriority := flag.String("user-max-priority", "", "per-user overrides of --max-priority, e.g. grader=2,alice=9; advisory only, as users are the unauthenticated user_name of requests")
+	streamMaxLen := flag.Int64("stream-max-len", 0, "trim the stream backend's history to about this many jobs (0 keeps all)")
+	cronInterval := flag.Duration("cron-interval", 5*time.Second, "how often schedules that are due get run")
+	promoteInterval := flag.Duration("promote-interval", time.Second, "how often scheduled executions that are due get queued")
+	workerTimeout := flag.Duration("worker-timeout", 30*time.Second, "report a language unavailable when no worker taking it sent a heartbeat for this long")
+	flag.Parse()
+
 	ctx := context.Background()
 
+	priorityCaps, err := parsePriorityCaps(*maxPriority, *userMaxPriority)
+	if err != nil {
+		log.Fatalf("parse priority caps: %v", err)
+	}
+
 	cfg, err := config.Load()
 	if err != nil {
 		log.Fatalf("load config: %v", err)
 	}
 
-	pool, err := pgxpool.New(ctx, cfg.Database.URL)
-	if err != nil {
-		log.Fatalf("connect postgres: %v", err)
+	var pool *pgxpool.Pool
+	if *store == "postgres" || *queueBackend == "postgres" {
+		pool, err = pgxpool.New(ctx, cfg.Database.URL)
+		if err != nil {
+			log.Fatalf("connect postgres: %v", err)
+		}
+		defer pool.Close()
+
+		if err := pool.Ping(ctx); err != nil {
+			log.Fatalf("ping postgres: %v", err)
+		}
 	}
-	defer pool.Close()
 
-	if err := pool.Ping(ctx); err != nil {
-		log.Fatalf("ping postgres: %v", err)
+	var (
+		migrator     schema.Migrator
+		repo         repository.ExecutionRepository
+		scheduleRepo repository.ScheduleRepository
+	)
+	switch *store {
+	case "postgres":
+		migrator, err = postgresrepo.NewMigrator(pool)
+		if err == nil {
+			repo, err = postgresrepo.NewExecutionRepository(pool)
+		}
+		if err == nil {
+			scheduleRepo, err = postgresrepo.NewScheduleRepository(pool)
+		}
+		if err != nil {
+			log.Fatalf("init postgres store: %v", err)
+		}
+	case "sqlite":
+		db, err := sqliterepo.Open(ctx, *sqlitePath, *sqliteBusyTimeout)
+		if err != nil {
+			log.Fatalf("open sqlite store: %v", err)
+		}
+		defer db.Close()
+
+		migrator, err = sqliterepo.NewMigrator(db)
+		if err == nil {
+			repo, err = sqliterepo.NewExecutionRepository(db)
+		}
+		if err == nil {
+			scheduleRepo, err = sqliterepo.NewScheduleRepository(db)
+		}
+		if err != nil {
+			log.Fatalf("init sqlite store: %v", err)
+		}
+	default:
+		log.Fatalf("unknown store %q", *store)
 	}
 
-	repo, err := postgresrepo.NewExecutionRepository(pool)
-	if err != nil {
-		log.Fatalf("init postgres repo: %v", err)
+	migrators := []schema.Migrator{migrator}
+	if *queueBackend == "postgres" && *store != "postgres" {
+		// The tables of the postgres queue come with the postgres store's
+		// migrations.
+		queueMigrator, err := postgresrepo.NewMigrator(pool)
+		if err != nil {
+			log.Fatalf("init postgres queue: %v", err)
+		}
+		migrators = append(migrators, queueMigrator)
 	}
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     cfg.Redis.Addr,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-	})
-	defer redisClient.Close()
-	if err := redisClient.Ping(ctx).Err(); err != nil {
-		log.Fatalf("connect redis: %v", err)
+	if flag.Arg(0) == "migrate" {
+		if err := runMigrate(ctx, migrators, flag.Args()[1:]); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
 	}
 
-	producer, err := redisqueue.NewProducer(redisClient, cfg.QueueKey)
-	if err != nil {
-		log.Fatalf("init redis producer: %v", err)
+	if err := requireMigrations(ctx, migrators); err != nil {
+		log.Fatalf("check migrations: %v", err)
+	}
+
+	var (
+		producer    queue.Producer
+		deadLetters queue.DeadLetters
+		cancels     queue.CancelNotifier
+		workers     queue.WorkerRegistry
+		delayed     queue.DelayedQueue
+		transactor  service.Transactor
+	)
+	switch *queueBackend {
+	case "list", "stream":
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		defer redisClient.Close()
+		if err := redisClient.Ping(ctx).Err(); err != nil {
+			log.Fatalf("connect redis: %v", err)
+		}
+
+		if *queueBackend == "list" {
+			producer, err = redisqueue.NewProducer(redisClient, cfg.QueueKey)
+			if err == nil {
+				deadLetters, err = redisqueue.NewDeadLetters(redisClient, cfg.QueueKey)
+			}
+		} else {
+			producer, err = redisqueue.NewStreamProducer(redisClient, cfg.QueueKey, *streamMaxLen)
+			if err == nil {
+				deadLetters, err = redisqueue.NewStreamDeadLetters(redisClient, cfg.QueueKey)
+			}
+		}
+		if err != nil {
+			log.Fatalf("init redis producer: %v", err)
+		}
+
+		cancels, err = redisqueue.NewCancelNotifier(redisClient, "")
+		if err != nil {
+			log.Fatalf("init redis cancel notifier: %v", err)
+		}
+
+		workers, err = redisqueue.NewWorkerRegistry(redisClient, cfg.QueueKey)
+		if err != nil {
+			log.Fatalf("init redis worker registry: %v", err)
+		}
+
+		delayed, err = redisqueue.NewDelayedQueue(redisClient, cfg.QueueKey)
+		if err != nil {
+			log.Fatalf("init redis delayed queue: %v", err)
+		}
+	case "postgres":
+		producer, err = pgqueue.NewProducer(pool)
+		if err == nil {
+			deadLetters, err = pgqueue.NewDeadLetters(pool)
+		}
+		if err == nil {
+			cancels, err = pgqueue.NewCancelNotifier(pool)
+		}
+		if err == nil {
+			workers, err = pgqueue.NewWorkerRegistry(pool)
+		}
+		if err == nil {
+			delayed, err = pgqueue.NewDelayedQueue(pool)
+		}
+		if err == nil && *store == "postgres" {
+			// Executions and their jobs are committed together.
+			transactor, err = pgtx.NewTransactor(pool)
+		}
+		if err != nil {
+			log.Fatalf("init postgres queue: %v", err)
+		}
+	default:
+		log.Fatalf("unknown queue backend %q", *queueBackend)
 	}
 
 	serviceDeps := service.ExecutionServiceDeps{
-		Repo:     repo,
-		Producer: producer,
+		Repo:          repo,
+		Producer:      producer,
+		Cancels:       cancels,
+		DeadLetters:   deadLetters,
+		Delayed:       delayed,
+		Transactor:    transactor,
+		PriorityCaps:  priorityCaps,
+		Workers:       workers,
+		WorkerTimeout: *workerTimeout,
 		IDGenerator: func() (string, error) {
 			return uuid.NewString(), nil
 		},
@@ -69,15 +223,67 @@ func main() {
 		log.Fatalf("init execution service: %v", err)
 	}
 
+	// Every api instance promotes; each due execution is claimed by one.
+	promoter, err := scheduler.New(scheduler.Deps{
+		Repo:     repo,
+		Delayed:  delayed,
+		Producer: producer,
+		Interval: *promoteInterval,
+	})
+	if err != nil {
+		log.Fatalf("init promoter: %v", err)
+	}
+	go promoter.Run(ctx)
+
+	scheduleService, err := service.NewScheduleService(service.ScheduleServiceDeps{
+		Schedules:  scheduleRepo,
+		Executions: repo,
+		IDGenerator: func() (string, error) {
+			return uuid.NewString(), nil
+		},
+		Now: time.Now,
+	})
+	if err != nil {
+		log.Fatalf("init schedule service: %v", err)
+	}
+
+	cron, err := scheduler.NewCron(scheduler.CronDeps{
+		Schedules:  scheduleRepo,
+		Executions: execService,
+		Transactor: transactor,
+		Interval:   *cronInterval,
+	})
+	if err != nil {
+		log.Fatalf("init cron: %v", err)
+	}
+	go cron.Run(ctx)
+
+	scheduleHandler, err := localhttp.NewScheduleHandler(scheduleService)
+	if err != nil {
+		log.Fatalf("init schedule handler: %v", err)
+	}
+
 	handler, err := localhttp.NewExecutionHandler(execService)
 	if err != nil {
 		log.Fatalf("init execution handler: %v", err)
 	}
 
+	var adminHandler *localhttp.AdminHandler
+	if *adminToken != "" {
+		adminHandler, err = localhttp.NewAdminHandler(execService, *adminToken)
+		if err != nil {
+			log.Fatalf("init admin handler: %v", err)
+		}
+	}
+
 	r := chi.NewRouter()
 	r.Use(middleware.RequestID, middleware.Recoverer, middleware.Logger)
 	r.Route("/api/v1", func(r chi.Router) {
 		handler.RegisterRoutes(r)
+		scheduleHandler.RegisterRoutes(r)
+		if adminHandler != nil {
+			adminHandler.RegisterRoutes(r)
+		}
 	})
 
 	log.Printf("Server started on %s", cfg.APIAddr)
@@ -85,3 +291,29 @@ func main() {
 		log.Fatalf("Server error: %v", err)
 	}
 }
+
+func parsePriorityCaps(defaultCap int, value string) (*service.PriorityCaps, error) {
+	caps := &service.PriorityCaps{
+		Default: defaultCap,
+		Users:   make(map[string]int),
+	}
+
+	if value == "" {
+		return caps, nil
+	}
+
+	for _, entry := range strings.Split(value, ",") {
+		user, limit, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid priority cap %q, want user=priority", entry)
+		}
+
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid priority cap %q: %w", entry, err)
+		}
+		caps.Users[user] = n
+	}
+
+	return caps, nil
+}
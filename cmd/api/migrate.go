@@ -0,0 +1,92 @@
+package main
+
+import (
+	"Code_executor/internal/repository/schema"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+const migrateUsage = "usage: api [flags] migrate up|down|status"
+
+// runMigrate runs the migrate subcommand against every database: up applies
+// every pending migration, down reverts the latest one and status lists them
+// all.
+func runMigrate(ctx context.Context, migrators []schema.Migrator, args []string) error {
+	if len(args) != 1 {
+		return errors.New(migrateUsage)
+	}
+
+	for _, migrator := range migrators {
+		if err := migrate(ctx, migrator, args[0]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func migrate(ctx context.Context, migrator schema.Migrator, command string) error {
+	switch command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		for _, migration := range applied {
+			fmt.Printf("applied %s\n", migration)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		reverted, err := migrator.Down(ctx)
+		if err != nil {
+			return err
+		}
+		if reverted == nil {
+			fmt.Println("no applied migrations")
+			return nil
+		}
+		fmt.Printf("reverted %s\n", reverted)
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return w.Flush()
+	default:
+		return fmt.Errorf("unknown migrate command %q; %s", command, migrateUsage)
+	}
+
+	return nil
+}
+
+// requireMigrations fails when the database schema is behind this binary, so
+// a deploy that skipped `api migrate up` doesn't serve requests against it.
+func requireMigrations(ctx context.Context, migrators []schema.Migrator) error {
+	for _, migrator := range migrators {
+		pending, err := schema.Pending(ctx, migrator)
+		if err != nil {
+			return err
+		}
+
+		if len(pending) > 0 {
+			return fmt.Errorf("%d pending migrations, starting with %s; run `api migrate up`", len(pending), pending[0])
+		}
+	}
+
+	return nil
+}
@@ -2,17 +2,58 @@ package main
 
 import (
 	"Code_executor/internal/config"
+	"Code_executor/internal/domain"
+	"Code_executor/internal/queue"
+	pgqueue "Code_executor/internal/queue/postgres"
 	redisqueue "Code_executor/internal/queue/redis"
+	"Code_executor/internal/repository"
 	postgresrepo "Code_executor/internal/repository/postgres"
+	"Code_executor/internal/repository/schema"
+	sqliterepo "Code_executor/internal/repository/sqlite"
+	sandboxrunner "Code_executor/internal/runner/sandbox"
+	"Code_executor/internal/worker"
 	"context"
+	"flag"
 	"fmt"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/redis/go-redis/v9"
 	"log"
+	"os"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 )
 
 func main() {
+	// Re-executed sandbox children stop here; see sandboxrunner.
+	sandboxrunner.MaybeInit()
+
+	var runnerOpts runnerOptions
+	runnerOpts.registerFlags(flag.CommandLine)
+	concurrency := flag.Int("concurrency", runtime.NumCPU(), "number of executions run at the same time")
+	drainTimeout := flag.Duration("drain-timeout", 30*time.Second, "on SIGTERM or SIGINT, how long to wait for running executions before killing them")
+	retryBaseDelay := flag.Duration("retry-base-delay", 2*time.Second, "delay before retrying a job that failed for infrastructure reasons, doubled per attempt")
+	retryMaxDelay := flag.Duration("retry-max-delay", time.Minute, "upper bound of the retry delay")
+	store := flag.String("store", "postgres", "where executions are kept, postgres or sqlite; must match the api's")
+	sqlitePath := flag.String("sqlite-path", "code_executor.db", "database file of the sqlite store; must match the api's")
+	sqliteBusyTimeout := flag.Duration("sqlite-busy-timeout", 5*time.Second, "how long the sqlite store waits for a write lock held by another process")
+	queueBackend := flag.String("queue-backend", "list", "queue backend, list or stream (redis) or postgres; must match the api's")
+	streamGroup := flag.String("stream-group", "", "consumer group of the stream backend (default: shared by all workers)")
+	priorityAging := flag.Duration("priority-aging", queue.DefaultPriorityAging, "raise the priority of waiting jobs by one per this period so they are not starved (0 disables)")
+	visibilityTimeout := flag.Duration("visibility-timeout", time.Minute, "redeliver jobs of a worker that stopped extending them for this long")
+	languagesFlag := flag.String("languages", "", "comma-separated languages to take jobs of, e.g. python,node (default: all)")
+	heartbeatInterval := flag.Duration("heartbeat-interval", 10*time.Second, "how often the worker reports to the api that it is alive")
+	flag.Parse()
+
+	languages, err := parseLanguages(*languagesFlag)
+	if err != nil {
+		log.Fatalf("invalid --languages: %v", err)
+	}
+
 	fmt.Println("Starting worker")
 	ctx := context.Background()
 
@@ -21,68 +62,189 @@ func main() {
 		log.Fatalf("load config: %v", err)
 	}
 
-	pool, err := pgxpool.New(ctx, cfg.Database.URL)
-	if err != nil {
-		log.Fatalf("connect postgres: %v", err)
-	}
-	defer pool.Close()
+	var pool *pgxpool.Pool
+	if *store == "postgres" || *queueBackend == "postgres" {
+		pool, err = pgxpool.New(ctx, cfg.Database.URL)
+		if err != nil {
+			log.Fatalf("connect postgres: %v", err)
+		}
+		defer pool.Close()
 
-	if err := pool.Ping(ctx); err != nil {
-		log.Fatalf("ping postgres: %v", err)
+		if err := pool.Ping(ctx); err != nil {
+			log.Fatalf("ping postgres: %v", err)
+		}
 	}
 
-	repo, err := postgresrepo.NewExecutionRepository(pool)
-	if err != nil {
-		log.Fatalf("init postgres repo: %v", err)
+	var (
+		migrator schema.Migrator
+		repo     repository.ExecutionRepository
+	)
+	switch *store {
+	case "postgres":
+		migrator, err = postgresrepo.NewMigrator(pool)
+		if err == nil {
+			repo, err = postgresrepo.NewExecutionRepository(pool)
+		}
+		if err != nil {
+			log.Fatalf("init postgres store: %v", err)
+		}
+	case "sqlite":
+		db, err := sqliterepo.Open(ctx, *sqlitePath, *sqliteBusyTimeout)
+		if err != nil {
+			log.Fatalf("open sqlite store: %v", err)
+		}
+		defer db.Close()
+
+		migrator, err = sqliterepo.NewMigrator(db)
+		if err == nil {
+			repo, err = sqliterepo.NewExecutionRepository(db)
+		}
+		if err != nil {
+			log.Fatalf("init sqlite store: %v", err)
+		}
+	default:
+		log.Fatalf("unknown store %q", *store)
 	}
 
-	redisClient := redis.NewClient(&redis.Options{
-		Addr:     cfg.Redis.Addr,
-		Password: cfg.Redis.Password,
-		DB:       cfg.Redis.DB,
-	})
-	defer redisClient.Close()
-	if err := redisClient.Ping(context.Background()).Err(); err != nil {
-		log.Fatalf("connect redis: %v", err)
+	migrators := []schema.Migrator{migrator}
+	if *queueBackend == "postgres" && *store != "postgres" {
+		// The tables of the postgres queue come with the postgres store's
+		// migrations.
+		queueMigrator, err := postgresrepo.NewMigrator(pool)
+		if err != nil {
+			log.Fatalf("init postgres queue: %v", err)
+		}
+		migrators = append(migrators, queueMigrator)
 	}
 
-	queue, err := redisqueue.NewConsumer(redisClient, cfg.QueueKey, cfg.PopTimeout)
-	if err != nil {
-		log.Fatalf("Redis cannot create new consumer: %v", err)
+	for _, migrator := range migrators {
+		if pending, err := schema.Pending(ctx, migrator); err != nil {
+			log.Fatalf("check migrations: %v", err)
+		} else if len(pending) > 0 {
+			log.Fatalf("%d pending migrations; run `api migrate up`", len(pending))
+		}
 	}
 
-	jobs, err := queue.Consume(ctx)
-	if err != nil {
-		panic(err)
+	consumerOpts := queue.ConsumerOptions{
+		PopTimeout:        cfg.PopTimeout,
+		VisibilityTimeout: *visibilityTimeout,
+		PriorityAging:     *priorityAging,
+		Languages:         languages,
 	}
 
-	for job := range jobs {
-		exec, err := repo.GetExecutionByID(ctx, job.ExecutionID)
-		if err != nil {
-			panic(err)
+	var (
+		consumer       queue.Consumer
+		cancelListener queue.CancelListener
+		registry       queue.WorkerRegistry
+	)
+	switch *queueBackend {
+	case "list", "stream":
+		redisClient := redis.NewClient(&redis.Options{
+			Addr:     cfg.Redis.Addr,
+			Password: cfg.Redis.Password,
+			DB:       cfg.Redis.DB,
+		})
+		defer redisClient.Close()
+		if err := redisClient.Ping(context.Background()).Err(); err != nil {
+			log.Fatalf("connect redis: %v", err)
 		}
-		err = exec.MarkRunning(time.Now())
-		if err != nil {
-			panic(err)
+
+		if *queueBackend == "list" {
+			consumer, err = redisqueue.NewConsumer(redisClient, cfg.QueueKey, consumerOpts)
+		} else {
+			consumer, err = redisqueue.NewStreamConsumer(redisClient, cfg.QueueKey, *streamGroup, consumerOpts)
 		}
-		err = repo.UpdateExecution(ctx, exec)
 		if err != nil {
-			panic(err)
+			log.Fatalf("Redis cannot create new consumer: %v", err)
 		}
 
-		fmt.Printf("⚙️ Processing job %s\n", job.ExecutionID)
-		time.Sleep(2 * time.Second) // simulate "work"
+		cancelListener, err = redisqueue.NewCancelListener(redisClient, "")
+		if err != nil {
+			log.Fatalf("Redis cannot create cancel listener: %v", err)
+		}
 
-		stdout := fmt.Sprintf("Fake output for %s code", exec.Language)
-		err = exec.MarkCompleted(stdout, "", 0, time.Now())
+		registry, err = redisqueue.NewWorkerRegistry(redisClient, cfg.QueueKey)
 		if err != nil {
-			panic(err)
+			log.Fatalf("Redis cannot create worker registry: %v", err)
+		}
+	case "postgres":
+		consumer, err = pgqueue.NewConsumer(pool, consumerOpts)
+		if err == nil {
+			cancelListener, err = pgqueue.NewCancelListener(pool)
+		}
+		if err == nil {
+			registry, err = pgqueue.NewWorkerRegistry(pool)
 		}
-		err = repo.UpdateExecution(ctx, exec)
 		if err != nil {
-			panic(err)
+			log.Fatalf("init postgres queue: %v", err)
 		}
+	default:
+		log.Fatalf("unknown queue backend %q", *queueBackend)
+	}
+
+	run, stopRunner, err := newRunner(runnerOpts)
+	if err != nil {
+		log.Fatalf("init runner: %v", err)
+	}
+	defer stopRunner()
+
+	w, err := worker.New(worker.Deps{
+		Repo:         repo,
+		Consumer:     consumer,
+		Runner:       run,
+		Cancels:      cancelListener,
+		Concurrency:  *concurrency,
+		DrainTimeout: *drainTimeout,
+
+		RetryBaseDelay: *retryBaseDelay,
+		RetryMaxDelay:  *retryMaxDelay,
+
+		Registry:          registry,
+		ID:                workerID(),
+		Languages:         languages,
+		HeartbeatInterval: *heartbeatInterval,
+	})
+	if err != nil {
+		log.Fatalf("init worker: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGTERM, os.Interrupt)
+	defer stop()
 
-		fmt.Printf("✅ Completed job %s\n", job.ExecutionID)
+	if err := w.Run(ctx); err != nil {
+		log.Printf("worker: %v", err)
 	}
+
+	fmt.Println("Worker stopped")
+}
+
+// parseLanguages parses the --languages flag; an empty value takes every
+// language.
+func parseLanguages(value string) ([]string, error) {
+	var languages []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		if _, ok := domain.GetLanguage(name); !ok {
+			return nil, fmt.Errorf("language %q is not supported", name)
+		}
+		if !slices.Contains(languages, name) {
+			languages = append(languages, name)
+		}
+	}
+
+	return languages, nil
+}
+
+// workerID identifies the worker in the registry; it is unique per process.
+func workerID() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+
+	return host + "-" + strconv.Itoa(os.Getpid())
 }
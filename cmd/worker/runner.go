@@ -0,0 +1,167 @@
+package main
+
+import (
+	"Code_executor/internal/cgroup"
+	"Code_executor/internal/domain"
+	"Code_executor/internal/runner"
+	dockerrunner "Code_executor/internal/runner/docker"
+	localrunner "Code_executor/internal/runner/local"
+	sandboxrunner "Code_executor/internal/runner/sandbox"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type runnerOptions struct {
+	kind         string
+	dockerBinary string
+	workspaceDir string
+	cgroupParent string
+	rootfsDir    string
+	unfiltered   bool
+
+	poolSizes         string
+	poolIdleTimeout   time.Duration
+	poolStatsInterval time.Duration
+}
+
+func (o *runnerOptions) registerFlags(fs *flag.FlagSet) {
+	fs.StringVar(&o.kind, "runner", "local", "execution backend: local, docker or sandbox")
+	fs.StringVar(&o.dockerBinary, "docker-binary", "docker", "docker-compatible CLI used by the docker runner")
+	fs.StringVar(&o.workspaceDir, "workspace-dir", "", "directory for per-execution workspaces (default: system temp dir)")
+	fs.StringVar(&o.cgroupParent, "cgroup-parent", "", "delegated cgroup v2 directory for per-execution limits of the local and sandbox runners (empty disables limits)")
+	fs.BoolVar(&o.unfiltered, "local-unfiltered", false, "let the local runner run languages with a seccomp profile without it (development only)")
+	fs.StringVar(&o.rootfsDir, "rootfs-dir", "", "directory with one read-only root filesystem per language for the sandbox runner")
+	fs.StringVar(&o.poolSizes, "pool-size", "", "warm containers per language for the docker runner, e.g. python=4,node=2 (empty disables the pool)")
+	fs.DurationVar(&o.poolIdleTimeout, "pool-idle-timeout", 10*time.Minute, "evict warm containers idle for longer than this")
+	fs.DurationVar(&o.poolStatsInterval, "pool-stats-interval", time.Minute, "how often warm pool statistics are logged (0 disables)")
+}
+
+// newRunner creates the configured runner. The returned stop function ends
+// background work such as the warm pool and waits for it to clean up; call it
+// once the runner is no longer used.
+func newRunner(opts runnerOptions) (runner.Runner, func(), error) {
+	var cgroups *cgroup.Manager
+	if opts.cgroupParent != "" {
+		m, err := cgroup.NewManager(opts.cgroupParent)
+		if err != nil {
+			return nil, nil, err
+		}
+		cgroups = m
+	}
+
+	noop := func() {}
+
+	switch opts.kind {
+	case "local":
+		if filtered := filteredLanguages(); len(filtered) > 0 {
+			if !opts.unfiltered {
+				return nil, nil, fmt.Errorf("local runner cannot enforce the seccomp profiles of %s; use -runner=sandbox or -runner=docker, or -local-unfiltered to run them unfiltered", strings.Join(filtered, ", "))
+			}
+			log.Printf("local runner runs %s without their seccomp profiles", strings.Join(filtered, ", "))
+		}
+		return localrunner.NewRunner(opts.workspaceDir, cgroups, opts.unfiltered), noop, nil
+	case "docker":
+		engine := dockerrunner.NewCLIEngine(opts.dockerBinary)
+		pool, stop, err := newPool(engine, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		run, err := dockerrunner.NewRunner(engine, opts.workspaceDir, pool)
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		return run, stop, nil
+	case "sandbox":
+		run, err := sandboxrunner.NewRunner(opts.workspaceDir, opts.rootfsDir, cgroups)
+		return run, noop, err
+	default:
+		return nil, nil, fmt.Errorf("unknown runner %q", opts.kind)
+	}
+}
+
+// filteredLanguages returns the languages with a syscall allowlist, which the
+// local runner cannot enforce. The worker refuses to start with them rather
+// than failing each of their executions.
+func filteredLanguages() []string {
+	var filtered []string
+	for _, name := range domain.LanguageNames() {
+		lang, _ := domain.GetLanguage(name)
+		if lang.SeccompProfile != "" || len(lang.SeccompSyscalls) > 0 {
+			filtered = append(filtered, name)
+		}
+	}
+
+	return filtered
+}
+
+func newPool(engine dockerrunner.Engine, opts runnerOptions) (*dockerrunner.Pool, func(), error) {
+	if opts.poolSizes == "" {
+		return nil, func() {}, nil
+	}
+
+	sizes, err := parsePoolSizes(opts.poolSizes)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pool, err := dockerrunner.NewPool(engine, opts.workspaceDir, sizes, opts.poolIdleTimeout)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+		pool.Run(ctx)
+	}()
+	if opts.poolStatsInterval > 0 {
+		go reportPoolStats(ctx, pool, opts.poolStatsInterval)
+	}
+
+	return pool, func() {
+		cancel()
+		<-done
+	}, nil
+}
+
+func parsePoolSizes(value string) (map[string]int, error) {
+	sizes := make(map[string]int)
+
+	for _, entry := range strings.Split(value, ",") {
+		name, size, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid pool size %q, want language=size", entry)
+		}
+
+		n, err := strconv.Atoi(size)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pool size %q: %w", entry, err)
+		}
+		sizes[name] = n
+	}
+
+	return sizes, nil
+}
+
+func reportPoolStats(ctx context.Context, pool *dockerrunner.Pool, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			stats := pool.Stats()
+			log.Printf("warm pool: idle=%v hits=%d misses=%d evictions=%d", stats.Idle, stats.Hits, stats.Misses, stats.Evictions)
+		}
+	}
+}
@@ -0,0 +1,11 @@
+package cgroup
+
+import "errors"
+
+// cpuPeriodMicros is the cpu.max period; quota is derived from CPUMillis.
+const cpuPeriodMicros = 100000
+
+var (
+	ErrUnsupported   = errors.New("cgroup v2 is not supported on this platform")
+	ErrInvalidCgroup = errors.New("invalid cgroup")
+)
@@ -0,0 +1,266 @@
+//go:build linux
+
+package cgroup
+
+import (
+	"Code_executor/internal/domain"
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	removeAttempts = 50
+	removeInterval = 20 * time.Millisecond
+)
+
+// Manager owns a delegated cgroup v2 directory and creates one leaf cgroup
+// per execution below it.
+type Manager struct {
+	parent string
+}
+
+func NewManager(parent string) (*Manager, error) {
+	if parent == "" {
+		return nil, fmt.Errorf("%w: parent path is empty", ErrInvalidCgroup)
+	}
+
+	if _, err := os.Stat(filepath.Join(filepath.Dir(parent), "cgroup.controllers")); err != nil {
+		return nil, fmt.Errorf("%w: %s is not on a cgroup v2 hierarchy", ErrUnsupported, parent)
+	}
+
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return nil, fmt.Errorf("create cgroup parent: %w", err)
+	}
+
+	// Controllers must be enabled on the parent for leaves to get the
+	// interface files. The parent itself must not contain processes.
+	if err := writeFile(parent, "cgroup.subtree_control", "+memory +pids +cpu"); err != nil {
+		return nil, fmt.Errorf("enable cgroup controllers: %w", err)
+	}
+	// The io controller only feeds usage statistics; not every host
+	// delegates it.
+	_ = writeFile(parent, "cgroup.subtree_control", "+io")
+
+	return &Manager{parent: parent}, nil
+}
+
+func (m *Manager) Create(name string, limits domain.ResourceLimits) (*Cgroup, error) {
+	if name == "" || strings.ContainsRune(name, '/') {
+		return nil, fmt.Errorf("%w: bad name %q", ErrInvalidCgroup, name)
+	}
+
+	path := filepath.Join(m.parent, name)
+	if err := os.Mkdir(path, 0o755); err != nil {
+		return nil, fmt.Errorf("create cgroup: %w", err)
+	}
+
+	cg := &Cgroup{path: path}
+	if err := cg.apply(limits); err != nil {
+		_ = cg.Close()
+		return nil, err
+	}
+
+	dir, err := os.Open(path)
+	if err != nil {
+		_ = cg.Close()
+		return nil, fmt.Errorf("open cgroup: %w", err)
+	}
+	cg.dir = dir
+
+	return cg, nil
+}
+
+type Cgroup struct {
+	path string
+	dir  *os.File
+}
+
+func (c *Cgroup) Path() string {
+	return c.path
+}
+
+// Attach makes cmd start directly inside the cgroup, so not even the first
+// instruction of the child runs unconstrained.
+func (c *Cgroup) Attach(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.UseCgroupFD = true
+	cmd.SysProcAttr.CgroupFD = int(c.dir.Fd())
+}
+
+// Violations reports which limits the kernel had to enforce during the run.
+func (c *Cgroup) Violations() ([]domain.ResourceViolation, error) {
+	var violations []domain.ResourceViolation
+
+	memEvents, err := readKeyedFile(c.path, "memory.events")
+	if err != nil {
+		return nil, err
+	}
+	if memEvents["oom_kill"] > 0 {
+		violations = append(violations, domain.ResourceViolationMemory)
+	}
+
+	pidEvents, err := readKeyedFile(c.path, "pids.events")
+	if err != nil {
+		return nil, err
+	}
+	if pidEvents["max"] > 0 {
+		violations = append(violations, domain.ResourceViolationPids)
+	}
+
+	return violations, nil
+}
+
+// Usage reads the accumulated CPU time, peak memory and I/O of the cgroup.
+// Peak memory needs memory.peak (Linux 5.19) and I/O the io controller; both
+// are left zero when unavailable.
+func (c *Cgroup) Usage() (domain.ResourceUsage, error) {
+	var usage domain.ResourceUsage
+
+	cpuStat, err := readKeyedFile(c.path, "cpu.stat")
+	if err != nil {
+		return usage, err
+	}
+	usage.UserCPUTimeMs = cpuStat["user_usec"] / 1000
+	usage.SystemCPUTimeMs = cpuStat["system_usec"] / 1000
+
+	if peak, err := os.ReadFile(filepath.Join(c.path, "memory.peak")); err == nil {
+		usage.PeakMemoryBytes, _ = strconv.ParseInt(strings.TrimSpace(string(peak)), 10, 64)
+	}
+
+	if ioStat, err := os.ReadFile(filepath.Join(c.path, "io.stat")); err == nil {
+		usage.IOReadBytes, usage.IOWriteBytes = parseIOStat(string(ioStat))
+	}
+
+	return usage, nil
+}
+
+// Kill terminates every process in the cgroup, including ones that escaped
+// the original process group.
+func (c *Cgroup) Kill() error {
+	return writeFile(c.path, "cgroup.kill", "1")
+}
+
+func (c *Cgroup) Close() error {
+	if c.dir != nil {
+		_ = c.dir.Close()
+		c.dir = nil
+	}
+
+	_ = c.Kill()
+
+	var err error
+	for i := 0; i < removeAttempts; i++ {
+		err = os.Remove(c.path)
+		if err == nil || errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		// Killed processes are reaped asynchronously; rmdir fails with
+		// EBUSY until the cgroup is empty.
+		time.Sleep(removeInterval)
+	}
+
+	return fmt.Errorf("remove cgroup %s: %w", c.path, err)
+}
+
+func (c *Cgroup) apply(limits domain.ResourceLimits) error {
+	if limits.MemoryBytes > 0 {
+		if err := writeFile(c.path, "memory.max", strconv.FormatInt(limits.MemoryBytes, 10)); err != nil {
+			return err
+		}
+		// Without this the limit is trivially bypassed by swapping.
+		if err := writeFile(c.path, "memory.swap.max", "0"); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if limits.Pids > 0 {
+		if err := writeFile(c.path, "pids.max", strconv.Itoa(limits.Pids)); err != nil {
+			return err
+		}
+	}
+
+	if limits.CPUMillis > 0 {
+		quota := limits.CPUMillis * cpuPeriodMicros / 1000
+		if err := writeFile(c.path, "cpu.max", fmt.Sprintf("%d %d", quota, cpuPeriodMicros)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeFile(dir, name, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+
+	return nil
+}
+
+// parseIOStat sums rbytes and wbytes over all devices of io.stat, whose lines
+// look like "8:0 rbytes=1 wbytes=2 rios=3 ...".
+func parseIOStat(content string) (readBytes, writeBytes int64) {
+	for _, line := range strings.Split(content, "\n") {
+		for _, field := range strings.Fields(line) {
+			key, value, ok := strings.Cut(field, "=")
+			if !ok {
+				continue
+			}
+
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+
+			switch key {
+			case "rbytes":
+				readBytes += n
+			case "wbytes":
+				writeBytes += n
+			}
+		}
+	}
+
+	return readBytes, writeBytes
+}
+
+// readKeyedFile parses flat-keyed cgroup files such as memory.events.
+func readKeyedFile(dir, name string) (map[string]int64, error) {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	defer f.Close()
+
+	values := make(map[string]int64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+
+		v, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		values[fields[0]] = v
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+
+	return values, nil
+}
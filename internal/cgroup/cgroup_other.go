@@ -0,0 +1,27 @@
+//go:build !linux
+
+package cgroup
+
+import (
+	"Code_executor/internal/domain"
+	"os/exec"
+)
+
+type Manager struct{}
+
+func NewManager(string) (*Manager, error) {
+	return nil, ErrUnsupported
+}
+
+func (m *Manager) Create(string, domain.ResourceLimits) (*Cgroup, error) {
+	return nil, ErrUnsupported
+}
+
+type Cgroup struct{}
+
+func (c *Cgroup) Path() string                                    { return "" }
+func (c *Cgroup) Attach(*exec.Cmd)                                {}
+func (c *Cgroup) Violations() ([]domain.ResourceViolation, error) { return nil, ErrUnsupported }
+func (c *Cgroup) Usage() (domain.ResourceUsage, error)            { return domain.ResourceUsage{}, ErrUnsupported }
+func (c *Cgroup) Kill() error                                     { return ErrUnsupported }
+func (c *Cgroup) Close() error                                    { return nil }
@@ -0,0 +1,153 @@
+package checker
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/runner"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrCheckerFailed = errors.New("checker failed")
+)
+
+// Checker decides whether actual is an acceptable output for a test case.
+type Checker interface {
+	Check(ctx context.Context, input, expected, actual string) (domain.Verdict, error)
+	Close() error
+}
+
+type compareFunc func(expected, actual string) bool
+
+type builtin struct {
+	compare compareFunc
+}
+
+func (b builtin) Check(_ context.Context, _, expected, actual string) (domain.Verdict, error) {
+	if b.compare(expected, actual) {
+		return domain.VerdictAccepted, nil
+	}
+
+	return domain.VerdictWrongAnswer, nil
+}
+
+func (b builtin) Close() error {
+	return nil
+}
+
+// New returns the checker selected by a judged execution. Custom checker
+// programs are prepared with run and must be closed.
+func New(ctx context.Context, run runner.Runner, exec *domain.Execution) (Checker, error) {
+	if exec.Checker.Kind == domain.CheckerCustom {
+		return NewProgram(ctx, run, exec)
+	}
+
+	return NewBuiltin(exec.Checker)
+}
+
+// NewBuiltin returns the comparator for every kind except CheckerCustom, see
+// NewProgram for that.
+func NewBuiltin(spec domain.Checker) (Checker, error) {
+	switch spec.Kind {
+	case domain.CheckerExact:
+		return builtin{compare: exact}, nil
+	case domain.CheckerWhitespace, "":
+		return builtin{compare: whitespace}, nil
+	case domain.CheckerFloat:
+		return builtin{compare: float(spec.AbsTolerance, spec.RelTolerance)}, nil
+	case domain.CheckerUnordered:
+		return builtin{compare: unordered}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q is not a builtin checker", ErrCheckerFailed, spec.Kind)
+	}
+}
+
+func exact(expected, actual string) bool {
+	return expected == actual
+}
+
+func whitespace(expected, actual string) bool {
+	return equalStrings(strings.Fields(expected), strings.Fields(actual))
+}
+
+// float accepts tokens that are equal as text, or numbers within either
+// tolerance. Infinities only match the infinity of the same sign, and NaN only
+// the same token.
+func float(absTolerance, relTolerance float64) compareFunc {
+	return func(expected, actual string) bool {
+		want, got := strings.Fields(expected), strings.Fields(actual)
+		if len(want) != len(got) {
+			return false
+		}
+
+		for i := range want {
+			if want[i] == got[i] {
+				continue
+			}
+
+			w, errW := strconv.ParseFloat(want[i], 64)
+			g, errG := strconv.ParseFloat(got[i], 64)
+			if errW != nil || errG != nil || math.IsNaN(w) || math.IsNaN(g) {
+				return false
+			}
+
+			// The difference to an infinity is NaN or infinite, which the
+			// tolerances would compare wrongly.
+			if math.IsInf(w, 0) || math.IsInf(g, 0) {
+				if w != g {
+					return false
+				}
+				continue
+			}
+
+			diff := math.Abs(w - g)
+			if diff > absTolerance && diff > relTolerance*math.Abs(w) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+func unordered(expected, actual string) bool {
+	want, got := lines(expected), lines(actual)
+	sort.Strings(want)
+	sort.Strings(got)
+
+	return equalStrings(want, got)
+}
+
+// lines splits output into lines without trailing whitespace, ignoring
+// line endings and trailing empty lines.
+func lines(output string) []string {
+	split := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i, line := range split {
+		split[i] = strings.TrimRight(line, " \t\r")
+	}
+
+	for len(split) > 0 && split[len(split)-1] == "" {
+		split = split[:len(split)-1]
+	}
+
+	return split
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
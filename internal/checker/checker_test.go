@@ -0,0 +1,144 @@
+package checker
+
+import (
+	"Code_executor/internal/domain"
+	"context"
+	"testing"
+)
+
+type checkTest struct {
+	name     string
+	expected string
+	actual   string
+	accept   bool
+}
+
+func TestWhitespace(t *testing.T) {
+	runChecks(t, domain.Checker{Kind: domain.CheckerWhitespace}, []checkTest{
+		{"identical", "1 2\n3\n", "1 2\n3\n", true},
+		{"spaces between tokens", "1 2", "1   2", true},
+		{"line breaks between tokens", "1 2\n3", "1\n2 3", true},
+		{"trailing newline", "1 2", "1 2\n\n", true},
+		{"carriage returns", "1\r\n2\r\n", "1\n2\n", true},
+		{"leading whitespace", "1", "  \t1", true},
+		{"both empty", "", "\n", true},
+		{"different token", "1 2", "1 3", false},
+		{"missing token", "1 2", "1", false},
+		{"extra token", "1", "1 2", false},
+		{"joined tokens", "1 2", "12", false},
+		{"case", "yes", "YES", false},
+	})
+}
+
+func TestDefaultIsWhitespace(t *testing.T) {
+	runChecks(t, domain.Checker{}, []checkTest{
+		{"spaces between tokens", "1 2", "1   2", true},
+		{"different token", "1 2", "1 3", false},
+	})
+}
+
+func TestExact(t *testing.T) {
+	runChecks(t, domain.Checker{Kind: domain.CheckerExact}, []checkTest{
+		{"identical", "1 2\n", "1 2\n", true},
+		{"trailing newline", "1 2", "1 2\n", false},
+		{"spaces between tokens", "1 2", "1  2", false},
+	})
+}
+
+func TestFloat(t *testing.T) {
+	// The values are exact in binary, so the boundaries are too.
+	runChecks(t, domain.Checker{Kind: domain.CheckerFloat, AbsTolerance: 0.5}, []checkTest{
+		{"identical tokens", "1.5 abc", "1.5 abc", true},
+		{"same number written differently", "1.5", "1.50e0", true},
+		{"within the tolerance", "1", "1.25", true},
+		{"at the tolerance", "1", "1.5", true},
+		{"at the tolerance below", "1", "0.5", true},
+		{"beyond the tolerance", "1", "1.5000001", false},
+		{"beyond the tolerance below", "1", "0.4999999", false},
+		{"whitespace between tokens", "1 2", "1\n  2\n", true},
+		{"missing token", "1 2", "1", false},
+		{"extra token", "1", "1 2", false},
+		{"different words", "abc", "abd", false},
+		{"word for a number", "1", "one", false},
+		{"number for a word", "one", "1", false},
+	})
+
+	runChecks(t, domain.Checker{Kind: domain.CheckerFloat, RelTolerance: 0.25}, []checkTest{
+		{"at the relative tolerance", "4", "5", true},
+		{"beyond the relative tolerance", "4", "5.0001", false},
+		{"relative to the expected value", "5", "3.75", true},
+		{"not relative to the actual value", "4", "3", true},
+		{"relative tolerance of zero", "0", "0.0001", false},
+		{"negative values", "-4", "-5", true},
+		{"sign", "4", "-4", false},
+	})
+
+	runChecks(t, domain.Checker{Kind: domain.CheckerFloat, AbsTolerance: 0.5, RelTolerance: 0.25}, []checkTest{
+		{"within the absolute tolerance only", "0.5", "1", true},
+		{"within the relative tolerance only", "100", "125", true},
+		{"within neither", "1", "1.75", false},
+	})
+
+	// Tokens that are not finite numbers.
+	runChecks(t, domain.Checker{Kind: domain.CheckerFloat, AbsTolerance: 1, RelTolerance: 1}, []checkTest{
+		{"same NaN token", "NaN", "NaN", true},
+		{"NaN written differently", "NaN", "nan", false},
+		{"NaN for a number", "1", "NaN", false},
+		{"number for NaN", "NaN", "1", false},
+		{"same Inf token", "Inf", "Inf", true},
+		{"Inf written differently", "Inf", "+infinity", true},
+		{"-Inf written differently", "-Inf", "-infinity", true},
+		{"Inf for -Inf", "-Inf", "Inf", false},
+		{"large number for Inf", "Inf", "1e308", false},
+		{"Inf for a large number", "1e308", "Inf", false},
+		{"Inf for NaN", "NaN", "Inf", false},
+		{"out of range number for Inf", "Inf", "1e400", false},
+	})
+}
+
+func TestUnordered(t *testing.T) {
+	runChecks(t, domain.Checker{Kind: domain.CheckerUnordered}, []checkTest{
+		{"same order", "a\nb\n", "a\nb\n", true},
+		{"other order", "a\nb\nc", "c\na\nb", true},
+		{"trailing spaces", "a\nb", "b  \na\t", true},
+		{"carriage returns", "a\r\nb\r\n", "b\na\n", true},
+		{"trailing empty lines", "a\nb", "b\na\n\n\n", true},
+		{"duplicates count", "a\na\nb", "a\nb\nb", false},
+		{"missing line", "a\nb", "a", false},
+		{"leading spaces", "a\nb", " a\nb", false},
+		{"inner empty line", "a\nb", "a\n\nb", false},
+		{"line split", "a b", "a\nb", false},
+	})
+}
+
+func TestNewBuiltinRejectsCustom(t *testing.T) {
+	if _, err := NewBuiltin(domain.Checker{Kind: domain.CheckerCustom}); err == nil {
+		t.Error("NewBuiltin of a custom checker succeeded")
+	}
+}
+
+func runChecks(t *testing.T, spec domain.Checker, tests []checkTest) {
+	t.Helper()
+
+	checker, err := NewBuiltin(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			verdict, err := checker.Check(context.Background(), "", test.expected, test.actual)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := domain.VerdictWrongAnswer
+			if test.accept {
+				want = domain.VerdictAccepted
+			}
+			if verdict != want {
+				t.Errorf("Check(%q, %q) = %s, want %s", test.expected, test.actual, verdict, want)
+			}
+		})
+	}
+}
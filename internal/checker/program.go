@@ -0,0 +1,102 @@
+package checker
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/runner"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	inputFile    = "checker_input.txt"
+	expectedFile = "checker_expected.txt"
+	actualFile   = "checker_actual.txt"
+
+	// Exit codes of a checker program.
+	exitAccepted    = 0
+	exitWrongAnswer = 1
+
+	// maxCheckerMessage bounds how much checker output ends up in errors.
+	maxCheckerMessage = 512
+)
+
+// Program is a custom checker compiled once and run in the sandbox for every
+// test case.
+type Program struct {
+	session runner.Session
+}
+
+// NewProgram prepares, and compiles if needed, the checker program of a
+// judged execution.
+func NewProgram(ctx context.Context, run runner.Runner, exec *domain.Execution) (*Program, error) {
+	checkerExec, err := exec.NewCheckerExecution(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	lang, ok := domain.GetLanguage(checkerExec.Language)
+	if !ok {
+		return nil, fmt.Errorf("%w: checker language %q is not supported", ErrCheckerFailed, checkerExec.Language)
+	}
+
+	session, err := run.Prepare(ctx, runner.Request{Execution: checkerExec, Language: lang})
+	if err != nil {
+		return nil, err
+	}
+
+	if lang.Compiled() {
+		result, err := session.Compile(ctx)
+		if err != nil {
+			_ = session.Close()
+			return nil, err
+		}
+		if result.Failed() {
+			_ = session.Close()
+			return nil, fmt.Errorf("%w: checker does not compile: %s", ErrCheckerFailed, message(result.Stderr))
+		}
+	}
+
+	return &Program{session: session}, nil
+}
+
+func (p *Program) Check(ctx context.Context, input, expected, actual string) (domain.Verdict, error) {
+	result, err := p.session.Run(ctx, runner.RunInput{
+		Args: []string{inputFile, expectedFile, actualFile},
+		Files: map[string]string{
+			inputFile:    input,
+			expectedFile: expected,
+			actualFile:   actual,
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case result.TimedOut:
+		return "", fmt.Errorf("%w: checker timed out", ErrCheckerFailed)
+	case result.OutputLimitExceeded() || result.BlockedSyscall != "" || len(result.Violations) > 0:
+		return "", fmt.Errorf("%w: checker was killed: %s", ErrCheckerFailed, message(result.Stderr))
+	case result.ExitCode == exitAccepted:
+		return domain.VerdictAccepted, nil
+	case result.ExitCode == exitWrongAnswer:
+		return domain.VerdictWrongAnswer, nil
+	default:
+		return "", fmt.Errorf("%w: checker exited with %d: %s", ErrCheckerFailed, result.ExitCode, message(result.Stderr))
+	}
+}
+
+func (p *Program) Close() error {
+	return p.session.Close()
+}
+
+func message(stderr string) string {
+	stderr = strings.TrimSpace(stderr)
+	if len(stderr) > maxCheckerMessage {
+		return stderr[:maxCheckerMessage] + "..."
+	}
+
+	return stderr
+}
@@ -0,0 +1,211 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// MissedRunPolicy decides what a schedule does about the runs that were due
+// while no scheduler was running.
+type MissedRunPolicy string
+
+const (
+	// MissedRunSkip drops runs that are more than MissedRunGrace late; the
+	// schedule resumes at its next tick.
+	MissedRunSkip MissedRunPolicy = "skip"
+	// MissedRunOnce runs once for all missed runs.
+	MissedRunOnce MissedRunPolicy = "run_once"
+	// MissedRunAll runs every missed run, up to MaxCatchUpRuns.
+	MissedRunAll MissedRunPolicy = "run_all"
+)
+
+const (
+	MaxCatchUpRuns = 10
+	MissedRunGrace = time.Minute
+	// MinScheduleInterval bounds how often a schedule may run.
+	MinScheduleInterval = time.Minute
+)
+
+var (
+	ErrInvalidSchedule = errors.New("invalid schedule")
+)
+
+// Schedule creates an execution of its submission at every tick of its cron
+// expression, in UTC.
+type Schedule struct {
+	ID        string
+	UserID    string
+	Language  string
+	Code      string
+	Stdin     string
+	TimeoutMs int
+	Priority  int
+	// Cron is a standard five-field expression or a descriptor such as
+	// @hourly or @every 5m.
+	Cron            string
+	MissedRunPolicy MissedRunPolicy
+	Enabled         bool
+
+	// NextRunAt is the next tick; runs are due once it passed.
+	NextRunAt time.Time
+	LastRunAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// ScheduleDefinition is what users set on a schedule.
+type ScheduleDefinition struct {
+	Language        string
+	Code            string
+	Stdin           string
+	TimeoutMs       int
+	Priority        int
+	Cron            string
+	MissedRunPolicy MissedRunPolicy
+	Enabled         bool
+}
+
+// NewSchedule creates a schedule whose first run is the first tick after
+// createdAt. An empty missed-run policy defaults to MissedRunSkip.
+func NewSchedule(id, userID string, def ScheduleDefinition, createdAt time.Time) (*Schedule, error) {
+	if id == "" || userID == "" || createdAt.IsZero() {
+		return nil, fmt.Errorf("%w: missing required fields", ErrInvalidSchedule)
+	}
+
+	s := &Schedule{
+		ID:        id,
+		UserID:    userID,
+		CreatedAt: createdAt.UTC(),
+	}
+
+	if err := s.Define(def, createdAt); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Define replaces what the schedule runs and when; the next run is the
+// first tick after now.
+func (s *Schedule) Define(def ScheduleDefinition, now time.Time) error {
+	if _, ok := GetLanguage(def.Language); !ok {
+		return fmt.Errorf("%w: language \"%s\" is not supported", ErrInvalidSchedule, def.Language)
+	}
+
+	if def.Code == "" {
+		return fmt.Errorf("%w: code is required", ErrInvalidSchedule)
+	}
+
+	if def.TimeoutMs <= 0 {
+		return fmt.Errorf("%w: timeout must be positive", ErrInvalidSchedule)
+	}
+
+	if def.Priority < MinPriority || def.Priority > MaxPriority {
+		return fmt.Errorf("%w: priority must be between %d and %d", ErrInvalidSchedule, MinPriority, MaxPriority)
+	}
+
+	if def.MissedRunPolicy == "" {
+		def.MissedRunPolicy = MissedRunSkip
+	}
+
+	switch def.MissedRunPolicy {
+	case MissedRunSkip, MissedRunOnce, MissedRunAll:
+	default:
+		return fmt.Errorf("%w: unknown missed run policy %q", ErrInvalidSchedule, def.MissedRunPolicy)
+	}
+
+	sched, err := parseCron(def.Cron)
+	if err != nil {
+		return err
+	}
+
+	s.Language = def.Language
+	s.Code = def.Code
+	s.Stdin = def.Stdin
+	s.TimeoutMs = def.TimeoutMs
+	s.Priority = def.Priority
+	s.Cron = def.Cron
+	s.MissedRunPolicy = def.MissedRunPolicy
+	s.Enabled = def.Enabled
+	s.NextRunAt = sched.Next(now.UTC())
+	s.UpdatedAt = now.UTC()
+	return nil
+}
+
+// Definition returns what Define last set.
+func (s *Schedule) Definition() ScheduleDefinition {
+	return ScheduleDefinition{
+		Language:        s.Language,
+		Code:            s.Code,
+		Stdin:           s.Stdin,
+		TimeoutMs:       s.TimeoutMs,
+		Priority:        s.Priority,
+		Cron:            s.Cron,
+		MissedRunPolicy: s.MissedRunPolicy,
+		Enabled:         s.Enabled,
+	}
+}
+
+// Advance moves a schedule whose NextRunAt passed to its first tick after
+// now and returns the ticks that passed which are to be run, according to
+// its missed-run policy.
+func (s *Schedule) Advance(now time.Time) ([]time.Time, error) {
+	sched, err := parseCron(s.Cron)
+	if err != nil {
+		return nil, err
+	}
+
+	now = now.UTC()
+	if s.NextRunAt.After(now) {
+		return nil, nil
+	}
+
+	var ticks []time.Time
+	for tick := s.NextRunAt; !tick.After(now); tick = sched.Next(tick) {
+		ticks = append(ticks, tick)
+		if len(ticks) > MaxCatchUpRuns {
+			ticks = ticks[1:]
+		}
+	}
+
+	switch latest := ticks[len(ticks)-1]; s.MissedRunPolicy {
+	case MissedRunAll:
+	case MissedRunOnce:
+		ticks = []time.Time{latest}
+	default:
+		ticks = nil
+		if now.Sub(latest) <= MissedRunGrace {
+			ticks = []time.Time{latest}
+		}
+	}
+
+	s.NextRunAt = sched.Next(now)
+	s.LastRunAt = timePtr(now)
+	s.UpdatedAt = now
+	return ticks, nil
+}
+
+// parseCron parses a cron expression of a schedule, which must not tick more
+// often than MinScheduleInterval. Only the gap between the first two ticks
+// after the epoch is checked: five fields cannot tick more often than every
+// minute, and @every, the only descriptor that can, ticks at a fixed interval.
+func parseCron(expr string) (cron.Schedule, error) {
+	if expr == "" {
+		return nil, fmt.Errorf("%w: cron expression is required", ErrInvalidSchedule)
+	}
+
+	sched, err := cron.ParseStandard(expr)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidSchedule, err)
+	}
+
+	first := sched.Next(time.Unix(0, 0).UTC())
+	if sched.Next(first).Sub(first) < MinScheduleInterval {
+		return nil, fmt.Errorf("%w: schedules may not run more often than every %s", ErrInvalidSchedule, MinScheduleInterval)
+	}
+
+	return sched, nil
+}
@@ -0,0 +1,111 @@
+package domain
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAdvance(t *testing.T) {
+	created := time.Date(2026, 1, 1, 0, 30, 0, 0, time.UTC)
+	at := func(day, hour, minute, second int) time.Time {
+		return time.Date(2026, 1, day, hour, minute, second, 0, time.UTC)
+	}
+
+	// The last MaxCatchUpRuns of the 24 hourly ticks up to Jan 2 00:00.
+	var capped []time.Time
+	for tick := at(1, 15, 0, 0); !tick.After(at(2, 0, 0, 0)); tick = tick.Add(time.Hour) {
+		capped = append(capped, tick)
+	}
+	if len(capped) != MaxCatchUpRuns {
+		t.Fatalf("%d capped ticks, want %d", len(capped), MaxCatchUpRuns)
+	}
+
+	for _, test := range []struct {
+		name   string
+		policy MissedRunPolicy
+		now    time.Time
+		want   []time.Time
+		// wantNext is the next run after Advance.
+		wantNext time.Time
+	}{
+		{"not due", MissedRunSkip, at(1, 0, 59, 59), nil, at(1, 1, 0, 0)},
+		{"skip on time", MissedRunSkip, at(1, 1, 0, 0), []time.Time{at(1, 1, 0, 0)}, at(1, 2, 0, 0)},
+		{"skip at the end of the grace", MissedRunSkip, at(1, 1, 1, 0), []time.Time{at(1, 1, 0, 0)}, at(1, 2, 0, 0)},
+		{"skip after the grace", MissedRunSkip, at(1, 1, 1, 1), nil, at(1, 2, 0, 0)},
+		{"skip runs the latest of several within the grace", MissedRunSkip, at(1, 4, 0, 30), []time.Time{at(1, 4, 0, 0)}, at(1, 5, 0, 0)},
+		{"skip drops several after the grace", MissedRunSkip, at(1, 4, 30, 0), nil, at(1, 5, 0, 0)},
+		{"run once on time", MissedRunOnce, at(1, 1, 0, 0), []time.Time{at(1, 1, 0, 0)}, at(1, 2, 0, 0)},
+		{"run once after several", MissedRunOnce, at(1, 3, 30, 0), []time.Time{at(1, 3, 0, 0)}, at(1, 4, 0, 0)},
+		{"run all after several", MissedRunAll, at(1, 3, 30, 0), []time.Time{at(1, 1, 0, 0), at(1, 2, 0, 0), at(1, 3, 0, 0)}, at(1, 4, 0, 0)},
+		{"run all capped", MissedRunAll, at(2, 0, 30, 0), capped, at(2, 1, 0, 0)},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestSchedule(t, "@hourly", test.policy, created)
+
+			ticks, err := s.Advance(test.now)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !slices.EqualFunc(ticks, test.want, time.Time.Equal) {
+				t.Errorf("Advance = %v, want %v", ticks, test.want)
+			}
+			if !s.NextRunAt.Equal(test.wantNext) {
+				t.Errorf("NextRunAt = %v, want %v", s.NextRunAt, test.wantNext)
+			}
+
+			due := !test.now.Before(at(1, 1, 0, 0))
+			if ran := s.LastRunAt != nil; ran != due || due && !s.LastRunAt.Equal(test.now) {
+				t.Errorf("LastRunAt = %v, want %v when due", s.LastRunAt, test.now)
+			}
+		})
+	}
+}
+
+func TestParseCron(t *testing.T) {
+	for _, test := range []struct {
+		expr  string
+		valid bool
+	}{
+		{"* * * * *", true},
+		{"*/5 * * * *", true},
+		{"@hourly", true},
+		{"@every 1m", true},
+		{"@every 90s", true},
+		{"@every 30s", false},
+		{"@every 59s", false},
+		{"* * * * * *", false},
+		{"not cron", false},
+		{"", false},
+	} {
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := parseCron(test.expr)
+			if test.valid && err != nil {
+				t.Errorf("parseCron = %v", err)
+			}
+			if !test.valid && !errors.Is(err, ErrInvalidSchedule) {
+				t.Errorf("parseCron = %v, want %v", err, ErrInvalidSchedule)
+			}
+		})
+	}
+}
+
+func newTestSchedule(t *testing.T, expr string, policy MissedRunPolicy, createdAt time.Time) *Schedule {
+	t.Helper()
+
+	s, err := NewSchedule("schedule-1", "alice", ScheduleDefinition{
+		Language:        "python",
+		Code:            "print(1)",
+		TimeoutMs:       1000,
+		Cron:            expr,
+		MissedRunPolicy: policy,
+		Enabled:         true,
+	}, createdAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return s
+}
@@ -3,17 +3,37 @@ package domain
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 )
 
 type ExecutionStatus string
 
 const (
-	ExecutionStatusQueued    ExecutionStatus = "queued"
-	ExecutionStatusRunning   ExecutionStatus = "running"
-	ExecutionStatusCompleted ExecutionStatus = "completed"
-	ExecutionStatusFailed    ExecutionStatus = "failed"
-	ExecutionStatusTimedOut  ExecutionStatus = "timed_out"
+	// Scheduled executions are queued once their RunAt is reached.
+	ExecutionStatusScheduled        ExecutionStatus = "scheduled"
+	ExecutionStatusQueued           ExecutionStatus = "queued"
+	ExecutionStatusCompiling        ExecutionStatus = "compiling"
+	ExecutionStatusRunning          ExecutionStatus = "running"
+	ExecutionStatusCompleted        ExecutionStatus = "completed"
+	ExecutionStatusFailed           ExecutionStatus = "failed"
+	ExecutionStatusTimedOut         ExecutionStatus = "timed_out"
+	ExecutionStatusCompilationError ExecutionStatus = "compilation_error"
+	// The program exited non-zero or was killed by a signal.
+	ExecutionStatusRuntimeError        ExecutionStatus = "runtime_error"
+	ExecutionStatusMemoryLimitExceeded ExecutionStatus = "memory_limit_exceeded"
+	ExecutionStatusOutputLimitExceeded ExecutionStatus = "output_limit_exceeded"
+	ExecutionStatusCancelled           ExecutionStatus = "cancelled"
+)
+
+// FailureReason tells clients why an execution failed without parsing stderr.
+type FailureReason string
+
+const (
+	FailureReasonSyscallNotAllowed FailureReason = "syscall_not_allowed"
+	FailureReasonCheckerError      FailureReason = "checker_error"
+	// The worker or sandbox failed on every attempt; see Redrive.
+	FailureReasonInfrastructure FailureReason = "infrastructure"
 )
 
 var (
@@ -22,40 +42,100 @@ var (
 )
 
 var finalStatuses = map[ExecutionStatus]struct{}{
-	ExecutionStatusCompleted: {},
-	ExecutionStatusFailed:    {},
-	ExecutionStatusTimedOut:  {},
+	ExecutionStatusCompleted:           {},
+	ExecutionStatusFailed:              {},
+	ExecutionStatusTimedOut:            {},
+	ExecutionStatusCompilationError:    {},
+	ExecutionStatusRuntimeError:        {},
+	ExecutionStatusMemoryLimitExceeded: {},
+	ExecutionStatusOutputLimitExceeded: {},
+	ExecutionStatusCancelled:           {},
 }
 var statusTransitions = map[ExecutionStatus]map[ExecutionStatus]struct{}{
+	ExecutionStatusScheduled: {
+		ExecutionStatusQueued:    {},
+		ExecutionStatusCancelled: {},
+	},
 	ExecutionStatusQueued: {
-		ExecutionStatusRunning: {},
+		ExecutionStatusCompiling: {},
+		ExecutionStatusRunning:   {},
+		ExecutionStatusCancelled: {},
+	},
+
+	ExecutionStatusCompiling: {
+		ExecutionStatusQueued:           {},
+		ExecutionStatusRunning:          {},
+		ExecutionStatusCompilationError: {},
+		ExecutionStatusFailed:           {},
+		ExecutionStatusCancelled:        {},
 	},
 
 	ExecutionStatusRunning: {
-		ExecutionStatusCompleted: {},
-		ExecutionStatusFailed:    {},
-		ExecutionStatusTimedOut:  {},
+		ExecutionStatusQueued:              {},
+		ExecutionStatusCompleted:           {},
+		ExecutionStatusFailed:              {},
+		ExecutionStatusTimedOut:            {},
+		ExecutionStatusRuntimeError:        {},
+		ExecutionStatusMemoryLimitExceeded: {},
+		ExecutionStatusOutputLimitExceeded: {},
+		ExecutionStatusCancelled:           {},
 	},
 }
 
 type Execution struct {
-	ID         string
-	Language   string
-	Code       string
+	ID       string
+	Language string
+	// Files maps slash-separated paths relative to the workspace to their
+	// content; Entrypoint is the one the language's commands start from.
+	Files      map[string]string
+	Entrypoint string
 	Stdin      string
 	TimeoutMs  int
 	Status     ExecutionStatus
 	Stdout     string
 	Stderr     string
 	ExitCode   *int
-	CreatedAt  time.Time
+
+	StdoutTruncated bool
+	StderrTruncated bool
+
+	CompileStdout   string
+	CompileStderr   string
+	CompileExitCode *int
+
+	// Judged submissions only; see SetTestCases.
+	TestCases   []TestCase
+	Checker     Checker
+	TestResults []TestCaseResult
+	Verdict     Verdict
+
+	CreatedAt time.Time
+	// RunAt is when a scheduled execution becomes queued; see Schedule.
+	RunAt      *time.Time
 	StartedAt  *time.Time
 	FinishedAt *time.Time
 	UserID     string
+	// ScheduleID is the schedule that created the execution, if any.
+	ScheduleID string
+	// Priority orders the execution in the queue; see SetPriority.
+	Priority   int
+	Limits     ResourceLimits
+	Violations []ResourceViolation
+	Usage      ResourceUsage
+	// Attempts counts how often a worker started the execution.
+	Attempts int
+	// WorkerID is the worker that started the current attempt, if it has an
+	// id.
+	WorkerID string
+
+	FailureReason FailureReason
+	FailureDetail string
 }
 
-func NewExecution(id, languageName, code, stdin string, timeoutMs int, userID string, createdAt time.Time) (*Execution, error) {
-	if id == "" || languageName == "" || code == "" || userID == "" || createdAt.IsZero() {
+// NewExecution creates a queued execution. An empty entrypoint defaults to the
+// language's SourceFile.
+func NewExecution(id, languageName string, files map[string]string, entrypoint, stdin string, timeoutMs int, userID string, createdAt time.Time) (*Execution, error) {
+	if id == "" || languageName == "" || userID == "" || createdAt.IsZero() {
 		return nil, fmt.Errorf("%w: missing required fields", ErrInvalidExecution)
 	}
 	if timeoutMs <= 0 {
@@ -67,8 +147,12 @@ func NewExecution(id, languageName, code, stdin string, timeoutMs int, userID st
 		return nil, fmt.Errorf("%w: language \"%s\" is not supported", ErrInvalidExecution, languageName)
 	}
 
-	if language.MaxCodeSize != nil && len(code) > *language.MaxCodeSize {
-		return nil, fmt.Errorf("%w: code size exceeds max limit for %s", ErrInvalidExecution, languageName)
+	if entrypoint == "" {
+		entrypoint = language.SourceFile
+	}
+
+	if err := validateFiles(files, entrypoint, language.MaxCodeSize); err != nil {
+		return nil, err
 	}
 
 	if language.MaxTimeoutMs != nil && timeoutMs > *language.MaxTimeoutMs {
@@ -76,29 +160,77 @@ func NewExecution(id, languageName, code, stdin string, timeoutMs int, userID st
 	}
 
 	execution := &Execution{
-		ID:        id,
-		Language:  languageName,
-		Code:      code,
-		Stdin:     stdin,
-		TimeoutMs: timeoutMs,
-		Status:    ExecutionStatusQueued,
-		CreatedAt: createdAt.UTC(),
-		UserID:    userID,
+		ID:         id,
+		Language:   languageName,
+		Files:      cloneFiles(files),
+		Entrypoint: entrypoint,
+		Stdin:      stdin,
+		TimeoutMs:  timeoutMs,
+		Status:     ExecutionStatusQueued,
+		CreatedAt:  createdAt.UTC(),
+		UserID:     userID,
+		Limits:     language.Limits(),
 	}
 
 	return execution, nil
 }
 
+func (e *Execution) MarkCompiling(startedAt time.Time) error {
+	if startedAt.IsZero() {
+		return fmt.Errorf("%w: started at time is zero", ErrInvalidExecution)
+	}
+
+	if err := e.transition(ExecutionStatusCompiling); err != nil {
+		return err
+	}
+
+	e.Attempts++
+	e.StartedAt = timePtr(startedAt)
+	return nil
+}
+
+// MarkRunning starts the run phase. After a compile phase StartedAt keeps the
+// time compilation began.
 func (e *Execution) MarkRunning(startedAt time.Time) error {
 	if startedAt.IsZero() {
 		return fmt.Errorf("%w: started at time is zero", ErrInvalidExecution)
 	}
 
+	fromQueued := e.Status == ExecutionStatusQueued
 	if err := e.transition(ExecutionStatusRunning); err != nil {
 		return err
 	}
 
-	e.StartedAt = timePtr(startedAt)
+	if fromQueued {
+		e.Attempts++
+	}
+	if e.StartedAt == nil {
+		e.StartedAt = timePtr(startedAt)
+	}
+	return nil
+}
+
+func (e *Execution) RecordCompilation(stdout, stderr string, exitCode int) {
+	e.CompileStdout = stdout
+	e.CompileStderr = stderr
+	e.CompileExitCode = intPtr(exitCode)
+}
+
+// MarkCompilationError ends an execution whose compile phase failed; the
+// program is never run. The compiler output must already be recorded.
+func (e *Execution) MarkCompilationError(finishedAt time.Time) error {
+	if finishedAt.IsZero() {
+		return fmt.Errorf("%w: finished at time is zero", ErrInvalidExecution)
+	}
+
+	if err := e.transition(ExecutionStatusCompilationError); err != nil {
+		return err
+	}
+
+	if e.IsJudged() {
+		e.Verdict = VerdictCompilationError
+	}
+	e.FinishedAt = timePtr(finishedAt)
 	return nil
 }
 
@@ -133,6 +265,59 @@ func (e *Execution) MarkFailed(stderr string, exitCode *int, finishedAt time.Tim
 	return nil
 }
 
+func (e *Execution) MarkFailedWithReason(reason FailureReason, detail, stderr string, exitCode *int, finishedAt time.Time) error {
+	if reason == "" {
+		return fmt.Errorf("%w: failure reason is empty", ErrInvalidExecution)
+	}
+
+	if err := e.MarkFailed(stderr, exitCode, finishedAt); err != nil {
+		return err
+	}
+
+	e.FailureReason = reason
+	e.FailureDetail = detail
+	return nil
+}
+
+// MarkRuntimeError ends a program that exited non-zero or was killed by a
+// signal, reported as 128+signal.
+func (e *Execution) MarkRuntimeError(stdout, stderr string, exitCode int, finishedAt time.Time) error {
+	return e.finishWithOutput(ExecutionStatusRuntimeError, stdout, stderr, intPtr(exitCode), finishedAt)
+}
+
+// MarkMemoryLimitExceeded ends a program killed by the memory limit.
+func (e *Execution) MarkMemoryLimitExceeded(stdout, stderr string, exitCode *int, finishedAt time.Time) error {
+	return e.finishWithOutput(ExecutionStatusMemoryLimitExceeded, stdout, stderr, exitCode, finishedAt)
+}
+
+// MarkOutputLimitExceeded ends a program that was stopped for writing too
+// much; the output collected up to the limit is kept.
+func (e *Execution) MarkOutputLimitExceeded(stdout, stderr string, finishedAt time.Time) error {
+	return e.finishWithOutput(ExecutionStatusOutputLimitExceeded, stdout, stderr, nil, finishedAt)
+}
+
+func (e *Execution) finishWithOutput(status ExecutionStatus, stdout, stderr string, exitCode *int, finishedAt time.Time) error {
+	if finishedAt.IsZero() {
+		return fmt.Errorf("%w: finished at time is zero", ErrInvalidExecution)
+	}
+
+	if err := e.transition(status); err != nil {
+		return err
+	}
+
+	e.Stdout = stdout
+	e.Stderr = stderr
+	e.ExitCode = exitCode
+	e.FinishedAt = timePtr(finishedAt)
+	return nil
+}
+
+// RecordTruncation marks which streams were cut at the output limit.
+func (e *Execution) RecordTruncation(stdoutTruncated, stderrTruncated bool) {
+	e.StdoutTruncated = stdoutTruncated
+	e.StderrTruncated = stderrTruncated
+}
+
 func (e *Execution) MarkTimedOut(finishedAt time.Time) error {
 	if finishedAt.IsZero() {
 		return fmt.Errorf("%w: finished at time is zero", ErrInvalidExecution)
@@ -146,6 +331,85 @@ func (e *Execution) MarkTimedOut(finishedAt time.Time) error {
 	return nil
 }
 
+func (e *Execution) MarkCancelled(finishedAt time.Time) error {
+	if finishedAt.IsZero() {
+		return fmt.Errorf("%w: finished at time is zero", ErrInvalidExecution)
+	}
+
+	if err := e.transition(ExecutionStatusCancelled); err != nil {
+		return err
+	}
+
+	e.FinishedAt = timePtr(finishedAt)
+	return nil
+}
+
+// Requeue puts back an execution whose worker stopped before finishing it,
+// dropping everything the interrupted attempt recorded.
+func (e *Execution) Requeue() error {
+	if err := e.transition(ExecutionStatusQueued); err != nil {
+		return err
+	}
+
+	e.resetAttempt()
+	return nil
+}
+
+// Redrive queues an execution that failed for infrastructure reasons again,
+// with a fresh attempt budget.
+func (e *Execution) Redrive() error {
+	if e.Status != ExecutionStatusFailed || e.FailureReason != FailureReasonInfrastructure {
+		return fmt.Errorf("%w: only executions failed for infrastructure reasons can be redriven", ErrInvalidStatusTransition)
+	}
+
+	e.Status = ExecutionStatusQueued
+	e.resetAttempt()
+	e.Attempts = 0
+	e.FinishedAt = nil
+	e.FailureReason = ""
+	e.FailureDetail = ""
+	return nil
+}
+
+func (e *Execution) resetAttempt() {
+	e.Stdout = ""
+	e.Stderr = ""
+	e.ExitCode = nil
+	e.StdoutTruncated = false
+	e.StderrTruncated = false
+	e.CompileStdout = ""
+	e.CompileStderr = ""
+	e.CompileExitCode = nil
+	e.TestResults = nil
+	e.StartedAt = nil
+	e.Violations = nil
+	e.Usage = ResourceUsage{}
+	e.WorkerID = ""
+}
+
+// FinalStatuses returns the terminal statuses in a stable order.
+func FinalStatuses() []ExecutionStatus {
+	statuses := make([]ExecutionStatus, 0, len(finalStatuses))
+	for status := range finalStatuses {
+		statuses = append(statuses, status)
+	}
+	slices.Sort(statuses)
+	return statuses
+}
+
+// IsKnown reports whether s is one of the execution statuses.
+func (s ExecutionStatus) IsKnown() bool {
+	_, isFinal := finalStatuses[s]
+	_, hasNext := statusTransitions[s]
+	return isFinal || hasNext
+}
+
+// IsFinal reports whether the execution reached a terminal status.
+func (e *Execution) IsFinal() bool {
+	_, isFinal := finalStatuses[e.Status]
+	return isFinal
+}
+
 func (e *Execution) transition(newStatus ExecutionStatus) error {
 	if _, isFinal := finalStatuses[e.Status]; isFinal {
 		return fmt.Errorf("%w: current status %s is final", ErrInvalidStatusTransition, e.Status)
@@ -0,0 +1,76 @@
+package domain
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// entrypointPlaceholder in a language's commands is replaced by the path of
+// the execution's entrypoint file.
+const entrypointPlaceholder = "{entrypoint}"
+
+const maxFiles = 64
+
+// validateFiles checks a submission's file tree: relative slash-separated
+// paths that stay inside the workspace, an entrypoint among them, and a total
+// size within maxSize (nil => unlimited).
+func validateFiles(files map[string]string, entrypoint string, maxSize *int) error {
+	if len(files) == 0 {
+		return fmt.Errorf("%w: no files submitted", ErrInvalidExecution)
+	}
+	if len(files) > maxFiles {
+		return fmt.Errorf("%w: at most %d files are allowed", ErrInvalidExecution, maxFiles)
+	}
+
+	total := 0
+	for name, content := range files {
+		if err := validateFilePath(name); err != nil {
+			return err
+		}
+		total += len(content)
+	}
+
+	if maxSize != nil && total > *maxSize {
+		return fmt.Errorf("%w: total code size %d exceeds max limit %d", ErrInvalidExecution, total, *maxSize)
+	}
+
+	if _, ok := files[entrypoint]; !ok {
+		return fmt.Errorf("%w: entrypoint %q is not one of the files", ErrInvalidExecution, entrypoint)
+	}
+
+	return nil
+}
+
+func validateFilePath(name string) error {
+	switch {
+	case name == "":
+		return fmt.Errorf("%w: empty file path", ErrInvalidExecution)
+	case path.IsAbs(name):
+		return fmt.Errorf("%w: file path %q must be relative", ErrInvalidExecution, name)
+	case strings.ContainsAny(name, "\\\x00"):
+		return fmt.Errorf("%w: file path %q contains invalid characters", ErrInvalidExecution, name)
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == "" || part == "." || part == ".." {
+			return fmt.Errorf("%w: file path %q must not contain empty, \".\" or \"..\" segments", ErrInvalidExecution, name)
+		}
+		// The entrypoint is an argument of the language's commands, where a
+		// leading "-" would make it an option.
+		if strings.HasPrefix(part, "-") {
+			return fmt.Errorf("%w: file path %q must not have segments starting with \"-\"", ErrInvalidExecution, name)
+		}
+	}
+
+	return nil
+}
+
+func cloneFiles(files map[string]string) map[string]string {
+	clone := make(map[string]string, len(files))
+	for name, content := range files {
+		clone[name] = content
+	}
+
+	return clone
+}
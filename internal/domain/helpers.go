@@ -7,6 +7,11 @@ func intPtr(v int) *int {
 	return &val
 }
 
+func int64Ptr(v int64) *int64 {
+	val := v
+	return &val
+}
+
 func timePtr(t time.Time) *time.Time {
 	tt := t.UTC()
 	return &tt
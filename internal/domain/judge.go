@@ -0,0 +1,219 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Verdict is the judge's outcome for one test case, or for the whole
+// submission.
+type Verdict string
+
+const (
+	VerdictAccepted          Verdict = "accepted"
+	VerdictWrongAnswer       Verdict = "wrong_answer"
+	VerdictTimeLimitExceeded Verdict = "time_limit_exceeded"
+	VerdictRuntimeError      Verdict = "runtime_error"
+	// VerdictCompilationError is only used as the overall verdict.
+	VerdictCompilationError Verdict = "compilation_error"
+)
+
+const maxTestCases = 100
+
+// CheckerKind selects how a test case's output is compared with the
+// expected output.
+type CheckerKind string
+
+const (
+	CheckerExact CheckerKind = "exact"
+	// CheckerWhitespace compares whitespace-separated tokens, so spacing and
+	// line endings do not matter. It is the default.
+	CheckerWhitespace CheckerKind = "whitespace"
+	// CheckerFloat compares tokens as numbers within a tolerance where both
+	// parse as floats, and exactly otherwise.
+	CheckerFloat CheckerKind = "float"
+	// CheckerUnordered compares the multiset of lines.
+	CheckerUnordered CheckerKind = "unordered"
+	// CheckerCustom runs a checker program in the sandbox.
+	CheckerCustom CheckerKind = "custom"
+)
+
+const (
+	defaultFloatTolerance = 1e-6
+	maxCheckerTimeoutMs   = 5000
+)
+
+// Checker is the comparator of a judged execution's test suite.
+type Checker struct {
+	Kind CheckerKind
+
+	// CheckerFloat: a token is accepted when it is within either tolerance.
+	AbsTolerance float64
+	RelTolerance float64
+
+	// CheckerCustom: the checker program. It is run with the input, the
+	// expected and the actual output file as arguments and exits 0 to
+	// accept, 1 to reject; anything else is a checker failure.
+	Language   string
+	Files      map[string]string
+	Entrypoint string
+}
+
+// TestCase is one judged run of a submission. A zero TimeoutMs uses the
+// execution's timeout.
+type TestCase struct {
+	Stdin          string
+	ExpectedStdout string
+	TimeoutMs      int
+}
+
+type TestCaseResult struct {
+	Verdict    Verdict
+	Stdout     string
+	Stderr     string
+	ExitCode   *int
+	DurationMs int64
+}
+
+// SetTestCases turns the execution into a judged submission: instead of one
+// run with Stdin, the program is run once per case.
+func (e *Execution) SetTestCases(cases []TestCase) error {
+	if len(cases) == 0 {
+		return nil
+	}
+
+	if len(cases) > maxTestCases {
+		return fmt.Errorf("%w: at most %d test cases are allowed", ErrInvalidExecution, maxTestCases)
+	}
+
+	if e.Stdin != "" {
+		return fmt.Errorf("%w: stdin and test cases are mutually exclusive", ErrInvalidExecution)
+	}
+
+	language, ok := GetLanguage(e.Language)
+	if !ok {
+		return fmt.Errorf("%w: language \"%s\" is not supported", ErrInvalidExecution, e.Language)
+	}
+
+	for i, tc := range cases {
+		if tc.TimeoutMs < 0 {
+			return fmt.Errorf("%w: test case %d: timeout must not be negative", ErrInvalidExecution, i)
+		}
+		if language.MaxTimeoutMs != nil && tc.TimeoutMs > *language.MaxTimeoutMs {
+			return fmt.Errorf("%w: test case %d: timeout exceeds max limit for %s", ErrInvalidExecution, i, e.Language)
+		}
+	}
+
+	e.TestCases = append([]TestCase(nil), cases...)
+	return nil
+}
+
+func (e *Execution) IsJudged() bool {
+	return len(e.TestCases) > 0
+}
+
+// CaseTimeoutMs is the timeout of the i-th test case.
+func (e *Execution) CaseTimeoutMs(i int) int {
+	if e.TestCases[i].TimeoutMs > 0 {
+		return e.TestCases[i].TimeoutMs
+	}
+
+	return e.TimeoutMs
+}
+
+// SetChecker selects the comparator of a judged execution. A zero Kind keeps
+// the default whitespace comparison.
+func (e *Execution) SetChecker(checker Checker) error {
+	if !e.IsJudged() {
+		if checker.Kind != "" {
+			return fmt.Errorf("%w: a checker needs test cases", ErrInvalidExecution)
+		}
+		return nil
+	}
+
+	if checker.Kind == "" {
+		checker.Kind = CheckerWhitespace
+	}
+
+	switch checker.Kind {
+	case CheckerExact, CheckerWhitespace, CheckerUnordered:
+	case CheckerFloat:
+		if checker.AbsTolerance < 0 || checker.RelTolerance < 0 {
+			return fmt.Errorf("%w: checker tolerances must not be negative", ErrInvalidExecution)
+		}
+		if checker.AbsTolerance == 0 && checker.RelTolerance == 0 {
+			checker.AbsTolerance = defaultFloatTolerance
+			checker.RelTolerance = defaultFloatTolerance
+		}
+	case CheckerCustom:
+		language, ok := GetLanguage(checker.Language)
+		if !ok {
+			return fmt.Errorf("%w: checker language \"%s\" is not supported", ErrInvalidExecution, checker.Language)
+		}
+		if checker.Entrypoint == "" {
+			checker.Entrypoint = language.SourceFile
+		}
+		if err := validateFiles(checker.Files, checker.Entrypoint, language.MaxCodeSize); err != nil {
+			return err
+		}
+		checker.Files = cloneFiles(checker.Files)
+	default:
+		return fmt.Errorf("%w: unknown checker %q", ErrInvalidExecution, checker.Kind)
+	}
+
+	e.Checker = checker
+	return nil
+}
+
+// NewCheckerExecution builds the execution that runs a custom checker
+// program; it is never stored or queued.
+func (e *Execution) NewCheckerExecution(createdAt time.Time) (*Execution, error) {
+	if e.Checker.Kind != CheckerCustom {
+		return nil, fmt.Errorf("%w: execution has no checker program", ErrInvalidExecution)
+	}
+
+	timeoutMs := maxCheckerTimeoutMs
+	if language, ok := GetLanguage(e.Checker.Language); ok && language.MaxTimeoutMs != nil && *language.MaxTimeoutMs < timeoutMs {
+		timeoutMs = *language.MaxTimeoutMs
+	}
+
+	return NewExecution(e.ID+"-checker", e.Checker.Language, e.Checker.Files, e.Checker.Entrypoint, "", timeoutMs, e.UserID, createdAt)
+}
+
+// RecordTestResult stores the result of the next test case.
+func (e *Execution) RecordTestResult(result TestCaseResult) error {
+	if len(e.TestResults) >= len(e.TestCases) {
+		return fmt.Errorf("%w: all %d test cases already have a result", ErrInvalidExecution, len(e.TestCases))
+	}
+
+	e.TestResults = append(e.TestResults, result)
+	return nil
+}
+
+// MarkJudged completes a judged execution once every case has a result. The
+// overall verdict is accepted, or the verdict of the first failing case.
+func (e *Execution) MarkJudged(finishedAt time.Time) error {
+	if len(e.TestResults) != len(e.TestCases) {
+		return fmt.Errorf("%w: %d of %d test cases have a result", ErrInvalidExecution, len(e.TestResults), len(e.TestCases))
+	}
+
+	verdict := VerdictAccepted
+	for _, result := range e.TestResults {
+		if result.Verdict != VerdictAccepted {
+			verdict = result.Verdict
+			break
+		}
+	}
+
+	if finishedAt.IsZero() {
+		return fmt.Errorf("%w: finished at time is zero", ErrInvalidExecution)
+	}
+
+	if err := e.transition(ExecutionStatusCompleted); err != nil {
+		return err
+	}
+
+	e.Verdict = verdict
+	e.FinishedAt = timePtr(finishedAt)
+	return nil
+}
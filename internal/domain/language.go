@@ -3,15 +3,66 @@ package domain
 import (
 	"errors"
 	"fmt"
+	"sort"
+	"strings"
 )
 
 type Language struct {
+	Name           string
+	DockerImage    string
+	SourceFile     string
+	RunCommand     []string
+	MaxTimeoutMs   *int
+	MaxCodeSize    *int
+	MaxMemoryBytes *int64
+	MaxPids        *int
+	MaxCPUMillis   *int
+	MaxOutputBytes *int
+
+	SeccompProfile  string
+	SeccompSyscalls []string
+
+	CompileCommand   []string
+	CompileTimeoutMs int
+
+	MaxAttempts int
+}
+
+type LanguageConfig struct {
 	Name         string
 	DockerImage  string
+	SourceFile   string   // default entrypoint, and file name of single-file submissions
+	RunCommand   []string // argv executed inside the workspace; see Command
 	MaxTimeoutMs *int
-	MaxCodeSize  *int
+
+	// Compiled languages only: argv run in a writable workspace before
+	// RunCommand, and its time budget.
+	CompileCommand   []string
+	CompileTimeoutMs int
+
+	MaxCodeSize *int // total size of all submitted files; nil => no limit
+
+	// Sandbox limits; nil => unlimited. Executions may request lower values.
+	MaxMemoryBytes *int64
+	MaxPids        *int
+	MaxCPUMillis   *int // 1000 == one full CPU
+
+	// Cap for each of stdout and stderr; nil => unlimited.
+	MaxOutputBytes *int
+
+	// Syscall allowlist enforced by the sandbox runner: either a named
+	// profile from package seccomp or an inline list, which takes
+	// precedence. Both empty => no filter.
+	SeccompProfile  string
+	SeccompSyscalls []string
+
+	// How often a job is tried when it fails for infrastructure reasons;
+	// 0 => defaultMaxAttempts.
+	MaxAttempts int
 }
 
+const defaultMaxAttempts = 3
+
 var (
 	ErrInvalidLanguageCreation = errors.New("invalid language")
 	ErrLanguageNotFound        = errors.New("language not found")
@@ -21,34 +72,156 @@ var languages map[string]*Language
 
 func init() {
 	languages = map[string]*Language{
-		"python": mustLanguage(NewLanguage(
-			"python",
-			"docker.io/library/python:3.12-slim",
-			intPtr(5000), // max timeout ms
-			nil,          // nil => no code size limit
-		)),
-		"node": mustLanguage(NewLanguage(
-			"node",
-			"docker.io/library/node:20-alpine",
-			intPtr(4000),
-			nil,
-		)),
+		"python": mustLanguage(NewLanguage(LanguageConfig{
+			Name:           "python",
+			DockerImage:    "docker.io/library/python:3.12-slim",
+			SourceFile:     "main.py",
+			RunCommand:     []string{"python3", "{entrypoint}"},
+			MaxTimeoutMs:   intPtr(5000),
+			MaxMemoryBytes: int64Ptr(256 << 20),
+			MaxPids:        intPtr(64),
+			MaxCPUMillis:   intPtr(1000),
+			MaxOutputBytes: intPtr(64 << 10),
+			SeccompProfile: "interpreter",
+		})),
+		"node": mustLanguage(NewLanguage(LanguageConfig{
+			Name:           "node",
+			DockerImage:    "docker.io/library/node:20-alpine",
+			SourceFile:     "main.js",
+			RunCommand:     []string{"node", "{entrypoint}"},
+			MaxTimeoutMs:   intPtr(4000),
+			MaxMemoryBytes: int64Ptr(512 << 20),
+			MaxPids:        intPtr(64),
+			MaxCPUMillis:   intPtr(1000),
+			MaxOutputBytes: intPtr(64 << 10),
+			SeccompProfile: "interpreter",
+		})),
+		"c": mustLanguage(NewLanguage(LanguageConfig{
+			Name:             "c",
+			DockerImage:      "docker.io/library/gcc:14",
+			SourceFile:       "main.c",
+			CompileCommand:   []string{"gcc", "-O2", "-std=c17", "-o", "main", "{entrypoint}", "-lm"},
+			CompileTimeoutMs: 10000,
+			RunCommand:       []string{"./main"},
+			MaxTimeoutMs:     intPtr(5000),
+			MaxMemoryBytes:   int64Ptr(256 << 20),
+			MaxPids:          intPtr(64),
+			MaxCPUMillis:     intPtr(1000),
+			MaxOutputBytes:   intPtr(64 << 10),
+			SeccompProfile:   "native",
+		})),
+		"cpp": mustLanguage(NewLanguage(LanguageConfig{
+			Name:             "cpp",
+			DockerImage:      "docker.io/library/gcc:14",
+			SourceFile:       "main.cpp",
+			CompileCommand:   []string{"g++", "-O2", "-std=c++20", "-o", "main", "{entrypoint}"},
+			CompileTimeoutMs: 15000,
+			RunCommand:       []string{"./main"},
+			MaxTimeoutMs:     intPtr(5000),
+			MaxMemoryBytes:   int64Ptr(256 << 20),
+			MaxPids:          intPtr(64),
+			MaxCPUMillis:     intPtr(1000),
+			MaxOutputBytes:   intPtr(64 << 10),
+			SeccompProfile:   "native",
+		})),
+		"go": mustLanguage(NewLanguage(LanguageConfig{
+			Name:             "go",
+			DockerImage:      "docker.io/library/golang:1.23",
+			SourceFile:       "main.go",
+			CompileCommand:   []string{"go", "build", "-o", "main", "{entrypoint}"},
+			CompileTimeoutMs: 30000,
+			RunCommand:       []string{"./main"},
+			MaxTimeoutMs:     intPtr(5000),
+			MaxMemoryBytes:   int64Ptr(512 << 20),
+			MaxPids:          intPtr(128),
+			MaxCPUMillis:     intPtr(1000),
+			MaxOutputBytes:   intPtr(64 << 10),
+			SeccompProfile:   "native",
+			MaxAttempts:      2,
+		})),
+		"rust": mustLanguage(NewLanguage(LanguageConfig{
+			Name:             "rust",
+			DockerImage:      "docker.io/library/rust:1.82-slim",
+			SourceFile:       "main.rs",
+			CompileCommand:   []string{"rustc", "-O", "-o", "main", "{entrypoint}"},
+			CompileTimeoutMs: 30000,
+			RunCommand:       []string{"./main"},
+			MaxTimeoutMs:     intPtr(5000),
+			MaxMemoryBytes:   int64Ptr(256 << 20),
+			MaxPids:          intPtr(64),
+			MaxCPUMillis:     intPtr(1000),
+			MaxOutputBytes:   intPtr(64 << 10),
+			SeccompProfile:   "native",
+			MaxAttempts:      2,
+		})),
+		// javac resolves further classes from the workspace; the entrypoint
+		// must declare class Main.
+		"java": mustLanguage(NewLanguage(LanguageConfig{
+			Name:             "java",
+			DockerImage:      "docker.io/library/eclipse-temurin:21-jdk",
+			SourceFile:       "Main.java",
+			CompileCommand:   []string{"javac", "{entrypoint}"},
+			CompileTimeoutMs: 30000,
+			RunCommand:       []string{"java", "-Xss64m", "-XX:+UseSerialGC", "-cp", ".", "Main"},
+			MaxTimeoutMs:     intPtr(8000),
+			MaxMemoryBytes:   int64Ptr(1 << 30),
+			MaxPids:          intPtr(256),
+			MaxCPUMillis:     intPtr(2000),
+			MaxOutputBytes:   intPtr(64 << 10),
+			SeccompProfile:   "jvm",
+			MaxAttempts:      2,
+		})),
 	}
 }
 
-func NewLanguage(languageName, dockerImage string, maxTimeoutMs, maxCodeSize *int) (*Language, error) {
-	if languageName == "" || dockerImage == "" {
+func NewLanguage(cfg LanguageConfig) (*Language, error) {
+	if cfg.Name == "" || cfg.DockerImage == "" {
 		return nil, fmt.Errorf("%w: empty language or docker image", ErrInvalidLanguageCreation)
 	}
-	if (maxTimeoutMs != nil && *maxTimeoutMs < 0) || (maxCodeSize != nil && *maxCodeSize < 0) {
+	if cfg.SourceFile == "" || len(cfg.RunCommand) == 0 {
+		return nil, fmt.Errorf("%w: empty source file or run command", ErrInvalidLanguageCreation)
+	}
+	if (cfg.MaxTimeoutMs != nil && *cfg.MaxTimeoutMs < 0) || (cfg.MaxCodeSize != nil && *cfg.MaxCodeSize < 0) {
 		return nil, fmt.Errorf("%w: invalid max Code size or max timeout", ErrInvalidLanguageCreation)
 	}
+	if (cfg.MaxMemoryBytes != nil && *cfg.MaxMemoryBytes <= 0) || (cfg.MaxPids != nil && *cfg.MaxPids <= 0) || (cfg.MaxCPUMillis != nil && *cfg.MaxCPUMillis <= 0) {
+		return nil, fmt.Errorf("%w: invalid memory, pids or cpu limit", ErrInvalidLanguageCreation)
+	}
+	if cfg.MaxOutputBytes != nil && *cfg.MaxOutputBytes <= 0 {
+		return nil, fmt.Errorf("%w: invalid max output size", ErrInvalidLanguageCreation)
+	}
+	if len(cfg.CompileCommand) > 0 && cfg.CompileTimeoutMs <= 0 {
+		return nil, fmt.Errorf("%w: compiled language needs a compile timeout", ErrInvalidLanguageCreation)
+	}
+	if cfg.MaxAttempts < 0 {
+		return nil, fmt.Errorf("%w: invalid max attempts", ErrInvalidLanguageCreation)
+	}
+
+	maxAttempts := cfg.MaxAttempts
+	if maxAttempts == 0 {
+		maxAttempts = defaultMaxAttempts
+	}
 
 	language := &Language{
-		Name:         languageName,
-		DockerImage:  dockerImage,
-		MaxTimeoutMs: maxTimeoutMs,
-		MaxCodeSize:  maxCodeSize,
+		Name:         cfg.Name,
+		DockerImage:  cfg.DockerImage,
+		SourceFile:   cfg.SourceFile,
+		RunCommand:   append([]string(nil), cfg.RunCommand...),
+		MaxTimeoutMs: cfg.MaxTimeoutMs,
+
+		CompileCommand:   append([]string(nil), cfg.CompileCommand...),
+		CompileTimeoutMs: cfg.CompileTimeoutMs,
+
+		MaxCodeSize:    cfg.MaxCodeSize,
+		MaxMemoryBytes: cfg.MaxMemoryBytes,
+		MaxPids:        cfg.MaxPids,
+		MaxCPUMillis:   cfg.MaxCPUMillis,
+		MaxOutputBytes: cfg.MaxOutputBytes,
+
+		SeccompProfile:  cfg.SeccompProfile,
+		SeccompSyscalls: append([]string(nil), cfg.SeccompSyscalls...),
+
+		MaxAttempts: maxAttempts,
 	}
 
 	return language, nil
@@ -61,6 +234,38 @@ func mustLanguage(lang *Language, err error) *Language {
 	return lang
 }
 
+func (l *Language) Compiled() bool {
+	return len(l.CompileCommand) > 0
+}
+
+// Limits are the language's maximum sandbox limits, which are also the
+// defaults for new executions and the limits of the compile phase.
+func (l *Language) Limits() ResourceLimits {
+	var limits ResourceLimits
+
+	if l.MaxMemoryBytes != nil {
+		limits.MemoryBytes = *l.MaxMemoryBytes
+	}
+	if l.MaxPids != nil {
+		limits.Pids = *l.MaxPids
+	}
+	if l.MaxCPUMillis != nil {
+		limits.CPUMillis = *l.MaxCPUMillis
+	}
+
+	return limits
+}
+
+// Command expands the {entrypoint} placeholder of a language command.
+func (l *Language) Command(argv []string, entrypoint string) []string {
+	expanded := make([]string, len(argv))
+	for i, arg := range argv {
+		expanded[i] = strings.ReplaceAll(arg, entrypointPlaceholder, entrypoint)
+	}
+
+	return expanded
+}
+
 func GetLanguage(name string) (*Language, bool) {
 	lang, ok := languages[name]
 	if !ok {
@@ -69,3 +274,14 @@ func GetLanguage(name string) (*Language, bool) {
 
 	return lang, true
 }
+
+// LanguageNames lists the supported languages, sorted.
+func LanguageNames() []string {
+	names := make([]string, 0, len(languages))
+	for name := range languages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,68 @@
+package domain
+
+import "fmt"
+
+// ResourceLimits are the per-execution sandbox limits. A zero value means
+// "no limit" for that resource.
+type ResourceLimits struct {
+	MemoryBytes int64
+	Pids        int
+	CPUMillis   int // 1000 == one full CPU
+}
+
+type ResourceViolation string
+
+const (
+	ResourceViolationMemory ResourceViolation = "memory_limit"
+	ResourceViolationPids   ResourceViolation = "pids_limit"
+)
+
+// RequestLimits lowers the execution's limits to the requested values. Zero
+// fields keep the language default; values above the language maximum are
+// rejected.
+func (e *Execution) RequestLimits(requested ResourceLimits) error {
+	if requested.MemoryBytes < 0 || requested.Pids < 0 || requested.CPUMillis < 0 {
+		return fmt.Errorf("%w: resource limits must not be negative", ErrInvalidExecution)
+	}
+
+	if requested.MemoryBytes > 0 {
+		if e.Limits.MemoryBytes > 0 && requested.MemoryBytes > e.Limits.MemoryBytes {
+			return fmt.Errorf("%w: memory limit exceeds max limit for %s", ErrInvalidExecution, e.Language)
+		}
+		e.Limits.MemoryBytes = requested.MemoryBytes
+	}
+
+	if requested.Pids > 0 {
+		if e.Limits.Pids > 0 && requested.Pids > e.Limits.Pids {
+			return fmt.Errorf("%w: pids limit exceeds max limit for %s", ErrInvalidExecution, e.Language)
+		}
+		e.Limits.Pids = requested.Pids
+	}
+
+	if requested.CPUMillis > 0 {
+		if e.Limits.CPUMillis > 0 && requested.CPUMillis > e.Limits.CPUMillis {
+			return fmt.Errorf("%w: cpu limit exceeds max limit for %s", ErrInvalidExecution, e.Language)
+		}
+		e.Limits.CPUMillis = requested.CPUMillis
+	}
+
+	return nil
+}
+
+func (e *Execution) RecordViolations(violations ...ResourceViolation) {
+	for _, v := range violations {
+		if !e.HasViolation(v) {
+			e.Violations = append(e.Violations, v)
+		}
+	}
+}
+
+func (e *Execution) HasViolation(v ResourceViolation) bool {
+	for _, existing := range e.Violations {
+		if existing == v {
+			return true
+		}
+	}
+
+	return false
+}
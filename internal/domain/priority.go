@@ -0,0 +1,19 @@
+package domain
+
+import "fmt"
+
+// Queued executions with a higher priority are run first; the default, 0, is
+// the lowest.
+const (
+	MinPriority = 0
+	MaxPriority = 9
+)
+
+func (e *Execution) SetPriority(priority int) error {
+	if priority < MinPriority || priority > MaxPriority {
+		return fmt.Errorf("%w: priority must be between %d and %d", ErrInvalidExecution, MinPriority, MaxPriority)
+	}
+
+	e.Priority = priority
+	return nil
+}
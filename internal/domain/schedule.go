@@ -0,0 +1,30 @@
+package domain
+
+import (
+	"fmt"
+	"time"
+)
+
+// Schedule defers a new execution until runAt; MarkQueued queues it then.
+func (e *Execution) Schedule(runAt time.Time) error {
+	if runAt.IsZero() {
+		return fmt.Errorf("%w: run at time is zero", ErrInvalidExecution)
+	}
+
+	if e.Status != ExecutionStatusQueued || e.Attempts > 0 || e.StartedAt != nil {
+		return fmt.Errorf("%w: only new executions can be scheduled", ErrInvalidStatusTransition)
+	}
+
+	e.Status = ExecutionStatusScheduled
+	e.RunAt = timePtr(runAt)
+	return nil
+}
+
+// MarkQueued queues a scheduled execution that is due.
+func (e *Execution) MarkQueued() error {
+	if e.Status != ExecutionStatusScheduled {
+		return fmt.Errorf("%w: %s -> %s not allowed", ErrInvalidStatusTransition, e.Status, ExecutionStatusQueued)
+	}
+
+	return e.transition(ExecutionStatusQueued)
+}
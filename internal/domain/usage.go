@@ -0,0 +1,54 @@
+package domain
+
+import "time"
+
+// ResourceUsage is what the program's run phases cost, as far as the runner
+// can measure it. Zero means "not measured" for every field but WallTimeMs.
+type ResourceUsage struct {
+	WallTimeMs      int64
+	UserCPUTimeMs   int64
+	SystemCPUTimeMs int64
+	PeakMemoryBytes int64
+	IOReadBytes     int64
+	IOWriteBytes    int64
+}
+
+func (u ResourceUsage) CPUTimeMs() int64 {
+	return u.UserCPUTimeMs + u.SystemCPUTimeMs
+}
+
+// Add accumulates another run: times and I/O add up, peak memory is the
+// maximum.
+func (u ResourceUsage) Add(other ResourceUsage) ResourceUsage {
+	u.WallTimeMs += other.WallTimeMs
+	u.UserCPUTimeMs += other.UserCPUTimeMs
+	u.SystemCPUTimeMs += other.SystemCPUTimeMs
+	u.IOReadBytes += other.IOReadBytes
+	u.IOWriteBytes += other.IOWriteBytes
+	if other.PeakMemoryBytes > u.PeakMemoryBytes {
+		u.PeakMemoryBytes = other.PeakMemoryBytes
+	}
+
+	return u
+}
+
+// RecordUsage adds the usage of one run phase; judged executions record one
+// per test case.
+func (e *Execution) RecordUsage(usage ResourceUsage) {
+	e.Usage = e.Usage.Add(usage)
+}
+
+// QueueWait is how long the execution waited for a worker, or zero if it has
+// not started yet.
+func (e *Execution) QueueWait() time.Duration {
+	if e.StartedAt == nil {
+		return 0
+	}
+
+	queuedAt := e.CreatedAt
+	if e.RunAt != nil && e.RunAt.After(queuedAt) {
+		queuedAt = *e.RunAt
+	}
+
+	return e.StartedAt.Sub(queuedAt)
+}
@@ -0,0 +1,105 @@
+package http
+
+import (
+	"Code_executor/internal/queue"
+	"Code_executor/internal/service"
+	"crypto/subtle"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// AdminHandler serves operator endpoints; every request must carry the admin
+// token as a bearer token.
+type AdminHandler struct {
+	service service.ExecutionService
+	token   string
+}
+
+type deadLetterResponse struct {
+	ExecutionID string    `json:"execution_id"`
+	Language    string    `json:"language"`
+	UserID      string    `json:"user_id"`
+	Attempts    int       `json:"attempts"`
+	Reason      string    `json:"reason"`
+	FailedAt    time.Time `json:"failed_at"`
+}
+
+func NewAdminHandler(s service.ExecutionService, token string) (*AdminHandler, error) {
+	if s == nil {
+		return nil, fmt.Errorf("%w: service is nil", ErrInvalidArgument)
+	}
+
+	if token == "" {
+		return nil, fmt.Errorf("%w: admin token is required", ErrInvalidArgument)
+	}
+
+	return &AdminHandler{
+		service: s,
+		token:   token,
+	}, nil
+}
+
+func (h *AdminHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(h.requireToken)
+		r.Get("/dead-letters", h.handleListDeadLetters)
+		r.Post("/dead-letters/{executionID}/redrive", h.handleRedrive)
+	})
+}
+
+func (h *AdminHandler) requireToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "unauthorized")
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func newDeadLetterResponses(letters []queue.DeadLetter) []deadLetterResponse {
+	responses := make([]deadLetterResponse, 0, len(letters))
+	for _, letter := range letters {
+		responses = append(responses, deadLetterResponse{
+			ExecutionID: letter.ExecutionID,
+			Language:    letter.Language,
+			UserID:      letter.UserID,
+			Attempts:    letter.Attempts,
+			Reason:      letter.Reason,
+			FailedAt:    letter.FailedAt.UTC(),
+		})
+	}
+
+	return responses
+}
+
+func (h *AdminHandler) handleListDeadLetters(w http.ResponseWriter, r *http.Request) {
+	letters, err := h.service.ListDeadLetters(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newDeadLetterResponses(letters))
+}
+
+func (h *AdminHandler) handleRedrive(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "executionID")
+	if executionID == "" {
+		writeServiceError(w, fmt.Errorf("%w: executionID is required", ErrInvalidArgument))
+		return
+	}
+
+	exec, err := h.service.RedriveExecution(r.Context(), executionID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newExecutionResponse(exec))
+}
@@ -2,6 +2,7 @@ package http
 
 import (
 	"Code_executor/internal/domain"
+	"Code_executor/internal/queue"
 	"Code_executor/internal/repository"
 	"Code_executor/internal/service"
 	"encoding/json"
@@ -9,6 +10,7 @@ import (
 	"fmt"
 	"github.com/go-chi/chi/v5"
 	"net/http"
+	"strconv"
 	"time"
 )
 
@@ -21,25 +23,104 @@ type ExecutionHandler struct {
 }
 
 type createExecutionRequest struct {
-	Language  string `json:"language"`
-	Code      string `json:"code"`
-	TimeoutMs int    `json:"timeout_ms"`
-	Stdin     string `json:"stdin"`
-	UserName  string `json:"user_name"`
+	Language         string            `json:"language"`
+	Code             string            `json:"code"`
+	Files            map[string]string `json:"files"`
+	Entrypoint       string            `json:"entrypoint"`
+	TimeoutMs        int               `json:"timeout_ms"`
+	Stdin            string            `json:"stdin"`
+	UserName         string            `json:"user_name"`
+	Priority         int               `json:"priority"`
+	RunAt            *time.Time        `json:"run_at"`
+	DelayMs          int64             `json:"delay_ms"`
+	MemoryLimitBytes int64             `json:"memory_limit_bytes"`
+	PidsLimit        int               `json:"pids_limit"`
+	CPULimitMillis   int               `json:"cpu_limit_millis"`
+	TestCases        []testCaseRequest `json:"test_cases"`
+	Checker          *checkerRequest   `json:"checker"`
+}
+
+// checkerRequest selects the comparator of the test cases; a custom checker
+// program is given like a submission, by code or files.
+type checkerRequest struct {
+	Kind         domain.CheckerKind `json:"kind"`
+	AbsTolerance float64            `json:"abs_tolerance"`
+	RelTolerance float64            `json:"rel_tolerance"`
+	Language     string             `json:"language"`
+	Code         string             `json:"code"`
+	Files        map[string]string  `json:"files"`
+	Entrypoint   string             `json:"entrypoint"`
+}
+
+type testCaseRequest struct {
+	Stdin          string `json:"stdin"`
+	ExpectedStdout string `json:"expected_stdout"`
+	TimeoutMs      int    `json:"timeout_ms"`
+}
+
+type testCaseResultResponse struct {
+	Verdict    domain.Verdict `json:"verdict"`
+	Stdout     string         `json:"stdout"`
+	Stderr     string         `json:"stderr"`
+	ExitCode   *int           `json:"exit_code"`
+	DurationMs int64          `json:"duration_ms"`
+}
+
+type resourceLimitsResponse struct {
+	MemoryBytes int64 `json:"memory_bytes"`
+	Pids        int   `json:"pids"`
+	CPUMillis   int   `json:"cpu_millis"`
+}
+
+type resourceUsageResponse struct {
+	WallTimeMs      int64 `json:"wall_time_ms"`
+	CPUTimeMs       int64 `json:"cpu_time_ms"`
+	UserCPUTimeMs   int64 `json:"user_cpu_time_ms"`
+	SystemCPUTimeMs int64 `json:"system_cpu_time_ms"`
+	PeakMemoryBytes int64 `json:"peak_memory_bytes"`
+	IOReadBytes     int64 `json:"io_read_bytes"`
+	IOWriteBytes    int64 `json:"io_write_bytes"`
 }
 
 type executionResponse struct {
-	ID         string                 `json:"id"`
-	Language   string                 `json:"language"`
-	Status     domain.ExecutionStatus `json:"status"`
-	Stdout     string                 `json:"stdout"`
-	Stderr     string                 `json:"stderr"`
-	ExitCode   *int                   `json:"exit_code"`
-	TimeoutMs  int                    `json:"timeout_ms"`
-	CreatedAt  time.Time              `json:"created_at"`
-	StartedAt  *time.Time             `json:"started_at,omitempty"`
-	FinishedAt *time.Time             `json:"finished_at,omitempty"`
-	UserID     string                 `json:"user_id"`
+	ID              string                 `json:"id"`
+	Language        string                 `json:"language"`
+	Entrypoint      string                 `json:"entrypoint"`
+	Status          domain.ExecutionStatus `json:"status"`
+	Stdout          string                 `json:"stdout"`
+	Stderr          string                 `json:"stderr"`
+	StdoutTruncated bool                   `json:"stdout_truncated"`
+	StderrTruncated bool                   `json:"stderr_truncated"`
+	ExitCode        *int                   `json:"exit_code"`
+	TimeoutMs       int                    `json:"timeout_ms"`
+	CreatedAt       time.Time              `json:"created_at"`
+	RunAt           *time.Time             `json:"run_at,omitempty"`
+	StartedAt       *time.Time             `json:"started_at,omitempty"`
+	FinishedAt      *time.Time             `json:"finished_at,omitempty"`
+	UserID          string                 `json:"user_id"`
+	ScheduleID      string                 `json:"schedule_id,omitempty"`
+	Priority        int                    `json:"priority"`
+	Limits          resourceLimitsResponse `json:"limits"`
+	Violations      []string               `json:"violations"`
+	Usage           resourceUsageResponse  `json:"usage"`
+	QueueWaitMs     *int64                 `json:"queue_wait_ms,omitempty"`
+	Attempts        int                    `json:"attempts"`
+
+	CompileStdout   string `json:"compile_stdout,omitempty"`
+	CompileStderr   string `json:"compile_stderr,omitempty"`
+	CompileExitCode *int   `json:"compile_exit_code,omitempty"`
+
+	FailureReason domain.FailureReason `json:"failure_reason,omitempty"`
+	FailureDetail string               `json:"failure_detail,omitempty"`
+
+	Checker     domain.CheckerKind       `json:"checker,omitempty"`
+	Verdict     domain.Verdict           `json:"verdict,omitempty"`
+	TestResults []testCaseResultResponse `json:"test_results,omitempty"`
+}
+
+type executionPageResponse struct {
+	Executions []executionResponse `json:"executions"`
+	NextCursor string              `json:"next_cursor,omitempty"`
 }
 
 func NewExecutionHandler(s service.ExecutionService) (*ExecutionHandler, error) {
@@ -54,7 +135,10 @@ func NewExecutionHandler(s service.ExecutionService) (*ExecutionHandler, error)
 
 func (h *ExecutionHandler) RegisterRoutes(r chi.Router) {
 	r.Post("/executions", h.handleCreateExecution)
+	r.Get("/executions", h.handleListExecutions)
 	r.Get("/executions/{executionID}", h.handleGetExecution)
+	r.Delete("/executions/{executionID}", h.handleCancelExecution)
+	r.Get("/languages", h.handleListLanguages)
 }
 
 func newExecutionResponse(exec *domain.Execution) executionResponse {
@@ -63,18 +147,89 @@ func newExecutionResponse(exec *domain.Execution) executionResponse {
 	}
 
 	return executionResponse{
-		ID:         exec.ID,
-		Language:   exec.Language,
-		Status:     exec.Status,
-		Stdout:     exec.Stdout,
-		Stderr:     exec.Stderr,
-		ExitCode:   exec.ExitCode,
-		TimeoutMs:  exec.TimeoutMs,
-		CreatedAt:  exec.CreatedAt.UTC(),
-		StartedAt:  normalizeTimePtr(exec.StartedAt),
-		FinishedAt: normalizeTimePtr(exec.FinishedAt),
-		UserID:     exec.UserID,
+		ID:              exec.ID,
+		Language:        exec.Language,
+		Entrypoint:      exec.Entrypoint,
+		Status:          exec.Status,
+		Stdout:          exec.Stdout,
+		Stderr:          exec.Stderr,
+		StdoutTruncated: exec.StdoutTruncated,
+		StderrTruncated: exec.StderrTruncated,
+		ExitCode:        exec.ExitCode,
+		TimeoutMs:       exec.TimeoutMs,
+		CreatedAt:       exec.CreatedAt.UTC(),
+		RunAt:           normalizeTimePtr(exec.RunAt),
+		StartedAt:       normalizeTimePtr(exec.StartedAt),
+		FinishedAt:      normalizeTimePtr(exec.FinishedAt),
+		UserID:          exec.UserID,
+		ScheduleID:      exec.ScheduleID,
+		Priority:        exec.Priority,
+		Limits: resourceLimitsResponse{
+			MemoryBytes: exec.Limits.MemoryBytes,
+			Pids:        exec.Limits.Pids,
+			CPUMillis:   exec.Limits.CPUMillis,
+		},
+		Violations: violationNames(exec.Violations),
+		Usage: resourceUsageResponse{
+			WallTimeMs:      exec.Usage.WallTimeMs,
+			CPUTimeMs:       exec.Usage.CPUTimeMs(),
+			UserCPUTimeMs:   exec.Usage.UserCPUTimeMs,
+			SystemCPUTimeMs: exec.Usage.SystemCPUTimeMs,
+			PeakMemoryBytes: exec.Usage.PeakMemoryBytes,
+			IOReadBytes:     exec.Usage.IOReadBytes,
+			IOWriteBytes:    exec.Usage.IOWriteBytes,
+		},
+		QueueWaitMs: queueWaitMs(exec),
+		Attempts:    exec.Attempts,
+
+		CompileStdout:   exec.CompileStdout,
+		CompileStderr:   exec.CompileStderr,
+		CompileExitCode: exec.CompileExitCode,
+
+		FailureReason: exec.FailureReason,
+		FailureDetail: exec.FailureDetail,
+
+		Checker:     exec.Checker.Kind,
+		Verdict:     exec.Verdict,
+		TestResults: newTestCaseResultResponses(exec.TestResults),
+	}
+}
+
+func newTestCaseResultResponses(results []domain.TestCaseResult) []testCaseResultResponse {
+	if len(results) == 0 {
+		return nil
+	}
+
+	responses := make([]testCaseResultResponse, 0, len(results))
+	for _, result := range results {
+		responses = append(responses, testCaseResultResponse{
+			Verdict:    result.Verdict,
+			Stdout:     result.Stdout,
+			Stderr:     result.Stderr,
+			ExitCode:   result.ExitCode,
+			DurationMs: result.DurationMs,
+		})
+	}
+
+	return responses
+}
+
+func violationNames(violations []domain.ResourceViolation) []string {
+	names := make([]string, 0, len(violations))
+	for _, v := range violations {
+		names = append(names, string(v))
+	}
+
+	return names
+}
+
+func queueWaitMs(exec *domain.Execution) *int64 {
+	if exec.StartedAt == nil {
+		return nil
 	}
+
+	ms := exec.QueueWait().Milliseconds()
+	return &ms
 }
 
 func normalizeTimePtr(t *time.Time) *time.Time {
@@ -100,11 +255,25 @@ func (h *ExecutionHandler) handleCreateExecution(w http.ResponseWriter, r *http.
 	}
 
 	params := service.CreateExecutionParams{
-		Language:  req.Language,
-		Code:      req.Code,
-		Stdin:     req.Stdin,
-		TimeoutMs: req.TimeoutMs,
-		UserID:    req.UserName,
+		Language:   req.Language,
+		Code:       req.Code,
+		Files:      req.Files,
+		Entrypoint: req.Entrypoint,
+		Stdin:      req.Stdin,
+		TimeoutMs:  req.TimeoutMs,
+		UserID:     req.UserName,
+		Priority:   req.Priority,
+		Delay:      time.Duration(req.DelayMs) * time.Millisecond,
+		Limits: domain.ResourceLimits{
+			MemoryBytes: req.MemoryLimitBytes,
+			Pids:        req.PidsLimit,
+			CPUMillis:   req.CPULimitMillis,
+		},
+		TestCases: newTestCases(req.TestCases),
+		Checker:   newChecker(req.Checker),
+	}
+	if req.RunAt != nil {
+		params.RunAt = *req.RunAt
 	}
 
 	exec, err := h.service.CreateExecutionAndEnqueue(r.Context(), params)
@@ -116,6 +285,41 @@ func (h *ExecutionHandler) handleCreateExecution(w http.ResponseWriter, r *http.
 	writeJSON(w, http.StatusCreated, newExecutionResponse(exec))
 }
 
+func newTestCases(reqs []testCaseRequest) []domain.TestCase {
+	cases := make([]domain.TestCase, 0, len(reqs))
+	for _, req := range reqs {
+		cases = append(cases, domain.TestCase{
+			Stdin:          req.Stdin,
+			ExpectedStdout: req.ExpectedStdout,
+			TimeoutMs:      req.TimeoutMs,
+		})
+	}
+
+	return cases
+}
+
+func newChecker(req *checkerRequest) domain.Checker {
+	if req == nil {
+		return domain.Checker{}
+	}
+
+	files := req.Files
+	if req.Code != "" {
+		if lang, ok := domain.GetLanguage(req.Language); ok {
+			files = map[string]string{lang.SourceFile: req.Code}
+		}
+	}
+
+	return domain.Checker{
+		Kind:         req.Kind,
+		AbsTolerance: req.AbsTolerance,
+		RelTolerance: req.RelTolerance,
+		Language:     req.Language,
+		Files:        files,
+		Entrypoint:   req.Entrypoint,
+	}
+}
+
 func (h *ExecutionHandler) handleGetExecution(w http.ResponseWriter, r *http.Request) {
 	executionID := chi.URLParam(r, "executionID")
 	if executionID == "" {
@@ -132,13 +336,68 @@ func (h *ExecutionHandler) handleGetExecution(w http.ResponseWriter, r *http.Req
 	writeJSON(w, http.StatusOK, newExecutionResponse(exec))
 }
 
+// handleListExecutions lists the latest executions, filtered by the user_name
+// and status query parameters, a page of limit at a time. The cursor
+// parameter continues after the page that returned it as next_cursor.
+func (h *ExecutionHandler) handleListExecutions(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			writeServiceError(w, fmt.Errorf("%w: limit must be a number", ErrInvalidArgument))
+			return
+		}
+	}
+
+	page, err := h.service.ListExecutions(r.Context(), service.ListExecutionsParams{
+		UserID: query.Get("user_name"),
+		Status: domain.ExecutionStatus(query.Get("status")),
+		Limit:  limit,
+		Cursor: query.Get("cursor"),
+	})
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	responses := make([]executionResponse, 0, len(page.Executions))
+	for _, exec := range page.Executions {
+		responses = append(responses, newExecutionResponse(exec))
+	}
+
+	writeJSON(w, http.StatusOK, executionPageResponse{Executions: responses, NextCursor: page.NextCursor})
+}
+
+func (h *ExecutionHandler) handleCancelExecution(w http.ResponseWriter, r *http.Request) {
+	executionID := chi.URLParam(r, "executionID")
+	if executionID == "" {
+		writeServiceError(w, fmt.Errorf("%w: executionID is required", ErrInvalidArgument))
+		return
+	}
+
+	exec, err := h.service.CancelExecution(r.Context(), executionID)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newExecutionResponse(exec))
+}
+
 func validateCreateExecutionRequest(req createExecutionRequest) error {
 	if req.Language == "" {
 		return fmt.Errorf("%w: language is required", ErrInvalidArgument)
 	}
 
-	if req.Code == "" {
-		return fmt.Errorf("%w: code is required", ErrInvalidArgument)
+	if req.Code == "" && len(req.Files) == 0 {
+		return fmt.Errorf("%w: code or files is required", ErrInvalidArgument)
+	}
+
+	if req.Code != "" && len(req.Files) > 0 {
+		return fmt.Errorf("%w: code and files are mutually exclusive", ErrInvalidArgument)
 	}
 
 	if req.TimeoutMs <= 0 {
@@ -149,6 +408,26 @@ func validateCreateExecutionRequest(req createExecutionRequest) error {
 		return fmt.Errorf("%w: user_name is required", ErrInvalidArgument)
 	}
 
+	if req.RunAt != nil && req.DelayMs != 0 {
+		return fmt.Errorf("%w: run_at and delay_ms are mutually exclusive", ErrInvalidArgument)
+	}
+
+	if req.DelayMs < 0 {
+		return fmt.Errorf("%w: delay_ms must not be negative", ErrInvalidArgument)
+	}
+
+	if req.MemoryLimitBytes < 0 || req.PidsLimit < 0 || req.CPULimitMillis < 0 {
+		return fmt.Errorf("%w: resource limits must not be negative", ErrInvalidArgument)
+	}
+
+	if len(req.TestCases) > 0 && req.Stdin != "" {
+		return fmt.Errorf("%w: stdin and test_cases are mutually exclusive", ErrInvalidArgument)
+	}
+
+	if req.Checker != nil && req.Checker.Code != "" && len(req.Checker.Files) > 0 {
+		return fmt.Errorf("%w: checker code and files are mutually exclusive", ErrInvalidArgument)
+	}
+
 	return nil
 }
 
@@ -186,9 +465,39 @@ func writeServiceError(w http.ResponseWriter, err error) {
 	case errors.Is(err, domain.ErrInvalidExecution):
 		status = http.StatusBadRequest
 		message = err.Error()
+	case errors.Is(err, service.ErrInvalidScheduleInput):
+		status = http.StatusBadRequest
+		message = err.Error()
+	case errors.Is(err, domain.ErrInvalidSchedule):
+		status = http.StatusBadRequest
+		message = err.Error()
+	case errors.Is(err, service.ErrPriorityNotAllowed):
+		status = http.StatusForbidden
+		message = err.Error()
+	case errors.Is(err, domain.ErrInvalidStatusTransition):
+		status = http.StatusConflict
+		message = err.Error()
+	case errors.Is(err, repository.ErrExecutionFinished):
+		status = http.StatusConflict
+		message = err.Error()
 	case errors.Is(err, repository.ErrExecutionNotFound):
 		status = http.StatusNotFound
 		message = err.Error()
+	case errors.Is(err, repository.ErrScheduleNotFound):
+		status = http.StatusNotFound
+		message = err.Error()
+	case errors.Is(err, queue.ErrDeadLetterNotFound):
+		status = http.StatusNotFound
+		message = err.Error()
+	case errors.Is(err, service.ErrNoDeadLetters):
+		status = http.StatusServiceUnavailable
+		message = err.Error()
+	case errors.Is(err, service.ErrNoDelayedQueue):
+		status = http.StatusServiceUnavailable
+		message = err.Error()
+	case errors.Is(err, service.ErrNoWorkerRegistry):
+		status = http.StatusServiceUnavailable
+		message = err.Error()
 	}
 
 	writeError(w, status, message)
@@ -0,0 +1,45 @@
+package http
+
+import (
+	"Code_executor/internal/service"
+	"net/http"
+	"time"
+)
+
+type languageResponse struct {
+	Language string `json:"language"`
+	// Available is false when no worker taking the language sent a
+	// heartbeat recently, so its executions stay queued.
+	Available     bool       `json:"available"`
+	Workers       int        `json:"workers"`
+	LastHeartbeat *time.Time `json:"last_heartbeat,omitempty"`
+}
+
+func newLanguageResponses(statuses []service.LanguageStatus) []languageResponse {
+	responses := make([]languageResponse, 0, len(statuses))
+	for _, status := range statuses {
+		response := languageResponse{
+			Language:  status.Language,
+			Available: status.Workers > 0,
+			Workers:   status.Workers,
+		}
+		if !status.LastHeartbeat.IsZero() {
+			lastHeartbeat := status.LastHeartbeat.UTC()
+			response.LastHeartbeat = &lastHeartbeat
+		}
+
+		responses = append(responses, response)
+	}
+
+	return responses
+}
+
+func (h *ExecutionHandler) handleListLanguages(w http.ResponseWriter, r *http.Request) {
+	statuses, err := h.service.ListLanguages(r.Context())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newLanguageResponses(statuses))
+}
@@ -0,0 +1,210 @@
+package http
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/service"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type ScheduleHandler struct {
+	service service.ScheduleService
+}
+
+type scheduleRequest struct {
+	Language        string                 `json:"language"`
+	Code            string                 `json:"code"`
+	Stdin           string                 `json:"stdin"`
+	TimeoutMs       int                    `json:"timeout_ms"`
+	UserName        string                 `json:"user_name"`
+	Priority        int                    `json:"priority"`
+	Cron            string                 `json:"cron"`
+	MissedRunPolicy domain.MissedRunPolicy `json:"missed_run_policy"`
+	// Enabled defaults to true.
+	Enabled *bool `json:"enabled"`
+}
+
+type scheduleResponse struct {
+	ID              string                 `json:"id"`
+	UserID          string                 `json:"user_id"`
+	Language        string                 `json:"language"`
+	Code            string                 `json:"code"`
+	Stdin           string                 `json:"stdin"`
+	TimeoutMs       int                    `json:"timeout_ms"`
+	Priority        int                    `json:"priority"`
+	Cron            string                 `json:"cron"`
+	MissedRunPolicy domain.MissedRunPolicy `json:"missed_run_policy"`
+	Enabled         bool                   `json:"enabled"`
+	NextRunAt       time.Time              `json:"next_run_at"`
+	LastRunAt       *time.Time             `json:"last_run_at,omitempty"`
+	CreatedAt       time.Time              `json:"created_at"`
+	UpdatedAt       time.Time              `json:"updated_at"`
+}
+
+func NewScheduleHandler(s service.ScheduleService) (*ScheduleHandler, error) {
+	if s == nil {
+		return nil, fmt.Errorf("%w: service is nil", ErrInvalidArgument)
+	}
+
+	return &ScheduleHandler{
+		service: s,
+	}, nil
+}
+
+func (h *ScheduleHandler) RegisterRoutes(r chi.Router) {
+	r.Route("/schedules", func(r chi.Router) {
+		r.Post("/", h.handleCreateSchedule)
+		r.Get("/", h.handleListSchedules)
+		r.Get("/{scheduleID}", h.handleGetSchedule)
+		r.Put("/{scheduleID}", h.handleUpdateSchedule)
+		r.Delete("/{scheduleID}", h.handleDeleteSchedule)
+		r.Get("/{scheduleID}/executions", h.handleListScheduleExecutions)
+	})
+}
+
+func newScheduleResponse(schedule *domain.Schedule) scheduleResponse {
+	return scheduleResponse{
+		ID:              schedule.ID,
+		UserID:          schedule.UserID,
+		Language:        schedule.Language,
+		Code:            schedule.Code,
+		Stdin:           schedule.Stdin,
+		TimeoutMs:       schedule.TimeoutMs,
+		Priority:        schedule.Priority,
+		Cron:            schedule.Cron,
+		MissedRunPolicy: schedule.MissedRunPolicy,
+		Enabled:         schedule.Enabled,
+		NextRunAt:       schedule.NextRunAt.UTC(),
+		LastRunAt:       normalizeTimePtr(schedule.LastRunAt),
+		CreatedAt:       schedule.CreatedAt.UTC(),
+		UpdatedAt:       schedule.UpdatedAt.UTC(),
+	}
+}
+
+func newScheduleResponses(schedules []*domain.Schedule) []scheduleResponse {
+	responses := make([]scheduleResponse, 0, len(schedules))
+	for _, schedule := range schedules {
+		responses = append(responses, newScheduleResponse(schedule))
+	}
+
+	return responses
+}
+
+func (req scheduleRequest) definition() domain.ScheduleDefinition {
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	return domain.ScheduleDefinition{
+		Language:        req.Language,
+		Code:            req.Code,
+		Stdin:           req.Stdin,
+		TimeoutMs:       req.TimeoutMs,
+		Priority:        req.Priority,
+		Cron:            req.Cron,
+		MissedRunPolicy: req.MissedRunPolicy,
+		Enabled:         enabled,
+	}
+}
+
+func (h *ScheduleHandler) handleCreateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req scheduleRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	if req.UserName == "" {
+		writeServiceError(w, fmt.Errorf("%w: user_name is required", ErrInvalidArgument))
+		return
+	}
+
+	schedule, err := h.service.CreateSchedule(r.Context(), req.UserName, req.definition())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newScheduleResponse(schedule))
+}
+
+// handleListSchedules lists the schedules of the user_name query parameter,
+// or of all users without it.
+func (h *ScheduleHandler) handleListSchedules(w http.ResponseWriter, r *http.Request) {
+	schedules, err := h.service.ListSchedules(r.Context(), r.URL.Query().Get("user_name"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newScheduleResponses(schedules))
+}
+
+func (h *ScheduleHandler) handleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	schedule, err := h.service.GetSchedule(r.Context(), chi.URLParam(r, "scheduleID"))
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newScheduleResponse(schedule))
+}
+
+// handleUpdateSchedule replaces the definition of a schedule; its owner
+// cannot be changed.
+func (h *ScheduleHandler) handleUpdateSchedule(w http.ResponseWriter, r *http.Request) {
+	var req scheduleRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request payload")
+		return
+	}
+
+	schedule, err := h.service.UpdateSchedule(r.Context(), chi.URLParam(r, "scheduleID"), req.definition())
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, newScheduleResponse(schedule))
+}
+
+func (h *ScheduleHandler) handleDeleteSchedule(w http.ResponseWriter, r *http.Request) {
+	if err := h.service.DeleteSchedule(r.Context(), chi.URLParam(r, "scheduleID")); err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *ScheduleHandler) handleListScheduleExecutions(w http.ResponseWriter, r *http.Request) {
+	limit := 0
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil {
+			writeServiceError(w, fmt.Errorf("%w: limit must be a number", ErrInvalidArgument))
+			return
+		}
+	}
+
+	execs, err := h.service.ListScheduleExecutions(r.Context(), chi.URLParam(r, "scheduleID"), limit)
+	if err != nil {
+		writeServiceError(w, err)
+		return
+	}
+
+	responses := make([]executionResponse, 0, len(execs))
+	for _, exec := range execs {
+		responses = append(responses, newExecutionResponse(exec))
+	}
+
+	writeJSON(w, http.StatusOK, responses)
+}
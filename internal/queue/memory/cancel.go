@@ -0,0 +1,55 @@
+package queuememory
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// CancelBus delivers cancellations to every listener of the same process.
+type CancelBus struct {
+	mu        sync.Mutex
+	listeners map[chan string]struct{}
+}
+
+func NewCancelBus() *CancelBus {
+	return &CancelBus{listeners: make(map[chan string]struct{})}
+}
+
+func (b *CancelBus) NotifyCancel(ctx context.Context, executionID string) error {
+	if executionID == "" {
+		return fmt.Errorf("execution id is empty")
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.listeners {
+		select {
+		case ch <- executionID:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+func (b *CancelBus) ListenCancels(ctx context.Context) (<-chan string, error) {
+	ch := make(chan string, 16)
+
+	b.mu.Lock()
+	b.listeners[ch] = struct{}{}
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+
+		b.mu.Lock()
+		delete(b.listeners, ch)
+		b.mu.Unlock()
+		close(ch)
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,81 @@
+package queuememory
+
+import (
+	"Code_executor/internal/queue"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DelayedQueue keeps scheduled jobs of the same process.
+type DelayedQueue struct {
+	mu   sync.Mutex
+	jobs map[string]delayedJob
+	next uint64
+}
+
+type delayedJob struct {
+	job queue.Job
+	// due is the run at time until the job is claimed, and then the end of
+	// its lease.
+	due time.Time
+}
+
+func NewDelayedQueue() *DelayedQueue {
+	return &DelayedQueue{jobs: make(map[string]delayedJob)}
+}
+
+func (q *DelayedQueue) Schedule(_ context.Context, job queue.Job, runAt time.Time) error {
+	if job.ExecutionID == "" {
+		return fmt.Errorf("execution id is empty")
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.next++
+	q.jobs[strconv.FormatUint(q.next, 10)] = delayedJob{job: job, due: runAt}
+	return nil
+}
+
+func (q *DelayedQueue) ClaimDue(_ context.Context, limit int, lease time.Duration) ([]queue.Delivery, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	var due []queue.Delivery
+	for receipt, delayed := range q.jobs {
+		if delayed.due.After(now) {
+			continue
+		}
+		due = append(due, queue.Delivery{Job: delayed.job, Receipt: receipt})
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return q.jobs[due[i].Receipt].due.Before(q.jobs[due[j].Receipt].due)
+	})
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	for _, d := range due {
+		q.jobs[d.Receipt] = delayedJob{job: d.Job, due: now.Add(lease)}
+	}
+
+	return due, nil
+}
+
+func (q *DelayedQueue) Remove(_ context.Context, d queue.Delivery) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if _, ok := q.jobs[d.Receipt]; !ok {
+		return ErrUnknownReceipt
+	}
+	delete(q.jobs, d.Receipt)
+
+	return nil
+}
@@ -6,21 +6,53 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
 )
 
+// InMemoryQueue tracks deliveries until they are acked like the other queues,
+// but has no visibility timeout: a delivery cannot outlive the process that
+// would redeliver it, so only Nack and Retry put it back.
 type InMemoryQueue struct {
-	ch chan queue.Job
+	// slots holds a token per pending job and bounds them to the buffer
+	// size.
+	slots chan struct{}
+
+	mu sync.Mutex
+	// ready is closed and replaced when a job is added, which wakes every
+	// consumer, whatever languages it takes.
+	ready    chan struct{}
+	pending  []pendingJob
+	inflight map[string]queue.Job
+	dead     map[string]queue.DeadLetter
+	next     uint64
+}
+
+type pendingJob struct {
+	job        queue.Job
+	enqueuedAt time.Time
 }
 
 var (
 	ErrInvalidMemoryBufferSize = errors.New("invalid memory buffer size")
+	ErrUnknownReceipt          = errors.New("unknown delivery receipt")
+
+	errNilQueue = errors.New("in-memory queue is nil")
 )
 
 func NewInMemoryQueue(buffer int) (*InMemoryQueue, error) {
 	if buffer <= 0 {
 		return nil, ErrInvalidMemoryBufferSize
 	}
-	return &InMemoryQueue{ch: make(chan queue.Job, buffer)}, nil
+	return &InMemoryQueue{
+		slots:    make(chan struct{}, buffer),
+		ready:    make(chan struct{}),
+		inflight: make(map[string]queue.Job),
+		dead:     make(map[string]queue.DeadLetter),
+	}, nil
 }
 
 func (q *InMemoryQueue) Enqueue(ctx context.Context, job queue.Job) error {
@@ -33,31 +65,204 @@ func (q *InMemoryQueue) Enqueue(ctx context.Context, job queue.Job) error {
 	case <-ctx.Done():
 		log.Printf("enqueue cancelled: %v", ctx.Err())
 		return ctx.Err()
-	case q.ch <- job:
-		return nil
+	case q.slots <- struct{}{}:
 	}
+
+	q.mu.Lock()
+	q.pending = append(q.pending, pendingJob{job: job, enqueuedAt: time.Now()})
+	close(q.ready)
+	q.ready = make(chan struct{})
+	q.mu.Unlock()
+
+	return nil
 }
 
-func (q *InMemoryQueue) Consume(ctx context.Context) (<-chan queue.Job, error) {
-	out := make(chan queue.Job)
+// Consume delivers the jobs of every language, aged by
+// queue.DefaultPriorityAging. NewConsumer configures a consumer.
+func (q *InMemoryQueue) Consume(ctx context.Context) (<-chan queue.Delivery, error) {
+	return q.consume(ctx, nil, queue.DefaultPriorityAging), nil
+}
+
+// consumer is a view of an InMemoryQueue that consumes the jobs of its
+// languages only.
+type consumer struct {
+	*InMemoryQueue
+	languages     []string
+	priorityAging time.Duration
+}
+
+// NewConsumer consumes the jobs of q that opts.Languages allows, aged by
+// opts.PriorityAging. Deliveries are shared with q, so any consumer of q can
+// settle them.
+func NewConsumer(q *InMemoryQueue, opts queue.ConsumerOptions) (queue.Consumer, error) {
+	if q == nil {
+		return nil, errNilQueue
+	}
+
+	var languages []string
+	if len(opts.Languages) > 0 {
+		languages = slices.Clone(opts.Languages)
+	}
+
+	return &consumer{InMemoryQueue: q, languages: languages, priorityAging: opts.PriorityAging}, nil
+}
+
+func (c *consumer) Consume(ctx context.Context) (<-chan queue.Delivery, error) {
+	return c.consume(ctx, c.languages, c.priorityAging), nil
+}
+
+// consume delivers the jobs of languages, or of all languages when it is
+// empty, until ctx is done.
+func (q *InMemoryQueue) consume(ctx context.Context, languages []string, aging time.Duration) <-chan queue.Delivery {
+	out := make(chan queue.Delivery)
 
 	go func() {
 		defer close(out)
 		for {
-			select {
-			case <-ctx.Done():
-				return
-			case job, ok := <-q.ch:
-				if !ok {
-					return
-				}
+			job, ready, ok := q.pop(languages, aging)
+			if !ok {
 				select {
 				case <-ctx.Done():
 					return
-				case out <- job:
+				case <-ready:
 				}
+				continue
+			}
+
+			delivery := q.deliver(job)
+			select {
+			case <-ctx.Done():
+				_ = q.Nack(context.WithoutCancel(ctx), delivery)
+				return
+			case out <- delivery:
 			}
 		}
 	}()
-	return out, nil
+	return out
+}
+
+func (q *InMemoryQueue) Ack(_ context.Context, d queue.Delivery) error {
+	if _, ok := q.take(d.Receipt); !ok {
+		return ErrUnknownReceipt
+	}
+
+	return nil
+}
+
+func (q *InMemoryQueue) Nack(ctx context.Context, d queue.Delivery) error {
+	job, ok := q.take(d.Receipt)
+	if !ok {
+		return ErrUnknownReceipt
+	}
+
+	return q.Enqueue(ctx, job)
+}
+
+func (q *InMemoryQueue) Retry(_ context.Context, d queue.Delivery, delay time.Duration) error {
+	job, ok := q.take(d.Receipt)
+	if !ok {
+		return ErrUnknownReceipt
+	}
+
+	job.Attempts++
+	time.AfterFunc(delay, func() {
+		_ = q.Enqueue(context.Background(), job)
+	})
+
+	return nil
+}
+
+func (q *InMemoryQueue) DeadLetter(_ context.Context, d queue.Delivery, reason string) error {
+	job, ok := q.take(d.Receipt)
+	if !ok {
+		return ErrUnknownReceipt
+	}
+
+	job.Attempts++
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.dead[job.ExecutionID] = queue.DeadLetter{Job: job, Reason: reason, FailedAt: time.Now()}
+	return nil
+}
+
+func (q *InMemoryQueue) ListDeadLetters(_ context.Context) ([]queue.DeadLetter, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	letters := make([]queue.DeadLetter, 0, len(q.dead))
+	for _, letter := range q.dead {
+		letters = append(letters, letter)
+	}
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+
+	return letters, nil
+}
+
+func (q *InMemoryQueue) Redrive(ctx context.Context, executionID string) error {
+	q.mu.Lock()
+	letter, ok := q.dead[executionID]
+	delete(q.dead, executionID)
+	q.mu.Unlock()
+
+	if !ok {
+		return queue.ErrDeadLetterNotFound
+	}
+
+	job := letter.Job
+	job.Attempts = 0
+	return q.Enqueue(ctx, job)
+}
+
+// pop removes the pending job of languages with the highest effective
+// priority; of equal ones the oldest. Without one, it returns the channel
+// that is closed when the next job is added.
+func (q *InMemoryQueue) pop(languages []string, aging time.Duration) (queue.Job, <-chan struct{}, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	now := time.Now()
+	best, bestPriority := -1, 0
+	for i, p := range q.pending {
+		if len(languages) > 0 && !slices.Contains(languages, p.job.Language) {
+			continue
+		}
+		priority := queue.EffectivePriority(p.job.Priority, now.Sub(p.enqueuedAt), aging)
+		if best < 0 || priority > bestPriority {
+			best, bestPriority = i, priority
+		}
+	}
+	if best < 0 {
+		return queue.Job{}, q.ready, false
+	}
+
+	job := q.pending[best].job
+	q.pending = append(q.pending[:best], q.pending[best+1:]...)
+	<-q.slots
+
+	return job, nil, true
+}
+
+func (q *InMemoryQueue) deliver(job queue.Job) queue.Delivery {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.next++
+	receipt := strconv.FormatUint(q.next, 10)
+	q.inflight[receipt] = job
+
+	return queue.Delivery{Job: job, Receipt: receipt}
+}
+
+func (q *InMemoryQueue) take(receipt string) (queue.Job, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	job, ok := q.inflight[receipt]
+	delete(q.inflight, receipt)
+
+	return job, ok
 }
@@ -0,0 +1,215 @@
+package queuememory
+
+import (
+	"Code_executor/internal/queue"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAck(t *testing.T) {
+	q, deliveries := consume(t, queue.ConsumerOptions{})
+	enqueue(t, q, queue.Job{ExecutionID: "exec-1"})
+
+	d := receive(t, deliveries)
+	if d.ExecutionID != "exec-1" {
+		t.Fatalf("delivered %s, want exec-1", d.ExecutionID)
+	}
+	if err := q.Ack(context.Background(), d); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := q.Ack(context.Background(), d); !errors.Is(err, ErrUnknownReceipt) {
+		t.Errorf("second Ack = %v, want %v", err, ErrUnknownReceipt)
+	}
+	if err := q.Nack(context.Background(), d); !errors.Is(err, ErrUnknownReceipt) {
+		t.Errorf("Nack after Ack = %v, want %v", err, ErrUnknownReceipt)
+	}
+	receiveNone(t, deliveries)
+}
+
+func TestNack(t *testing.T) {
+	q, deliveries := consume(t, queue.ConsumerOptions{})
+	enqueue(t, q, queue.Job{ExecutionID: "exec-1", Attempts: 1})
+
+	first := receive(t, deliveries)
+	if err := q.Nack(context.Background(), first); err != nil {
+		t.Fatal(err)
+	}
+
+	second := receive(t, deliveries)
+	if second.ExecutionID != "exec-1" || second.Attempts != 1 {
+		t.Errorf("redelivered %+v, want exec-1 with its attempts unchanged", second.Job)
+	}
+	if second.Receipt == first.Receipt {
+		t.Error("redelivery reused the receipt of the nacked delivery")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	q, deliveries := consume(t, queue.ConsumerOptions{})
+	enqueue(t, q, queue.Job{ExecutionID: "exec-1"})
+
+	d := receive(t, deliveries)
+	retried := time.Now()
+	if err := q.Retry(context.Background(), d, 50*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	d = receive(t, deliveries)
+	if elapsed := time.Since(retried); elapsed < 50*time.Millisecond {
+		t.Errorf("retry delivered after %s, want at least 50ms", elapsed)
+	}
+	if d.Attempts != 1 {
+		t.Errorf("retry has %d attempts, want 1", d.Attempts)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+	ctx := context.Background()
+	q, deliveries := consume(t, queue.ConsumerOptions{})
+	enqueue(t, q, queue.Job{ExecutionID: "exec-1", Attempts: 2})
+
+	if err := q.DeadLetter(ctx, receive(t, deliveries), "broken"); err != nil {
+		t.Fatal(err)
+	}
+	receiveNone(t, deliveries)
+
+	letters, err := q.ListDeadLetters(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 || letters[0].ExecutionID != "exec-1" || letters[0].Attempts != 3 || letters[0].Reason != "broken" {
+		t.Fatalf("dead letters = %+v, want exec-1 with 3 attempts", letters)
+	}
+
+	if err := q.Redrive(ctx, "exec-1"); err != nil {
+		t.Fatal(err)
+	}
+	if d := receive(t, deliveries); d.ExecutionID != "exec-1" || d.Attempts != 0 {
+		t.Errorf("redriven %+v, want exec-1 with no attempts", d.Job)
+	}
+
+	if err := q.Redrive(ctx, "exec-1"); !errors.Is(err, queue.ErrDeadLetterNotFound) {
+		t.Errorf("second Redrive = %v, want %v", err, queue.ErrDeadLetterNotFound)
+	}
+}
+
+func TestConsumerLanguages(t *testing.T) {
+	q, deliveries := consume(t, queue.ConsumerOptions{Languages: []string{"go"}})
+	enqueue(t, q, queue.Job{ExecutionID: "exec-py", Language: "python"})
+	enqueue(t, q, queue.Job{ExecutionID: "exec-go", Language: "go"})
+
+	d := receive(t, deliveries)
+	if d.ExecutionID != "exec-go" {
+		t.Fatalf("delivered %s, want exec-go", d.ExecutionID)
+	}
+	receiveNone(t, deliveries)
+
+	// Deliveries are shared, so another consumer of q can settle them.
+	all, err := q.Consume(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := receive(t, all)
+	if other.ExecutionID != "exec-py" {
+		t.Fatalf("delivered %s, want exec-py", other.ExecutionID)
+	}
+	if err := q.Ack(context.Background(), d); err != nil {
+		t.Errorf("Ack through the queue = %v", err)
+	}
+}
+
+func TestPriority(t *testing.T) {
+	q, err := NewInMemoryQueue(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	enqueue(t, q, queue.Job{ExecutionID: "low", Priority: 0})
+	enqueue(t, q, queue.Job{ExecutionID: "high", Priority: 5})
+	enqueue(t, q, queue.Job{ExecutionID: "high-later", Priority: 5})
+
+	c, err := NewConsumer(q, queue.ConsumerOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	deliveries, err := c.Consume(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"high", "high-later", "low"} {
+		d := receive(t, deliveries)
+		if d.ExecutionID != want {
+			t.Fatalf("delivered %s, want %s", d.ExecutionID, want)
+		}
+		if err := c.Ack(ctx, d); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNewConsumerNilQueue(t *testing.T) {
+	if _, err := NewConsumer(nil, queue.ConsumerOptions{}); !errors.Is(err, errNilQueue) {
+		t.Errorf("NewConsumer(nil) = %v, want %v", err, errNilQueue)
+	}
+}
+
+// consume returns a queue and the deliveries of a consumer with opts, which
+// stops with the test.
+func consume(t *testing.T, opts queue.ConsumerOptions) (*InMemoryQueue, <-chan queue.Delivery) {
+	t.Helper()
+
+	q, err := NewInMemoryQueue(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewConsumer(q, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	deliveries, err := c.Consume(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return q, deliveries
+}
+
+func enqueue(t *testing.T, q *InMemoryQueue, job queue.Job) {
+	t.Helper()
+
+	if err := q.Enqueue(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func receive(t *testing.T, deliveries <-chan queue.Delivery) queue.Delivery {
+	t.Helper()
+
+	select {
+	case d := <-deliveries:
+		return d
+	case <-time.After(time.Second):
+		t.Fatal("no delivery within a second")
+		return queue.Delivery{}
+	}
+}
+
+func receiveNone(t *testing.T, deliveries <-chan queue.Delivery) {
+	t.Helper()
+
+	select {
+	case d := <-deliveries:
+		t.Fatalf("unexpected delivery of %s", d.ExecutionID)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,57 @@
+package queuememory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"Code_executor/internal/queue"
+)
+
+// WorkerRegistry tracks the workers of the same process.
+type WorkerRegistry struct {
+	mu      sync.Mutex
+	workers map[string]queue.WorkerInfo
+}
+
+func NewWorkerRegistry() *WorkerRegistry {
+	return &WorkerRegistry{workers: make(map[string]queue.WorkerInfo)}
+}
+
+func (r *WorkerRegistry) Heartbeat(_ context.Context, info queue.WorkerInfo) error {
+	if info.ID == "" {
+		return fmt.Errorf("worker id is empty")
+	}
+
+	info.Languages = slices.Clone(info.Languages)
+	info.SeenAt = time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.workers[info.ID] = info
+	return nil
+}
+
+func (r *WorkerRegistry) Deregister(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.workers, id)
+	return nil
+}
+
+func (r *WorkerRegistry) ListWorkers(_ context.Context) ([]queue.WorkerInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	workers := make([]queue.WorkerInfo, 0, len(r.workers))
+	for _, info := range r.workers {
+		info.Languages = slices.Clone(info.Languages)
+		workers = append(workers, info)
+	}
+
+	return workers, nil
+}
@@ -0,0 +1,109 @@
+package pgqueue
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"Code_executor/internal/queue"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+const cancelChannel = "queue_executions_cancel"
+
+type cancelNotifier struct {
+	pool *pgxpool.Pool
+}
+
+type cancelListener struct {
+	pool *pgxpool.Pool
+}
+
+// NewCancelNotifier sends cancellations with NOTIFY. Like the Redis notifier,
+// workers that are not listening at that moment miss them.
+func NewCancelNotifier(pool *pgxpool.Pool) (queue.CancelNotifier, error) {
+	if pool == nil {
+		return nil, errNilPool
+	}
+
+	return &cancelNotifier{pool: pool}, nil
+}
+
+// NewCancelListener listens for the cancellations of NewCancelNotifier on a
+// connection of its own, which is replaced if it is lost.
+func NewCancelListener(pool *pgxpool.Pool) (queue.CancelListener, error) {
+	if pool == nil {
+		return nil, errNilPool
+	}
+
+	return &cancelListener{pool: pool}, nil
+}
+
+func (n *cancelNotifier) NotifyCancel(ctx context.Context, executionID string) error {
+	if executionID == "" {
+		return fmt.Errorf("execution id is required")
+	}
+
+	if _, err := n.pool.Exec(ctx, `SELECT pg_notify($1, $2)`, cancelChannel, executionID); err != nil {
+		return fmt.Errorf("postgres notify: %w", err)
+	}
+
+	return nil
+}
+
+func (l *cancelListener) ListenCancels(ctx context.Context) (<-chan string, error) {
+	// Listen before returning so no cancellation sent after we return is
+	// lost.
+	conn, err := listen(ctx, l.pool, cancelChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan string)
+
+	go func() {
+		defer close(out)
+
+		for {
+			if conn == nil {
+				conn, err = listen(ctx, l.pool, cancelChannel)
+				if err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+
+					log.Printf("listen for cancellations: %v", err)
+					select {
+					case <-ctx.Done():
+						return
+					case <-time.After(time.Second):
+					}
+					continue
+				}
+			}
+
+			notification, err := conn.Conn().WaitForNotification(ctx)
+			if ctx.Err() != nil {
+				unlisten(context.WithoutCancel(ctx), conn)
+				return
+			}
+			if err != nil {
+				log.Printf("wait for cancellations: %v", err)
+				conn.Release()
+				conn = nil
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				unlisten(context.WithoutCancel(ctx), conn)
+				return
+			case out <- notification.Payload:
+			}
+		}
+	}()
+
+	return out, nil
+}
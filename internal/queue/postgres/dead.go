@@ -0,0 +1,85 @@
+package pgqueue
+
+import (
+	"context"
+	"fmt"
+
+	"Code_executor/internal/pgtx"
+	"Code_executor/internal/queue"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// redriveQuery moves a dead letter back to the queue with its attempts reset.
+//
+// $1: execution id, $2: jobs channel.
+const redriveQuery = `
+WITH d AS (
+	DELETE FROM queue_dead_letters
+	WHERE execution_id = $1
+	RETURNING execution_id, language, user_id, priority
+), j AS (
+	INSERT INTO queue_jobs (execution_id, language, user_id, priority, effective_priority)
+	SELECT execution_id, language, user_id, priority, priority FROM d
+	RETURNING language
+)
+SELECT pg_notify($2, language) FROM j`
+
+type deadLetters struct {
+	pool *pgxpool.Pool
+}
+
+// NewDeadLetters gives access to the jobs consumers dead-lettered. Redrive
+// joins the transaction of its context, like Enqueue.
+func NewDeadLetters(pool *pgxpool.Pool) (queue.DeadLetters, error) {
+	if pool == nil {
+		return nil, errNilPool
+	}
+
+	return &deadLetters{pool: pool}, nil
+}
+
+func (d *deadLetters) ListDeadLetters(ctx context.Context) ([]queue.DeadLetter, error) {
+	rows, err := pgtx.Conn(ctx, d.pool).Query(ctx, `
+SELECT execution_id, language, user_id, priority, attempts, reason, failed_at
+FROM queue_dead_letters
+ORDER BY failed_at`)
+	if err != nil {
+		return nil, fmt.Errorf("postgres list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	var letters []queue.DeadLetter
+	for rows.Next() {
+		var letter queue.DeadLetter
+		err := rows.Scan(&letter.ExecutionID, &letter.Language, &letter.UserID, &letter.Priority, &letter.Attempts, &letter.Reason, &letter.FailedAt)
+		if err != nil {
+			return nil, fmt.Errorf("postgres scan dead letter: %w", err)
+		}
+
+		letters = append(letters, letter)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres list dead letters: %w", err)
+	}
+
+	return letters, nil
+}
+
+func (d *deadLetters) Redrive(ctx context.Context, executionID string) error {
+	if executionID == "" {
+		return fmt.Errorf("execution id is required")
+	}
+
+	tag, err := pgtx.Conn(ctx, d.pool).Exec(ctx, redriveQuery, executionID, jobsChannel)
+	if err != nil {
+		return fmt.Errorf("postgres redrive: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return queue.ErrDeadLetterNotFound
+	}
+
+	return nil
+}
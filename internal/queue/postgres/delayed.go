@@ -0,0 +1,95 @@
+package pgqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"Code_executor/internal/pgtx"
+	"Code_executor/internal/queue"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// claimDueQuery pushes run_at of up to $1 due jobs to the end of their
+// lease, $2 seconds from now, and returns them.
+const claimDueQuery = `
+WITH due AS (
+	SELECT execution_id
+	FROM queue_scheduled
+	WHERE run_at <= now()
+	ORDER BY run_at
+	LIMIT $1
+	FOR UPDATE SKIP LOCKED
+)
+UPDATE queue_scheduled s
+SET run_at = now() + make_interval(secs => $2)
+FROM due
+WHERE s.execution_id = due.execution_id
+RETURNING s.execution_id, s.language, s.user_id, s.priority`
+
+type delayedQueue struct {
+	pool *pgxpool.Pool
+}
+
+// NewDelayedQueue keeps scheduled jobs in the queue_scheduled table. Schedule
+// joins the transaction of its context, like Enqueue.
+func NewDelayedQueue(pool *pgxpool.Pool) (queue.DelayedQueue, error) {
+	if pool == nil {
+		return nil, errNilPool
+	}
+
+	return &delayedQueue{pool: pool}, nil
+}
+
+func (q *delayedQueue) Schedule(ctx context.Context, job queue.Job, runAt time.Time) error {
+	if job.ExecutionID == "" {
+		return fmt.Errorf("execution id is required")
+	}
+
+	_, err := pgtx.Conn(ctx, q.pool).Exec(ctx, `
+INSERT INTO queue_scheduled (execution_id, language, user_id, priority, run_at)
+VALUES ($1, $2, $3, $4, $5)`, job.ExecutionID, job.Language, job.UserID, job.Priority, runAt)
+	if err != nil {
+		return fmt.Errorf("postgres schedule: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimDue uses the execution id of a job as its receipt.
+func (q *delayedQueue) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]queue.Delivery, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	rows, err := q.pool.Query(ctx, claimDueQuery, limit, lease.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("postgres claim due jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []queue.Delivery
+	for rows.Next() {
+		var job queue.Job
+		if err := rows.Scan(&job.ExecutionID, &job.Language, &job.UserID, &job.Priority); err != nil {
+			return nil, fmt.Errorf("postgres scan due job: %w", err)
+		}
+
+		deliveries = append(deliveries, queue.Delivery{Job: job, Receipt: job.ExecutionID})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres claim due jobs: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+func (q *delayedQueue) Remove(ctx context.Context, d queue.Delivery) error {
+	if _, err := q.pool.Exec(ctx, `DELETE FROM queue_scheduled WHERE execution_id = $1`, d.Receipt); err != nil {
+		return fmt.Errorf("postgres remove scheduled job: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,468 @@
+// Package pgqueue keeps the queue in Postgres tables, which the migrations of
+// the Postgres store create.
+package pgqueue
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"Code_executor/internal/pgtx"
+	"Code_executor/internal/queue"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// jobsChannel is notified whenever a job becomes claimable, to wake idle
+// consumers. The payload is the job's language.
+const jobsChannel = "queue_jobs"
+
+var (
+	errNilPool        = errors.New("postgres pool is nil")
+	errUnknownReceipt = errors.New("unknown delivery receipt")
+)
+
+type producer struct {
+	pool *pgxpool.Pool
+}
+
+// consumer claims one job at a time with SELECT ... FOR UPDATE SKIP LOCKED,
+// so concurrent consumers never wait on each other's rows, and locks it until
+// a visibility deadline that is extended while the consumer lives. Jobs are
+// deleted on Ack; jobs whose lock expired are claimed again by any consumer.
+type consumer struct {
+	pool              *pgxpool.Pool
+	name              string
+	popTimeout        time.Duration
+	visibilityTimeout time.Duration
+	priorityAging     time.Duration
+	// languages the consumer claims jobs of; nil claims every language.
+	languages []string
+
+	// held are the claim tokens of unsettled deliveries; the receipt of a
+	// delivery is its claim token.
+	held *receipts
+}
+
+type receipts struct {
+	mu   sync.Mutex
+	held map[string]struct{}
+}
+
+// claimQuery locks the claimable job with the highest effective priority
+// for a consumer, so the order is served by an index; see promoteQuery. The
+// last column tells whether the job was reclaimed from a consumer whose lock
+// expired.
+//
+// $1: consumer name, $2: visibility timeout in seconds, $3: claim token,
+// $4: languages (NULL for all).
+const claimQuery = `
+WITH next AS (
+	SELECT id, locked_until
+	FROM queue_jobs
+	WHERE run_at <= now() AND (locked_until IS NULL OR locked_until < now())
+		AND ($4::text[] IS NULL OR language = ANY($4))
+	ORDER BY effective_priority DESC, priority DESC, run_at, id
+	LIMIT 1
+	FOR UPDATE SKIP LOCKED
+)
+UPDATE queue_jobs j
+SET locked_by = $1, locked_until = now() + make_interval(secs => $2), claim_token = $3
+FROM next
+WHERE j.id = next.id
+RETURNING j.execution_id, j.language, j.user_id, j.priority, j.attempts, next.locked_until IS NOT NULL`
+
+// promoteQuery raises effective_priority to queue.EffectivePriority, with
+// jobs aging from run_at. Consumers run it once per aging period, so a job
+// may lag its effective priority by up to one level.
+//
+// $1: priority aging period in seconds.
+const promoteQuery = `
+UPDATE queue_jobs
+SET effective_priority = priority + floor(extract(epoch FROM now() - run_at) / $1)::int
+WHERE run_at <= now() - make_interval(secs => $1)
+	AND effective_priority < priority + floor(extract(epoch FROM now() - run_at) / $1)::int`
+
+// The notifications are sent when the transaction commits, so consumers
+// woken by them see the job.
+const (
+	enqueueQuery = `
+WITH j AS (
+	INSERT INTO queue_jobs (execution_id, language, user_id, priority, effective_priority, attempts)
+	VALUES ($1, $2, $3, $4, $4, $5)
+	RETURNING language
+)
+SELECT pg_notify($6, language) FROM j`
+
+	nackQuery = `
+WITH j AS (
+	UPDATE queue_jobs
+	SET locked_by = NULL, locked_until = NULL, claim_token = NULL
+	WHERE claim_token = $1
+	RETURNING language
+)
+SELECT pg_notify($2, language) FROM j`
+)
+
+const retryQuery = `
+UPDATE queue_jobs
+SET attempts = attempts + 1, run_at = now() + make_interval(secs => $2),
+	effective_priority = priority, locked_by = NULL, locked_until = NULL, claim_token = NULL
+WHERE claim_token = $1`
+
+const deadLetterQuery = `
+WITH j AS (
+	DELETE FROM queue_jobs
+	WHERE claim_token = $1
+	RETURNING execution_id, language, user_id, priority, attempts
+)
+INSERT INTO queue_dead_letters (execution_id, language, user_id, priority, attempts, reason, failed_at)
+SELECT execution_id, language, user_id, priority, attempts + 1, $2, now() FROM j
+ON CONFLICT (execution_id) DO UPDATE
+SET language = EXCLUDED.language, user_id = EXCLUDED.user_id, priority = EXCLUDED.priority,
+	attempts = EXCLUDED.attempts, reason = EXCLUDED.reason, failed_at = EXCLUDED.failed_at`
+
+const extendQuery = `
+UPDATE queue_jobs
+SET locked_until = now() + make_interval(secs => $2)
+WHERE claim_token = ANY($1)`
+
+// NewProducer enqueues jobs into the queue_jobs table. Enqueue joins the
+// transaction of its context (see pgtx), so a job can be committed together
+// with its execution.
+func NewProducer(pool *pgxpool.Pool) (queue.Producer, error) {
+	if pool == nil {
+		return nil, errNilPool
+	}
+
+	return &producer{pool: pool}, nil
+}
+
+// NewConsumer creates an acknowledging consumer. Idle consumers are woken by
+// LISTEN/NOTIFY and poll every pop timeout, which bounds how late retries
+// that became due and jobs of dead consumers are picked up.
+func NewConsumer(pool *pgxpool.Pool, opts queue.ConsumerOptions) (queue.Consumer, error) {
+	if pool == nil {
+		return nil, errNilPool
+	}
+
+	opts = opts.WithDefaults()
+
+	var languages []string
+	if len(opts.Languages) > 0 {
+		languages = slices.Clone(opts.Languages)
+	}
+
+	return &consumer{
+		pool:              pool,
+		name:              consumerName(),
+		popTimeout:        opts.PopTimeout,
+		visibilityTimeout: opts.VisibilityTimeout,
+		priorityAging:     opts.PriorityAging,
+		languages:         languages,
+		held:              newReceipts(),
+	}, nil
+}
+
+func (p *producer) Enqueue(ctx context.Context, job queue.Job) error {
+	if job.ExecutionID == "" {
+		return fmt.Errorf("execution id is required")
+	}
+
+	conn := pgtx.Conn(ctx, p.pool)
+	_, err := conn.Exec(ctx, enqueueQuery, job.ExecutionID, job.Language, job.UserID, job.Priority, job.Attempts, jobsChannel)
+	if err != nil {
+		return fmt.Errorf("postgres enqueue: %w", err)
+	}
+
+	return nil
+}
+
+func (c *consumer) Consume(ctx context.Context) (<-chan queue.Delivery, error) {
+	conn, err := listen(ctx, c.pool, jobsChannel)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan queue.Delivery)
+
+	go c.keepAlive(ctx)
+	if c.priorityAging > 0 {
+		go c.promote(ctx)
+	}
+
+	go func() {
+		defer close(out)
+		defer func() {
+			if conn != nil {
+				unlisten(context.WithoutCancel(ctx), conn)
+			}
+		}()
+
+		for ctx.Err() == nil {
+			delivery, ok, err := c.claim(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("claim job: %v", err)
+			}
+
+			if !ok {
+				conn = c.wait(ctx, conn)
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				_ = c.Nack(context.WithoutCancel(ctx), delivery)
+				return
+			case out <- delivery:
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func (c *consumer) claim(ctx context.Context) (queue.Delivery, bool, error) {
+	token := uuid.NewString()
+
+	var (
+		job       queue.Job
+		reclaimed bool
+	)
+	err := c.pool.QueryRow(ctx, claimQuery, c.name, c.visibilityTimeout.Seconds(), token, c.languages).
+		Scan(&job.ExecutionID, &job.Language, &job.UserID, &job.Priority, &job.Attempts, &reclaimed)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return queue.Delivery{}, false, nil
+	}
+	if err != nil {
+		return queue.Delivery{}, false, err
+	}
+
+	if reclaimed {
+		log.Printf("claimed job of execution %s past its visibility timeout", job.ExecutionID)
+	}
+
+	c.held.hold(token)
+
+	return queue.Delivery{Job: job, Receipt: token}, true, nil
+}
+
+// wait blocks until a job of the consumer's languages is enqueued or
+// popTimeout passed. It returns the
+// listening connection to wait on next, or nil after the connection was lost;
+// a new one is then acquired on the next call.
+func (c *consumer) wait(ctx context.Context, conn *pgxpool.Conn) *pgxpool.Conn {
+	waitCtx, cancel := context.WithTimeout(ctx, c.popTimeout)
+	defer cancel()
+
+	if conn == nil {
+		next, err := listen(waitCtx, c.pool, jobsChannel)
+		if err != nil {
+			<-waitCtx.Done()
+			return nil
+		}
+
+		// Jobs enqueued while no connection listened are claimed first.
+		return next
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(waitCtx)
+		if err != nil {
+			if waitCtx.Err() == nil {
+				log.Printf("wait for jobs: %v", err)
+				conn.Release()
+				<-waitCtx.Done()
+				return nil
+			}
+			return conn
+		}
+
+		if c.languages == nil || slices.Contains(c.languages, notification.Payload) {
+			return conn
+		}
+	}
+}
+
+func (c *consumer) Ack(ctx context.Context, d queue.Delivery) error {
+	if !c.held.release(d.Receipt) {
+		return errUnknownReceipt
+	}
+
+	if _, err := c.pool.Exec(ctx, `DELETE FROM queue_jobs WHERE claim_token = $1`, d.Receipt); err != nil {
+		return fmt.Errorf("postgres ack: %w", err)
+	}
+
+	return nil
+}
+
+// Nack unlocks the job. It keeps its place in the queue, ahead of the jobs
+// enqueued after it.
+func (c *consumer) Nack(ctx context.Context, d queue.Delivery) error {
+	if !c.held.release(d.Receipt) {
+		return errUnknownReceipt
+	}
+
+	if _, err := c.pool.Exec(ctx, nackQuery, d.Receipt, jobsChannel); err != nil {
+		return fmt.Errorf("postgres nack: %w", err)
+	}
+
+	return nil
+}
+
+func (c *consumer) Retry(ctx context.Context, d queue.Delivery, delay time.Duration) error {
+	if !c.held.release(d.Receipt) {
+		return errUnknownReceipt
+	}
+
+	if _, err := c.pool.Exec(ctx, retryQuery, d.Receipt, delay.Seconds()); err != nil {
+		return fmt.Errorf("postgres retry: %w", err)
+	}
+
+	return nil
+}
+
+func (c *consumer) DeadLetter(ctx context.Context, d queue.Delivery, reason string) error {
+	if !c.held.release(d.Receipt) {
+		return errUnknownReceipt
+	}
+
+	if _, err := c.pool.Exec(ctx, deadLetterQuery, d.Receipt, reason); err != nil {
+		return fmt.Errorf("postgres dead letter: %w", err)
+	}
+
+	return nil
+}
+
+// promote ages the waiting jobs once per aging period until ctx is done.
+func (c *consumer) promote(ctx context.Context) {
+	ticker := time.NewTicker(c.priorityAging)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if _, err := c.pool.Exec(ctx, promoteQuery, c.priorityAging.Seconds()); err != nil && ctx.Err() == nil {
+			log.Printf("promote waiting jobs: %v", err)
+		}
+	}
+}
+
+// keepAlive extends the locks of held jobs. It outlives ctx while jobs are
+// still held, so jobs finishing during a drain are not claimed by another
+// worker.
+func (c *consumer) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(c.visibilityTimeout / 3)
+	defer ticker.Stop()
+
+	bg := context.WithoutCancel(ctx)
+
+	for range ticker.C {
+		if ctx.Err() != nil && c.held.count() == 0 {
+			return
+		}
+
+		held := c.held.list()
+		if len(held) == 0 {
+			continue
+		}
+
+		// Jobs that were reclaimed by another consumer meanwhile have a new
+		// claim token and stay out.
+		if _, err := c.pool.Exec(bg, extendQuery, held, c.visibilityTimeout.Seconds()); err != nil {
+			log.Printf("extend claimed jobs: %v", err)
+		}
+	}
+}
+
+// listen acquires a connection of its own that listens on channel.
+func listen(ctx context.Context, pool *pgxpool.Pool, channel string) (*pgxpool.Conn, error) {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("postgres acquire: %w", err)
+	}
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+		conn.Release()
+		return nil, fmt.Errorf("postgres listen: %w", err)
+	}
+
+	return conn, nil
+}
+
+// unlisten returns a connection of listen to the pool, or closes it if it
+// cannot stop listening.
+func unlisten(ctx context.Context, conn *pgxpool.Conn) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := conn.Exec(ctx, "UNLISTEN *"); err != nil {
+		_ = conn.Conn().Close(ctx)
+	}
+	conn.Release()
+}
+
+func newReceipts() *receipts {
+	return &receipts{held: make(map[string]struct{})}
+}
+
+func (r *receipts) hold(receipt string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.held[receipt] = struct{}{}
+}
+
+func (r *receipts) release(receipt string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.held[receipt]; !ok {
+		return false
+	}
+	delete(r.held, receipt)
+
+	return true
+}
+
+func (r *receipts) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.held)
+}
+
+func (r *receipts) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]string, 0, len(r.held))
+	for receipt := range r.held {
+		list = append(list, receipt)
+	}
+
+	return list
+}
+
+// consumerName identifies the process holding a job in locked_by, for
+// operators inspecting the table.
+func consumerName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+
+	return host + "-" + strconv.Itoa(os.Getpid())
+}
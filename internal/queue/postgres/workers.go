@@ -0,0 +1,87 @@
+package pgqueue
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"Code_executor/internal/queue"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// workerExpiry is how long a worker that stopped sending heartbeats without
+// deregistering, e.g. because it was killed, stays in queue_workers.
+const workerExpiry = time.Hour
+
+type workerRegistry struct {
+	pool *pgxpool.Pool
+}
+
+// NewWorkerRegistry keeps the workers in the queue_workers table.
+func NewWorkerRegistry(pool *pgxpool.Pool) (queue.WorkerRegistry, error) {
+	if pool == nil {
+		return nil, errNilPool
+	}
+
+	return &workerRegistry{pool: pool}, nil
+}
+
+func (r *workerRegistry) Heartbeat(ctx context.Context, info queue.WorkerInfo) error {
+	if info.ID == "" {
+		return fmt.Errorf("worker id is required")
+	}
+
+	languages := info.Languages
+	if languages == nil {
+		languages = []string{}
+	}
+
+	_, err := r.pool.Exec(ctx, `
+INSERT INTO queue_workers (id, languages, seen_at)
+VALUES ($1, $2, now())
+ON CONFLICT (id) DO UPDATE SET languages = EXCLUDED.languages, seen_at = EXCLUDED.seen_at`, info.ID, languages)
+	if err != nil {
+		return fmt.Errorf("postgres heartbeat: %w", err)
+	}
+
+	return nil
+}
+
+func (r *workerRegistry) Deregister(ctx context.Context, id string) error {
+	if _, err := r.pool.Exec(ctx, `DELETE FROM queue_workers WHERE id = $1`, id); err != nil {
+		return fmt.Errorf("postgres deregister worker: %w", err)
+	}
+
+	return nil
+}
+
+// ListWorkers also removes the workers that expired.
+func (r *workerRegistry) ListWorkers(ctx context.Context) ([]queue.WorkerInfo, error) {
+	_, err := r.pool.Exec(ctx, `DELETE FROM queue_workers WHERE seen_at < now() - make_interval(secs => $1)`, workerExpiry.Seconds())
+	if err != nil {
+		return nil, fmt.Errorf("postgres expire workers: %w", err)
+	}
+
+	rows, err := r.pool.Query(ctx, `SELECT id, languages, seen_at FROM queue_workers ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("postgres list workers: %w", err)
+	}
+	defer rows.Close()
+
+	var workers []queue.WorkerInfo
+	for rows.Next() {
+		var info queue.WorkerInfo
+		if err := rows.Scan(&info.ID, &info.Languages, &info.SeenAt); err != nil {
+			return nil, fmt.Errorf("postgres scan worker: %w", err)
+		}
+
+		workers = append(workers, info)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("postgres list workers: %w", err)
+	}
+
+	return workers, nil
+}
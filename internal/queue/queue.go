@@ -1,17 +1,148 @@
 package queue
 
-import "context"
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	ErrDeadLetterNotFound = errors.New("dead letter not found")
+)
 
 type Job struct {
 	ExecutionID string
 	Language    string
 	UserID      string
+	// Priority ranges from domain.MinPriority to domain.MaxPriority; jobs
+	// with a higher effective priority are delivered first.
+	Priority int
+	// Attempts counts the failed attempts before this delivery.
+	Attempts int
+}
+
+// DefaultPriorityAging is the default aging period of EffectivePriority.
+const DefaultPriorityAging = 30 * time.Second
+
+// ConsumerOptions configure a Consumer. Zero values are replaced by the
+// defaults of WithDefaults.
+type ConsumerOptions struct {
+	// PopTimeout bounds how long a consumer blocks waiting for a job before
+	// checking ctx and expired deliveries again.
+	PopTimeout time.Duration
+	// VisibilityTimeout is how long a delivery may go without being acked,
+	// or its deadline extended, before it is delivered again.
+	VisibilityTimeout time.Duration
+	// PriorityAging is the aging period of EffectivePriority; 0 disables
+	// aging.
+	PriorityAging time.Duration
+	// Languages limits the consumer to the jobs of these languages. Empty
+	// takes every language.
+	Languages []string
+}
+
+func (o ConsumerOptions) WithDefaults() ConsumerOptions {
+	if o.PopTimeout <= 0 {
+		o.PopTimeout = 5 * time.Second
+	}
+
+	if o.VisibilityTimeout <= 0 {
+		o.VisibilityTimeout = time.Minute
+	}
+
+	return o
 }
 
 type Producer interface {
 	Enqueue(ctx context.Context, job Job) error
 }
 
+// Delivery is a job handed to a consumer. It stays in flight until it is
+// acknowledged; a consumer redelivers deliveries that are neither acked nor
+// nacked within its visibility timeout, e.g. because the worker died.
+type Delivery struct {
+	Job
+	// Receipt identifies this delivery to Ack and Nack.
+	Receipt string
+}
+
 type Consumer interface {
-	Consume(ctx context.Context) (<-chan Job, error)
+	Consume(ctx context.Context) (<-chan Delivery, error)
+	// Ack removes a delivery that was processed from the queue.
+	Ack(ctx context.Context, d Delivery) error
+	// Nack returns a delivery to the queue to be delivered again.
+	Nack(ctx context.Context, d Delivery) error
+	// Retry delivers a failed delivery again after delay, with Attempts
+	// incremented.
+	Retry(ctx context.Context, d Delivery, delay time.Duration) error
+	// DeadLetter removes a delivery that will not be retried from the queue
+	// and keeps it in the dead letters, with Attempts incremented like Retry.
+	DeadLetter(ctx context.Context, d Delivery, reason string) error
+}
+
+type DeadLetter struct {
+	Job
+	Reason   string
+	FailedAt time.Time
+}
+
+// DeadLetters lets admins inspect dead-lettered jobs and put them back.
+type DeadLetters interface {
+	ListDeadLetters(ctx context.Context) ([]DeadLetter, error)
+	// Redrive moves the dead letter of an execution back to the queue with
+	// its attempts reset.
+	Redrive(ctx context.Context, executionID string) error
+}
+
+// DelayedQueue holds jobs until they are due, when a promoter moves them to
+// the work queue. A claimed job is handed out again once its lease expired,
+// unless it was removed, so a promoter that dies does not lose it.
+type DelayedQueue interface {
+	Schedule(ctx context.Context, job Job, runAt time.Time) error
+	// ClaimDue claims up to limit jobs that are due for lease. The receipt
+	// of a claimed job is passed to Remove.
+	ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]Delivery, error)
+	Remove(ctx context.Context, d Delivery) error
+}
+
+// CancelNotifier tells workers that an execution was cancelled.
+type CancelNotifier interface {
+	NotifyCancel(ctx context.Context, executionID string) error
+}
+
+// CancelListener delivers the ids of cancelled executions to a worker until
+// ctx is done.
+type CancelListener interface {
+	ListenCancels(ctx context.Context) (<-chan string, error)
+}
+
+// WorkerInfo is what a worker reports about itself with every heartbeat.
+type WorkerInfo struct {
+	ID string
+	// Languages the worker takes jobs for; empty means all of them.
+	Languages []string
+	SeenAt    time.Time
+}
+
+// WorkerRegistry tracks the workers that are alive, so the API can tell
+// whether the jobs of a language will be picked up.
+type WorkerRegistry interface {
+	// Heartbeat records that the worker is alive, setting SeenAt to now.
+	Heartbeat(ctx context.Context, info WorkerInfo) error
+	// Deregister removes a worker that stops.
+	Deregister(ctx context.Context, id string) error
+	// ListWorkers returns the workers that sent a heartbeat recently; how
+	// recently depends on the registry, so callers filter on SeenAt.
+	ListWorkers(ctx context.Context) ([]WorkerInfo, error)
+}
+
+// EffectivePriority is the priority a job competes with after waiting for
+// wait: it gains a level per aging period, so a steady stream of high
+// priority jobs cannot starve low priority ones. Aging <= 0 disables aging.
+func EffectivePriority(priority int, wait, aging time.Duration) int {
+	if aging <= 0 || wait <= 0 {
+		return priority
+	}
+
+	return priority + int(wait/aging)
 }
@@ -0,0 +1,105 @@
+package redisqueue
+
+import (
+	"context"
+	"fmt"
+
+	"Code_executor/internal/queue"
+
+	rds "github.com/redis/go-redis/v9"
+)
+
+const cancelChannel = "queue:executions:cancel"
+
+type cancelNotifier struct {
+	client  *rds.Client
+	channel string
+}
+
+type cancelListener struct {
+	client  *rds.Client
+	channel string
+}
+
+// NewCancelNotifier publishes cancellations on a pub/sub channel. Workers
+// that are not subscribed at that moment miss the message, which only
+// matters for jobs they are running.
+func NewCancelNotifier(redisClient *rds.Client, channel string) (queue.CancelNotifier, error) {
+	if redisClient == nil {
+		return nil, errNilRedisClient
+	}
+
+	return &cancelNotifier{
+		client:  redisClient,
+		channel: normalizeCancelChannel(channel),
+	}, nil
+}
+
+func NewCancelListener(redisClient *rds.Client, channel string) (queue.CancelListener, error) {
+	if redisClient == nil {
+		return nil, errNilRedisClient
+	}
+
+	return &cancelListener{
+		client:  redisClient,
+		channel: normalizeCancelChannel(channel),
+	}, nil
+}
+
+func (n *cancelNotifier) NotifyCancel(ctx context.Context, executionID string) error {
+	if executionID == "" {
+		return fmt.Errorf("execution id is required")
+	}
+
+	if err := n.client.Publish(ctx, n.channel, executionID).Err(); err != nil {
+		return fmt.Errorf("redis publish: %w", err)
+	}
+
+	return nil
+}
+
+func (l *cancelListener) ListenCancels(ctx context.Context) (<-chan string, error) {
+	sub := l.client.Subscribe(ctx, l.channel)
+
+	// Wait for the subscription so no cancellation sent after we return is
+	// lost.
+	if _, err := sub.Receive(ctx); err != nil {
+		_ = sub.Close()
+		return nil, fmt.Errorf("redis subscribe: %w", err)
+	}
+
+	out := make(chan string)
+
+	go func() {
+		defer close(out)
+		defer sub.Close()
+
+		messages := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-messages:
+				if !ok {
+					return
+				}
+
+				select {
+				case <-ctx.Done():
+					return
+				case out <- msg.Payload:
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+func normalizeCancelChannel(channel string) string {
+	if channel == "" {
+		return cancelChannel
+	}
+
+	return channel
+}
@@ -0,0 +1,135 @@
+package redisqueue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"Code_executor/internal/queue"
+
+	rds "github.com/redis/go-redis/v9"
+)
+
+// deadLetterPayload is stored in a hash keyed by execution id, so an
+// execution has at most one dead letter.
+type deadLetterPayload struct {
+	Job      jobPayload `json:"job"`
+	Reason   string     `json:"reason"`
+	FailedAt time.Time  `json:"failed_at"`
+}
+
+type deadLetters struct {
+	client  *rds.Client
+	deadKey string
+	// requeue queues the payload of a redriven job.
+	requeue func(ctx context.Context, pipe rds.Pipeliner, job queue.Job, payload string)
+}
+
+// NewDeadLetters gives access to the jobs consumers of the queue at key
+// dead-lettered.
+func NewDeadLetters(redisClient *rds.Client, key string) (queue.DeadLetters, error) {
+	if redisClient == nil {
+		return nil, errNilRedisClient
+	}
+
+	key = normalizeQueueKey(key)
+
+	return &deadLetters{
+		client:  redisClient,
+		deadKey: deadLetterKey(key),
+		requeue: func(ctx context.Context, pipe rds.Pipeliner, job queue.Job, payload string) {
+			pushJob(ctx, pipe, key, job, payload)
+		},
+	}, nil
+}
+
+// NewStreamDeadLetters is NewDeadLetters for the stream consumers of key.
+func NewStreamDeadLetters(redisClient *rds.Client, key string) (queue.DeadLetters, error) {
+	if redisClient == nil {
+		return nil, errNilRedisClient
+	}
+
+	stream := normalizeStreamKey(key)
+
+	return &deadLetters{
+		client:  redisClient,
+		deadKey: deadLetterKey(stream),
+		requeue: func(ctx context.Context, pipe rds.Pipeliner, job queue.Job, payload string) {
+			addJob(ctx, pipe, jobStream(stream, job), 0, payload)
+		},
+	}, nil
+}
+
+func (d *deadLetters) ListDeadLetters(ctx context.Context) ([]queue.DeadLetter, error) {
+	entries, err := d.client.HGetAll(ctx, d.deadKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis hgetall: %w", err)
+	}
+
+	letters := make([]queue.DeadLetter, 0, len(entries))
+	for _, raw := range entries {
+		var payload deadLetterPayload
+		if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+			continue
+		}
+
+		letters = append(letters, queue.DeadLetter{
+			Job:      payload.Job.job(),
+			Reason:   payload.Reason,
+			FailedAt: payload.FailedAt,
+		})
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt.Before(letters[j].FailedAt)
+	})
+
+	return letters, nil
+}
+
+func (d *deadLetters) Redrive(ctx context.Context, executionID string) error {
+	if executionID == "" {
+		return fmt.Errorf("execution id is required")
+	}
+
+	err := d.client.Watch(ctx, func(tx *rds.Tx) error {
+		raw, err := tx.HGet(ctx, d.deadKey, executionID).Result()
+		if errors.Is(err, rds.Nil) {
+			return queue.ErrDeadLetterNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("redis hget: %w", err)
+		}
+
+		var payload deadLetterPayload
+		if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+			return fmt.Errorf("unmarshal dead letter: %w", err)
+		}
+
+		job := payload.Job.job()
+		job.Attempts = 0
+		data, err := marshalJob(job)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe rds.Pipeliner) error {
+			pipe.HDel(ctx, d.deadKey, executionID)
+			d.requeue(ctx, pipe, job, data)
+			return nil
+		})
+		return err
+	}, d.deadKey)
+	if errors.Is(err, rds.TxFailedErr) {
+		return fmt.Errorf("redis redrive: dead letter changed concurrently")
+	}
+
+	return err
+}
+
+func deadLetterKey(key string) string {
+	return key + ":dead"
+}
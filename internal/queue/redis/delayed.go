@@ -0,0 +1,95 @@
+package redisqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"Code_executor/internal/queue"
+
+	rds "github.com/redis/go-redis/v9"
+)
+
+// claimDueScript pushes the score of up to limit due jobs to the end of
+// their lease and returns them.
+//
+// KEYS: scheduled set. ARGV: now, lease end, limit.
+var claimDueScript = rds.NewScript(`
+local due = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, ARGV[3])
+for _, payload in ipairs(due) do
+	redis.call('ZADD', KEYS[1], 'XX', ARGV[2], payload)
+end
+return due
+`)
+
+// delayedQueue is a sorted set of job payloads scored by the Unix time in
+// milliseconds they are due.
+type delayedQueue struct {
+	client *rds.Client
+	key    string
+}
+
+// NewDelayedQueue holds the scheduled jobs of the queue at key, whichever
+// backend consumes it.
+func NewDelayedQueue(redisClient *rds.Client, key string) (queue.DelayedQueue, error) {
+	if redisClient == nil {
+		return nil, errNilRedisClient
+	}
+
+	return &delayedQueue{
+		client: redisClient,
+		key:    normalizeQueueKey(key) + ":scheduled",
+	}, nil
+}
+
+func (q *delayedQueue) Schedule(ctx context.Context, job queue.Job, runAt time.Time) error {
+	if job.ExecutionID == "" {
+		return fmt.Errorf("execution id is required")
+	}
+
+	data, err := marshalJob(job)
+	if err != nil {
+		return err
+	}
+
+	if err := q.client.ZAdd(ctx, q.key, rds.Z{Score: float64(runAt.UnixMilli()), Member: data}).Err(); err != nil {
+		return fmt.Errorf("redis zadd: %w", err)
+	}
+
+	return nil
+}
+
+// ClaimDue uses the payload of a job as its receipt.
+func (q *delayedQueue) ClaimDue(ctx context.Context, limit int, lease time.Duration) ([]queue.Delivery, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+
+	now := time.Now()
+	due, err := claimDueScript.Run(ctx, q.client, []string{q.key}, now.UnixMilli(), now.Add(lease).UnixMilli(), limit).StringSlice()
+	if err != nil {
+		return nil, fmt.Errorf("redis claim due jobs: %w", err)
+	}
+
+	deliveries := make([]queue.Delivery, 0, len(due))
+	for _, raw := range due {
+		var payload jobPayload
+		if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+			_ = q.client.ZRem(ctx, q.key, raw).Err()
+			continue
+		}
+
+		deliveries = append(deliveries, queue.Delivery{Job: payload.job(), Receipt: raw})
+	}
+
+	return deliveries, nil
+}
+
+func (q *delayedQueue) Remove(ctx context.Context, d queue.Delivery) error {
+	if err := q.client.ZRem(ctx, q.key, d.Receipt).Err(); err != nil {
+		return fmt.Errorf("redis zrem: %w", err)
+	}
+
+	return nil
+}
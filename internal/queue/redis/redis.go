@@ -5,8 +5,12 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
+	"strconv"
+	"sync"
 	"time"
 
+	"Code_executor/internal/domain"
 	"Code_executor/internal/queue"
 
 	rds "github.com/redis/go-redis/v9"
@@ -14,27 +18,198 @@ import (
 
 const executionsListKey = "queue:executions"
 
+// retryPromoteInterval is the resolution of retry delays.
+const retryPromoteInterval = time.Second
+
 type producer struct {
 	client *rds.Client
 	key    string
 }
 
+// consumer moves each job atomically from the queue into a processing list
+// shared by all consumers of the queue and records its visibility deadline in
+// an in-flight sorted set. Jobs are removed on Ack; jobs whose deadline passed
+// are moved back to the queue by any consumer's reaper.
+//
+// The queue is a list per language and priority, and a consumer pops from the
+// lists of its languages only. A job is popped from the list whose oldest
+// job has the highest effective priority (see queue.EffectivePriority). Idle
+// consumers block on the ready lists of their languages, which get an
+// element for every job added to the language's lists.
 type consumer struct {
-	client     *rds.Client
-	key        string
-	popTimeout time.Duration
+	client *rds.Client
+	key    string
+	// queueKeys are the lists of the consumer's languages, priorityLevels
+	// per language, by priority.
+	queueKeys         []string
+	readyKeys         []string
+	processingKey     string
+	inflightKey       string
+	retryKey          string
+	deadKey           string
+	popTimeout        time.Duration
+	visibilityTimeout time.Duration
+	priorityAging     time.Duration
+
+	// held deadlines are extended while the consumer lives.
+	held *receipts
+}
+
+// receipts are the deliveries a consumer handed out and that were not
+// settled yet by Ack, Nack, Retry or DeadLetter.
+type receipts struct {
+	mu   sync.Mutex
+	held map[string]struct{}
 }
 
 type jobPayload struct {
 	ExecutionID string `json:"execution_id"`
 	Language    string `json:"language,omitempty"`
 	UserID      string `json:"user_id,omitempty"`
+	Priority    int    `json:"priority,omitempty"`
+	Attempts    int    `json:"attempts,omitempty"`
+	// EnqueuedAt is the Unix time in milliseconds the job was queued, which
+	// its effective priority grows with.
+	EnqueuedAt int64 `json:"enqueued_at,omitempty"`
 }
 
 var (
 	errNilRedisClient = errors.New("redis client is nil")
+	errUnknownReceipt = errors.New("unknown delivery receipt")
 )
 
+// priorityLevels is the number of lists per language.
+const priorityLevels = domain.MaxPriority - domain.MinPriority + 1
+
+// popScript moves the job with the highest effective priority from the
+// queues to the processing list and gives it a deadline. Of the queues'
+// oldest jobs, the one with the highest priority plus a level per aging
+// period waited wins; ties go to the higher priority. If the queues are
+// empty the ready lists are cleared, since they only signal jobs the next
+// pop would find.
+//
+// KEYS: processing list, in-flight set, ready lists, queues by language and
+// priority. ARGV: now, deadline, aging period (0 disables aging), number of
+// ready lists, priority levels.
+var popScript = rds.NewScript(`
+local aging = tonumber(ARGV[3])
+local first = 3 + tonumber(ARGV[4])
+local levels = tonumber(ARGV[5])
+local best, bestPriority
+for i = #KEYS, first, -1 do
+	local payload = redis.call('LINDEX', KEYS[i], -1)
+	if payload then
+		local priority = (i - first) % levels
+		if aging > 0 then
+			local ok, job = pcall(cjson.decode, payload)
+			local enqueued = ok and tonumber(job.enqueued_at) or 0
+			priority = priority + math.floor(math.max(tonumber(ARGV[1]) - enqueued, 0) / aging)
+		end
+		if not best or priority > bestPriority then
+			best, bestPriority = i, priority
+		end
+	end
+end
+if not best then
+	for i = 3, first - 1 do
+		redis.call('DEL', KEYS[i])
+	end
+	return false
+end
+local payload = redis.call('RPOP', KEYS[best])
+redis.call('LPUSH', KEYS[1], payload)
+redis.call('ZADD', KEYS[2], ARGV[2], payload)
+return payload
+`)
+
+// reapScript requeues an in-flight job if its deadline passed.
+//
+// KEYS: processing list, in-flight set, queue, ready list. ARGV: payload,
+// now.
+var reapScript = rds.NewScript(`
+local deadline = redis.call('ZSCORE', KEYS[2], ARGV[1])
+if not deadline or tonumber(deadline) > tonumber(ARGV[2]) then
+	return 0
+end
+redis.call('ZREM', KEYS[2], ARGV[1])
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) > 0 then
+	redis.call('RPUSH', KEYS[3], ARGV[1])
+	redis.call('LPUSH', KEYS[4], 1)
+	return 1
+end
+return 0
+`)
+
+// orphanScript gives a deadline to jobs in the processing list that have
+// none, which happens when a consumer died right after moving them.
+//
+// KEYS: processing list, in-flight set. ARGV: deadline.
+var orphanScript = rds.NewScript(`
+for _, payload in ipairs(redis.call('LRANGE', KEYS[1], 0, -1)) do
+	if not redis.call('ZSCORE', KEYS[2], payload) then
+		redis.call('ZADD', KEYS[2], 'NX', ARGV[1], payload)
+	end
+end
+return 1
+`)
+
+// promoteScript moves a retry that is due to the tail of its queue.
+//
+// KEYS: retry set, queue, ready list. ARGV: payload.
+var promoteScript = rds.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) > 0 then
+	redis.call('LPUSH', KEYS[2], ARGV[1])
+	redis.call('LPUSH', KEYS[3], 1)
+	return 1
+end
+return 0
+`)
+
+// retryScript moves a job from the processing list to the retry set, as the
+// payload with its attempts incremented.
+//
+// KEYS: processing list, in-flight set, retry set. ARGV: payload, retried
+// payload, due time.
+var retryScript = rds.NewScript(`
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+redis.call('ZADD', KEYS[3], ARGV[3], ARGV[2])
+return 1
+`)
+
+// deadLetterScript moves a job from the processing list to the dead letters.
+//
+// KEYS: processing list, in-flight set, dead letters. ARGV: payload,
+// execution id, dead letter.
+var deadLetterScript = rds.NewScript(`
+redis.call('ZREM', KEYS[2], ARGV[1])
+redis.call('LREM', KEYS[1], 1, ARGV[1])
+redis.call('HSET', KEYS[3], ARGV[2], ARGV[3])
+return 1
+`)
+
+// ackScript removes a job from the processing list and the in-flight set.
+//
+// KEYS: processing list, in-flight set. ARGV: payload.
+var ackScript = rds.NewScript(`
+redis.call('ZREM', KEYS[2], ARGV[1])
+return redis.call('LREM', KEYS[1], 1, ARGV[1])
+`)
+
+// nackScript moves a job from the processing list back to the head of its
+// queue. Jobs already requeued by the reaper are left alone.
+//
+// KEYS: processing list, in-flight set, queue, ready list. ARGV: payload.
+var nackScript = rds.NewScript(`
+redis.call('ZREM', KEYS[2], ARGV[1])
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) > 0 then
+	redis.call('RPUSH', KEYS[3], ARGV[1])
+	redis.call('LPUSH', KEYS[4], 1)
+	return 1
+end
+return 0
+`)
+
 func NewProducer(redisClient *rds.Client, key string) (queue.Producer, error) {
 	if redisClient == nil {
 		return nil, errNilRedisClient
@@ -46,20 +221,36 @@ func NewProducer(redisClient *rds.Client, key string) (queue.Producer, error) {
 	}, nil
 }
 
-func NewConsumer(redisClient *rds.Client, key string, popTimeout time.Duration) (queue.Consumer, error) {
+// NewConsumer creates an acknowledging consumer. Jobs not acked within the
+// visibility timeout are delivered again; the deadline is extended for as
+// long as the consumer holding the job is alive.
+func NewConsumer(redisClient *rds.Client, key string, opts queue.ConsumerOptions) (queue.Consumer, error) {
 	if redisClient == nil {
 		return nil, errNilRedisClient
 	}
 
-	if popTimeout <= 0 {
-		popTimeout = 5 * time.Second
+	opts = opts.WithDefaults()
+	key = normalizeQueueKey(key)
+
+	c := &consumer{
+		client:            redisClient,
+		key:               key,
+		processingKey:     key + ":processing",
+		inflightKey:       key + ":inflight",
+		retryKey:          key + ":retry",
+		deadKey:           deadLetterKey(key),
+		popTimeout:        opts.PopTimeout,
+		visibilityTimeout: opts.VisibilityTimeout,
+		priorityAging:     opts.PriorityAging,
+		held:              newReceipts(),
 	}
 
-	return &consumer{
-		client:     redisClient,
-		key:        normalizeQueueKey(key),
-		popTimeout: popTimeout,
-	}, nil
+	for _, languageKey := range languageKeys(key, opts.Languages) {
+		c.queueKeys = append(c.queueKeys, priorityKeys(languageKey)...)
+		c.readyKeys = append(c.readyKeys, readyKey(languageKey))
+	}
+
+	return c, nil
 }
 
 func (p *producer) Enqueue(ctx context.Context, job queue.Job) error {
@@ -67,26 +258,30 @@ func (p *producer) Enqueue(ctx context.Context, job queue.Job) error {
 		return fmt.Errorf("execution id is required")
 	}
 
-	payload := jobPayload{
-		ExecutionID: job.ExecutionID,
-		Language:    job.Language,
-		UserID:      job.UserID,
-	}
-
-	data, err := json.Marshal(payload)
+	data, err := marshalJob(job)
 	if err != nil {
-		return fmt.Errorf("marshal job: %w", err)
+		return err
 	}
 
-	if err := p.client.LPush(ctx, p.key, data).Err(); err != nil {
-		return fmt.Errorf("redis lpush: %w", err)
+	_, err = p.client.TxPipelined(ctx, func(pipe rds.Pipeliner) error {
+		pushJob(ctx, pipe, p.key, job, data)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis enqueue: %w", err)
 	}
 
 	return nil
 }
 
-func (c *consumer) Consume(ctx context.Context) (<-chan queue.Job, error) {
-	out := make(chan queue.Job)
+func (c *consumer) Consume(ctx context.Context) (<-chan queue.Delivery, error) {
+	out := make(chan queue.Delivery)
+
+	go c.keepAlive(ctx)
+	go promoteRetries(ctx, c.client, c.retryKey, func(ctx context.Context, payload string) error {
+		queueKey, readyKey := payloadQueue(c.key, payload)
+		return promoteScript.Run(ctx, c.client, []string{c.retryKey, queueKey, readyKey}, payload).Err()
+	})
 
 	go func() {
 		defer close(out)
@@ -96,41 +291,33 @@ func (c *consumer) Consume(ctx context.Context) (<-chan queue.Job, error) {
 				return
 			}
 
-			brCtx, cancel := context.WithTimeout(ctx, c.popTimeout)
-			result, err := c.client.BRPop(brCtx, c.popTimeout, c.key).Result()
-			cancel()
-
-			if err != nil {
-				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-					continue
-				}
-
-				if errors.Is(err, rds.Nil) {
-					continue
-				}
-
-				continue
+			raw, err := c.pop(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("pop job: %v", err)
 			}
 
-			if len(result) != 2 {
+			if raw == "" {
+				c.waitReady(ctx)
 				continue
 			}
+			c.held.hold(raw)
 
 			var payload jobPayload
-			if err := json.Unmarshal([]byte(result[1]), &payload); err != nil {
+			if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+				_ = c.Ack(ctx, queue.Delivery{Receipt: raw})
 				continue
 			}
 
-			job := queue.Job{
-				ExecutionID: payload.ExecutionID,
-				Language:    payload.Language,
-				UserID:      payload.UserID,
+			delivery := queue.Delivery{
+				Job:     payload.job(),
+				Receipt: raw,
 			}
 
 			select {
 			case <-ctx.Done():
+				_ = c.Nack(context.WithoutCancel(ctx), delivery)
 				return
-			case out <- job:
+			case out <- delivery:
 			}
 		}
 	}()
@@ -138,6 +325,362 @@ func (c *consumer) Consume(ctx context.Context) (<-chan queue.Job, error) {
 	return out, nil
 }
 
+// pop moves the next job to the processing list, returning "" if the queue
+// is empty.
+func (c *consumer) pop(ctx context.Context) (string, error) {
+	now := time.Now()
+	keys := append([]string{c.processingKey, c.inflightKey}, c.readyKeys...)
+	keys = append(keys, c.queueKeys...)
+	raw, err := popScript.Run(ctx, c.client, keys,
+		now.UnixMilli(), now.Add(c.visibilityTimeout).UnixMilli(), c.priorityAging.Milliseconds(), len(c.readyKeys), priorityLevels).Text()
+	if errors.Is(err, rds.Nil) {
+		return "", nil
+	}
+
+	return raw, err
+}
+
+// waitReady blocks until a job may have been added to the queue or
+// popTimeout passed.
+func (c *consumer) waitReady(ctx context.Context) {
+	waitCtx, cancel := context.WithTimeout(ctx, c.popTimeout+time.Second)
+	defer cancel()
+
+	err := c.client.BRPop(waitCtx, c.popTimeout, c.readyKeys...).Err()
+	if err != nil && !errors.Is(err, rds.Nil) && waitCtx.Err() == nil {
+		// Redis is unavailable; do not spin.
+		select {
+		case <-waitCtx.Done():
+		case <-time.After(time.Second):
+		}
+	}
+}
+
+func (c *consumer) Ack(ctx context.Context, d queue.Delivery) error {
+	if !c.held.release(d.Receipt) {
+		return errUnknownReceipt
+	}
+
+	keys := []string{c.processingKey, c.inflightKey}
+	if err := ackScript.Run(ctx, c.client, keys, d.Receipt).Err(); err != nil {
+		return fmt.Errorf("redis ack: %w", err)
+	}
+
+	return nil
+}
+
+func (c *consumer) Nack(ctx context.Context, d queue.Delivery) error {
+	if !c.held.release(d.Receipt) {
+		return errUnknownReceipt
+	}
+
+	queueKey, readyKey := payloadQueue(c.key, d.Receipt)
+	keys := []string{c.processingKey, c.inflightKey, queueKey, readyKey}
+	if err := nackScript.Run(ctx, c.client, keys, d.Receipt).Err(); err != nil {
+		return fmt.Errorf("redis nack: %w", err)
+	}
+
+	return nil
+}
+
+func (c *consumer) Retry(ctx context.Context, d queue.Delivery, delay time.Duration) error {
+	if !c.held.release(d.Receipt) {
+		return errUnknownReceipt
+	}
+
+	job := d.Job
+	job.Attempts++
+	data, err := marshalJob(job)
+	if err != nil {
+		return err
+	}
+
+	due := time.Now().Add(delay).UnixMilli()
+	keys := []string{c.processingKey, c.inflightKey, c.retryKey}
+	if err := retryScript.Run(ctx, c.client, keys, d.Receipt, data, due).Err(); err != nil {
+		return fmt.Errorf("redis retry: %w", err)
+	}
+
+	return nil
+}
+
+func (c *consumer) DeadLetter(ctx context.Context, d queue.Delivery, reason string) error {
+	if !c.held.release(d.Receipt) {
+		return errUnknownReceipt
+	}
+
+	job := d.Job
+	job.Attempts++
+	data, err := json.Marshal(deadLetterPayload{
+		Job:      newJobPayload(job),
+		Reason:   reason,
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal dead letter: %w", err)
+	}
+
+	keys := []string{c.processingKey, c.inflightKey, c.deadKey}
+	if err := deadLetterScript.Run(ctx, c.client, keys, d.Receipt, d.ExecutionID, data).Err(); err != nil {
+		return fmt.Errorf("redis dead letter: %w", err)
+	}
+
+	return nil
+}
+
+// keepAlive extends the deadlines of held jobs and reaps expired ones. It
+// outlives ctx while jobs are still held, so jobs finishing during a drain
+// are not redelivered to another worker.
+func (c *consumer) keepAlive(ctx context.Context) {
+	ticker := time.NewTicker(c.visibilityTimeout / 3)
+	defer ticker.Stop()
+
+	bg := context.WithoutCancel(ctx)
+
+	for range ticker.C {
+		if ctx.Err() != nil && c.held.count() == 0 {
+			return
+		}
+
+		if err := c.extend(bg); err != nil {
+			log.Printf("extend in-flight jobs: %v", err)
+		}
+
+		if ctx.Err() != nil {
+			continue
+		}
+
+		if err := c.reap(bg); err != nil {
+			log.Printf("reap in-flight jobs: %v", err)
+		}
+	}
+}
+
+// promoteRetries moves the payloads of the retry set that are due back to
+// the queue with promote until ctx is done.
+func promoteRetries(ctx context.Context, client *rds.Client, retryKey string, promote func(ctx context.Context, payload string) error) {
+	ticker := time.NewTicker(retryPromoteInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			due, err := client.ZRangeByScore(ctx, retryKey, &rds.ZRangeBy{
+				Min: "-inf",
+				Max: strconv.FormatInt(time.Now().UnixMilli(), 10),
+			}).Result()
+			if err == nil {
+				for _, payload := range due {
+					if err = promote(ctx, payload); err != nil {
+						break
+					}
+				}
+			}
+			if err != nil && ctx.Err() == nil {
+				log.Printf("promote retried jobs: %v", err)
+			}
+		}
+	}
+}
+
+func (c *consumer) extend(ctx context.Context) error {
+	deadline := float64(time.Now().Add(c.visibilityTimeout).UnixMilli())
+	var members []rds.Z
+	for _, receipt := range c.held.list() {
+		members = append(members, rds.Z{Score: deadline, Member: receipt})
+	}
+
+	if len(members) == 0 {
+		return nil
+	}
+
+	// XX: jobs that were acked or reaped meanwhile stay out.
+	return c.client.ZAddArgs(ctx, c.inflightKey, rds.ZAddArgs{XX: true, Members: members}).Err()
+}
+
+// reap requeues the expired jobs of all consumers, whatever their language.
+func (c *consumer) reap(ctx context.Context) error {
+	now := time.Now()
+	expired, err := c.client.ZRangeByScore(ctx, c.inflightKey, &rds.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(now.UnixMilli(), 10),
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	requeued := 0
+	for _, payload := range expired {
+		queueKey, readyKey := payloadQueue(c.key, payload)
+		keys := []string{c.processingKey, c.inflightKey, queueKey, readyKey}
+		n, err := reapScript.Run(ctx, c.client, keys, payload, now.UnixMilli()).Int()
+		if err != nil {
+			return err
+		}
+		requeued += n
+	}
+
+	keys := []string{c.processingKey, c.inflightKey}
+	if err := orphanScript.Run(ctx, c.client, keys, now.Add(c.visibilityTimeout).UnixMilli()).Err(); err != nil {
+		return err
+	}
+
+	if requeued > 0 {
+		log.Printf("requeued %d jobs past their visibility timeout", requeued)
+	}
+
+	return nil
+}
+
+func newReceipts() *receipts {
+	return &receipts{held: make(map[string]struct{})}
+}
+
+func (r *receipts) hold(receipt string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.held[receipt] = struct{}{}
+}
+
+func (r *receipts) release(receipt string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.held[receipt]; !ok {
+		return false
+	}
+	delete(r.held, receipt)
+
+	return true
+}
+
+func (r *receipts) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.held)
+}
+
+func (r *receipts) list() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	list := make([]string, 0, len(r.held))
+	for receipt := range r.held {
+		list = append(list, receipt)
+	}
+
+	return list
+}
+
+func newJobPayload(job queue.Job) jobPayload {
+	return jobPayload{
+		ExecutionID: job.ExecutionID,
+		Language:    job.Language,
+		UserID:      job.UserID,
+		Priority:    job.Priority,
+		Attempts:    job.Attempts,
+	}
+}
+
+func (p jobPayload) job() queue.Job {
+	return queue.Job{
+		ExecutionID: p.ExecutionID,
+		Language:    p.Language,
+		UserID:      p.UserID,
+		Priority:    p.Priority,
+		Attempts:    p.Attempts,
+	}
+}
+
+// marshalJob encodes a job that is added to the queue now.
+func marshalJob(job queue.Job) (string, error) {
+	payload := newJobPayload(job)
+	payload.EnqueuedAt = time.Now().UnixMilli()
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("marshal job: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// pushJob adds the payload of job to the queue of its language and priority.
+func pushJob(ctx context.Context, pipe rds.Pipeliner, key string, job queue.Job, payload string) {
+	languageKey := languageKey(key, job.Language)
+	pipe.LPush(ctx, priorityKey(languageKey, job.Priority), payload)
+	pipe.LPush(ctx, readyKey(languageKey), 1)
+}
+
+// payloadQueue returns the queue a payload taken from key belongs to, and its
+// ready list.
+func payloadQueue(key, payload string) (queueKey, ready string) {
+	var p jobPayload
+	_ = json.Unmarshal([]byte(payload), &p)
+
+	languageKey := languageKey(key, p.Language)
+	return priorityKey(languageKey, p.Priority), readyKey(languageKey)
+}
+
+// languageKeys are the keys of the languages a consumer of key takes. A
+// consumer of every language also takes the jobs that were queued at key
+// itself before queues were split by language.
+func languageKeys(key string, languages []string) []string {
+	if len(languages) == 0 {
+		keys := []string{key}
+		for _, language := range domain.LanguageNames() {
+			keys = append(keys, languageKey(key, language))
+		}
+		return keys
+	}
+
+	keys := make([]string, 0, len(languages))
+	for _, language := range languages {
+		keys = append(keys, languageKey(key, language))
+	}
+
+	return keys
+}
+
+// languageKey is the key the queues of a language are derived from.
+func languageKey(key, language string) string {
+	if language == "" {
+		return key
+	}
+
+	return key + ":lang:" + language
+}
+
+// priorityKeys lists the queues of key by priority.
+func priorityKeys(key string) []string {
+	keys := make([]string, 0, priorityLevels)
+	for priority := domain.MinPriority; priority <= domain.MaxPriority; priority++ {
+		keys = append(keys, priorityKey(key, priority))
+	}
+
+	return keys
+}
+
+// priorityKey is the queue of key for a priority. The lowest priority, the
+// default, uses key itself, so jobs queued before priorities existed are
+// still consumed.
+func priorityKey(key string, priority int) string {
+	priority = min(max(priority, domain.MinPriority), domain.MaxPriority)
+	if priority == domain.MinPriority {
+		return key
+	}
+
+	return key + ":p" + strconv.Itoa(priority)
+}
+
+func readyKey(key string) string {
+	return key + ":ready"
+}
+
 func normalizeQueueKey(key string) string {
 	if key == "" {
 		return executionsListKey
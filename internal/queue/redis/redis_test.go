@@ -0,0 +1,294 @@
+package redisqueue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"Code_executor/internal/queue"
+
+	rds "github.com/redis/go-redis/v9"
+)
+
+// testAddrEnv names the Redis server the tests use keys of their own on; they
+// are skipped without it.
+const testAddrEnv = "TEST_REDIS_ADDR"
+
+const (
+	testVisibilityTimeout = 300 * time.Millisecond
+	testPopTimeout        = 100 * time.Millisecond
+)
+
+// consumerKind builds a producer and a consumer of the same queue.
+type consumerKind struct {
+	name        string
+	newProducer func(client *rds.Client, key string) (queue.Producer, error)
+	newConsumer func(client *rds.Client, key string, opts queue.ConsumerOptions) (queue.Consumer, error)
+}
+
+var consumerKinds = []consumerKind{
+	{
+		name:        "list",
+		newProducer: NewProducer,
+		newConsumer: NewConsumer,
+	},
+	{
+		name: "stream",
+		newProducer: func(client *rds.Client, key string) (queue.Producer, error) {
+			return NewStreamProducer(client, key, 0)
+		},
+		newConsumer: func(client *rds.Client, key string, opts queue.ConsumerOptions) (queue.Consumer, error) {
+			return NewStreamConsumer(client, key, "", opts)
+		},
+	},
+}
+
+func TestConsumerAck(t *testing.T) {
+	forEachKind(t, func(t *testing.T, q *testQueue) {
+		q.enqueue(t, "exec-1")
+
+		c, deliveries := q.consume(t)
+		d := receive(t, deliveries)
+		if d.ExecutionID != "exec-1" || d.Language != "python" || d.Priority != 2 {
+			t.Fatalf("delivered %+v, want exec-1 for python with priority 2", d.Job)
+		}
+		if err := c.Ack(context.Background(), d); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.Ack(context.Background(), d); err == nil {
+			t.Error("second Ack succeeded")
+		}
+
+		// An acked job is gone, even once its visibility timeout passed.
+		_, others := q.consume(t)
+		receiveNone(t, deliveries, others)
+	})
+}
+
+func TestConsumerNack(t *testing.T) {
+	forEachKind(t, func(t *testing.T, q *testQueue) {
+		q.enqueue(t, "exec-1")
+
+		c, deliveries := q.consume(t)
+		if err := c.Nack(context.Background(), receive(t, deliveries)); err != nil {
+			t.Fatal(err)
+		}
+
+		d := receive(t, deliveries)
+		if d.ExecutionID != "exec-1" || d.Attempts != 0 {
+			t.Errorf("redelivered %+v, want exec-1 with no attempts", d.Job)
+		}
+	})
+}
+
+func TestConsumerRetry(t *testing.T) {
+	forEachKind(t, func(t *testing.T, q *testQueue) {
+		q.enqueue(t, "exec-1")
+
+		c, deliveries := q.consume(t)
+		if err := c.Retry(context.Background(), receive(t, deliveries), 100*time.Millisecond); err != nil {
+			t.Fatal(err)
+		}
+
+		d := receive(t, deliveries)
+		if d.ExecutionID != "exec-1" || d.Attempts != 1 {
+			t.Errorf("retried %+v, want exec-1 with 1 attempt", d.Job)
+		}
+	})
+}
+
+func TestConsumerPriority(t *testing.T) {
+	forEachKind(t, func(t *testing.T, q *testQueue) {
+		q.enqueueJob(t, queue.Job{ExecutionID: "low", Language: "python", Priority: 0})
+		q.enqueueJob(t, queue.Job{ExecutionID: "high", Language: "python", Priority: 5})
+		q.enqueueJob(t, queue.Job{ExecutionID: "high-later", Language: "python", Priority: 5})
+
+		c, deliveries := q.consume(t)
+		for _, want := range []string{"high", "high-later", "low"} {
+			d := receive(t, deliveries)
+			if d.ExecutionID != want {
+				t.Fatalf("delivered %s, want %s", d.ExecutionID, want)
+			}
+			if err := c.Ack(context.Background(), d); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
+
+// A job of a consumer that died is delivered again once its visibility
+// timeout passed.
+func TestConsumerRedeliversAfterVisibilityTimeout(t *testing.T) {
+	forEachKind(t, func(t *testing.T, q *testQueue) {
+		q.enqueue(t, "exec-1")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		dead, deliveries := q.consumeCtx(t, ctx)
+		lost := receive(t, deliveries)
+
+		// The consumer stops without settling the job, like a killed
+		// worker: nothing extends its deadline any more.
+		cancel()
+		heldBy(dead).release(lost.Receipt)
+
+		_, others := q.consume(t)
+		d := receive(t, others)
+		if d.ExecutionID != "exec-1" {
+			t.Errorf("redelivered %s, want exec-1", d.ExecutionID)
+		}
+	})
+}
+
+// A consumer that stopped consuming keeps the jobs it holds while its worker
+// drains them, well past the visibility timeout.
+func TestConsumerExtendsDuringDrain(t *testing.T) {
+	forEachKind(t, func(t *testing.T, q *testQueue) {
+		q.enqueue(t, "exec-1")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		draining, deliveries := q.consumeCtx(t, ctx)
+		d := receive(t, deliveries)
+		cancel()
+
+		_, others := q.consume(t)
+		receiveNone(t, others)
+
+		if err := draining.Ack(context.Background(), d); err != nil {
+			t.Fatalf("Ack after the drain = %v", err)
+		}
+		receiveNone(t, others)
+	})
+}
+
+type testQueue struct {
+	kind   consumerKind
+	client *rds.Client
+	key    string
+}
+
+// forEachKind runs test against every consumer kind, each on a queue of its
+// own that is removed afterwards.
+func forEachKind(t *testing.T, test func(t *testing.T, q *testQueue)) {
+	addr := os.Getenv(testAddrEnv)
+	if addr == "" {
+		t.Skipf("%s is not set", testAddrEnv)
+	}
+
+	for _, kind := range consumerKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			client := rds.NewClient(&rds.Options{Addr: addr})
+			t.Cleanup(func() { _ = client.Close() })
+
+			key := fmt.Sprintf("test:%s:%d", t.Name(), time.Now().UnixNano())
+			t.Cleanup(func() {
+				ctx := context.Background()
+				keys, err := client.Keys(ctx, key+"*").Result()
+				if err == nil && len(keys) > 0 {
+					err = client.Del(ctx, keys...).Err()
+				}
+				if err != nil {
+					t.Errorf("remove test keys: %v", err)
+				}
+			})
+
+			test(t, &testQueue{kind: kind, client: client, key: key})
+		})
+	}
+}
+
+func (q *testQueue) enqueue(t *testing.T, executionID string) {
+	t.Helper()
+
+	q.enqueueJob(t, queue.Job{ExecutionID: executionID, Language: "python", Priority: 2})
+}
+
+func (q *testQueue) enqueueJob(t *testing.T, job queue.Job) {
+	t.Helper()
+
+	producer, err := q.kind.newProducer(q.client, q.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := producer.Enqueue(context.Background(), job); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// consume starts a consumer that stops with the test.
+func (q *testQueue) consume(t *testing.T) (queue.Consumer, <-chan queue.Delivery) {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	return q.consumeCtx(t, ctx)
+}
+
+func (q *testQueue) consumeCtx(t *testing.T, ctx context.Context) (queue.Consumer, <-chan queue.Delivery) {
+	t.Helper()
+
+	c, err := q.kind.newConsumer(q.client, q.key, queue.ConsumerOptions{
+		PopTimeout:        testPopTimeout,
+		VisibilityTimeout: testVisibilityTimeout,
+		Languages:         []string{"python"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deliveries, err := c.Consume(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c, deliveries
+}
+
+func heldBy(c queue.Consumer) *receipts {
+	switch c := c.(type) {
+	case *consumer:
+		return c.held
+	case *streamConsumer:
+		return c.held
+	}
+
+	panic(fmt.Sprintf("unknown consumer %T", c))
+}
+
+func receive(t *testing.T, deliveries <-chan queue.Delivery) queue.Delivery {
+	t.Helper()
+
+	select {
+	case d := <-deliveries:
+		return d
+	case <-time.After(10 * testVisibilityTimeout):
+		t.Fatal("no delivery")
+		return queue.Delivery{}
+	}
+}
+
+// receiveNone waits for three visibility timeouts for an unexpected delivery
+// on any of deliveries.
+func receiveNone(t *testing.T, deliveries ...<-chan queue.Delivery) {
+	t.Helper()
+
+	deadline := time.After(3 * testVisibilityTimeout)
+	for {
+		for _, ch := range deliveries {
+			select {
+			case d := <-ch:
+				t.Fatalf("unexpected delivery of %s", d.ExecutionID)
+			default:
+			}
+		}
+
+		select {
+		case <-deadline:
+			return
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
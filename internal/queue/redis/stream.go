@@ -0,0 +1,604 @@
+package redisqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"Code_executor/internal/domain"
+	"Code_executor/internal/queue"
+
+	rds "github.com/redis/go-redis/v9"
+)
+
+const (
+	// streamJobField is the entry field holding the job payload.
+	streamJobField = "job"
+
+	defaultStreamGroup = "workers"
+)
+
+// streamPromoteScript moves a retry that is due back to its stream.
+//
+// KEYS: retry set, stream. ARGV: payload.
+var streamPromoteScript = rds.NewScript(`
+if redis.call('ZREM', KEYS[1], ARGV[1]) > 0 then
+	redis.call('XADD', KEYS[2], '*', 'job', ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// streamNextScript reads the unread entry of the group with the highest
+// effective priority, like popScript: of the streams' first entries after
+// the group's last delivered id, the one with the highest priority plus a
+// level per aging period waited wins; ties go to the higher priority. Entry
+// ids tell when entries were added.
+//
+// KEYS: streams. ARGV: group, consumer, now, aging period (0 disables aging),
+// then the priority of every stream. Returns the stream, id and job payload
+// of the entry read.
+var streamNextScript = rds.NewScript(`
+local aging = tonumber(ARGV[4])
+local best, bestPriority, bestLevel
+for i, key in ipairs(KEYS) do
+	local last = '0-0'
+	local groups = redis.pcall('XINFO', 'GROUPS', key)
+	if type(groups) == 'table' and not groups.err then
+		for _, group in ipairs(groups) do
+			local fields = {}
+			for j = 1, #group, 2 do
+				fields[group[j]] = group[j + 1]
+			end
+			if fields['name'] == ARGV[1] then
+				last = fields['last-delivered-id']
+			end
+		end
+	end
+	local head = redis.call('XRANGE', key, '(' .. last, '+', 'COUNT', 1)[1]
+	if head then
+		local level = tonumber(ARGV[4 + i])
+		local priority = level
+		if aging > 0 then
+			local added = tonumber(string.match(head[1], '^%d+'))
+			priority = priority + math.floor(math.max(tonumber(ARGV[3]) - added, 0) / aging)
+		end
+		if not best or priority > bestPriority or (priority == bestPriority and level > bestLevel) then
+			best, bestPriority, bestLevel = i, priority, level
+		end
+	end
+end
+if not best then
+	return false
+end
+local read = redis.call('XREADGROUP', 'GROUP', ARGV[1], ARGV[2], 'COUNT', 1, 'STREAMS', KEYS[best], '>')
+if not read then
+	return false
+end
+local entry = read[1][2][1]
+local payload = ''
+for j = 1, #entry[2], 2 do
+	if entry[2][j] == '` + streamJobField + `' then
+		payload = entry[2][j + 1]
+	end
+end
+return {KEYS[best], entry[1], payload}
+`)
+
+type streamProducer struct {
+	client *rds.Client
+	stream string
+	maxLen int64
+}
+
+// streamConsumer reads a stream as a member of a consumer group, so every
+// job goes to one consumer of the group and stays in that consumer's pending
+// list until acked. Entries pending longer than the visibility timeout, i.e.
+// of consumers that died, are claimed with XAUTOCLAIM (Redis 6.2 or later).
+// Acked entries stay in the stream as history.
+//
+// Like the list consumer's queue, the stream is split by language and
+// priority. The consumer reads from the stream of its languages whose oldest
+// unread entry has the highest effective priority (see
+// queue.EffectivePriority); entry ids tell when entries were added.
+type streamConsumer struct {
+	client            *rds.Client
+	key               string
+	streams           []priorityStream
+	group             string
+	name              string
+	retryKey          string
+	deadKey           string
+	popTimeout        time.Duration
+	visibilityTimeout time.Duration
+	priorityAging     time.Duration
+
+	// held entries have their idle time reset while the consumer lives; see
+	// streamReceipt.
+	held *receipts
+}
+
+type priorityStream struct {
+	key      string
+	priority int
+}
+
+// NewStreamProducer appends jobs to the stream of key. With maxLen > 0 the
+// stream is trimmed to roughly that many entries; 0 keeps the whole history.
+func NewStreamProducer(redisClient *rds.Client, key string, maxLen int64) (queue.Producer, error) {
+	if redisClient == nil {
+		return nil, errNilRedisClient
+	}
+
+	if maxLen < 0 {
+		return nil, fmt.Errorf("stream max length must not be negative")
+	}
+
+	return &streamProducer{
+		client: redisClient,
+		stream: normalizeStreamKey(key),
+		maxLen: maxLen,
+	}, nil
+}
+
+// NewStreamConsumer joins group, created if missing, on the streams of key.
+// An empty group uses the default group shared by all workers.
+func NewStreamConsumer(redisClient *rds.Client, key, group string, opts queue.ConsumerOptions) (queue.Consumer, error) {
+	if redisClient == nil {
+		return nil, errNilRedisClient
+	}
+
+	if group == "" {
+		group = defaultStreamGroup
+	}
+
+	opts = opts.WithDefaults()
+	stream := normalizeStreamKey(key)
+
+	c := &streamConsumer{
+		client:            redisClient,
+		key:               stream,
+		group:             group,
+		name:              consumerName(),
+		retryKey:          stream + ":retry",
+		deadKey:           deadLetterKey(stream),
+		popTimeout:        opts.PopTimeout,
+		visibilityTimeout: opts.VisibilityTimeout,
+		priorityAging:     opts.PriorityAging,
+		held:              newReceipts(),
+	}
+
+	for _, languageKey := range languageKeys(stream, opts.Languages) {
+		for p, key := range priorityKeys(languageKey) {
+			c.streams = append(c.streams, priorityStream{key: key, priority: domain.MinPriority + p})
+		}
+	}
+
+	return c, nil
+}
+
+func (p *streamProducer) Enqueue(ctx context.Context, job queue.Job) error {
+	if job.ExecutionID == "" {
+		return fmt.Errorf("execution id is required")
+	}
+
+	data, err := marshalJob(job)
+	if err != nil {
+		return err
+	}
+
+	if err := addJob(ctx, p.client, jobStream(p.stream, job), p.maxLen, data).Err(); err != nil {
+		return fmt.Errorf("redis xadd: %w", err)
+	}
+
+	return nil
+}
+
+func (c *streamConsumer) Consume(ctx context.Context) (<-chan queue.Delivery, error) {
+	// A new group starts at the beginning of the stream so jobs added before
+	// the first worker started are not skipped; finished ones are skipped by
+	// the worker.
+	for _, stream := range c.streams {
+		err := c.client.XGroupCreateMkStream(ctx, stream.key, c.group, "0").Err()
+		if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+			return nil, fmt.Errorf("redis xgroup create: %w", err)
+		}
+	}
+
+	out := make(chan queue.Delivery)
+	reading := make(chan struct{})
+
+	go c.keepAlive(ctx, reading)
+	go promoteRetries(ctx, c.client, c.retryKey, func(ctx context.Context, payload string) error {
+		var p jobPayload
+		_ = json.Unmarshal([]byte(payload), &p)
+		keys := []string{c.retryKey, jobStream(c.key, p.job())}
+		return streamPromoteScript.Run(ctx, c.client, keys, payload).Err()
+	})
+
+	go func() {
+		defer close(reading)
+		defer close(out)
+
+		nextClaim := time.Now()
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+
+			var streams []rds.XStream
+			if time.Now().After(nextClaim) {
+				streams = c.claim(ctx)
+				if len(streams) == 0 {
+					nextClaim = time.Now().Add(c.visibilityTimeout / 3)
+				}
+			}
+			if len(streams) == 0 {
+				streams = c.next(ctx)
+			}
+			if len(streams) == 0 {
+				streams = c.read(ctx)
+			}
+
+			for i, stream := range streams {
+				for j, msg := range stream.Messages {
+					delivery, ok := c.delivery(ctx, stream.Stream, msg)
+					if !ok {
+						continue
+					}
+
+					select {
+					case <-ctx.Done():
+						// Everything read but not handed out goes back
+						// to its stream.
+						bg := context.WithoutCancel(ctx)
+						c.requeue(bg, delivery)
+						c.requeueMessages(bg, stream.Stream, stream.Messages[j+1:])
+						for _, rest := range streams[i+1:] {
+							c.requeueMessages(bg, rest.Stream, rest.Messages)
+						}
+						return
+					case out <- delivery:
+					}
+				}
+			}
+		}
+	}()
+
+	return out, nil
+}
+
+// next reads the unread entry with the highest effective priority, without
+// blocking.
+func (c *streamConsumer) next(ctx context.Context) []rds.XStream {
+	keys := make([]string, len(c.streams))
+	args := []any{c.group, c.name, time.Now().UnixMilli(), c.priorityAging.Milliseconds()}
+	for i, stream := range c.streams {
+		keys[i] = stream.key
+		args = append(args, stream.priority)
+	}
+
+	// rds.Nil when there is no unread entry; read waits for one then.
+	entry, err := streamNextScript.Run(ctx, c.client, keys, args...).StringSlice()
+	if err != nil || len(entry) != 3 {
+		return nil
+	}
+
+	return []rds.XStream{{
+		Stream:   entry[0],
+		Messages: []rds.XMessage{{ID: entry[1], Values: map[string]any{streamJobField: entry[2]}}},
+	}}
+}
+
+// read waits for a new entry of the group on any stream. It may return an
+// entry of every stream, highest priority first.
+func (c *streamConsumer) read(ctx context.Context) []rds.XStream {
+	readCtx, cancel := context.WithTimeout(ctx, c.popTimeout+time.Second)
+	defer cancel()
+
+	args := make([]string, 0, 2*len(c.streams))
+	for _, stream := range c.byPriority() {
+		args = append(args, stream.key)
+	}
+	for range c.streams {
+		args = append(args, ">")
+	}
+
+	streams, err := c.client.XReadGroup(readCtx, &rds.XReadGroupArgs{
+		Group:    c.group,
+		Consumer: c.name,
+		Streams:  args,
+		Count:    1,
+		Block:    c.popTimeout,
+	}).Result()
+	if err != nil {
+		return nil
+	}
+
+	return streams
+}
+
+// claim takes over one entry another consumer left pending for longer than
+// the visibility timeout, trying the highest priority first.
+func (c *streamConsumer) claim(ctx context.Context) []rds.XStream {
+	for _, stream := range c.byPriority() {
+		messages, _, err := c.client.XAutoClaim(ctx, &rds.XAutoClaimArgs{
+			Stream:   stream.key,
+			Group:    c.group,
+			Consumer: c.name,
+			MinIdle:  c.visibilityTimeout,
+			Start:    "0-0",
+			Count:    1,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("claim pending jobs: %v", err)
+			}
+			return nil
+		}
+
+		if len(messages) > 0 {
+			log.Printf("claimed job %s past its visibility timeout", messages[0].ID)
+			return []rds.XStream{{Stream: stream.key, Messages: messages}}
+		}
+	}
+
+	return nil
+}
+
+// byPriority returns the streams, highest priority first.
+func (c *streamConsumer) byPriority() []priorityStream {
+	streams := slices.Clone(c.streams)
+	slices.SortStableFunc(streams, func(a, b priorityStream) int {
+		return b.priority - a.priority
+	})
+
+	return streams
+}
+
+func (c *streamConsumer) delivery(ctx context.Context, stream string, msg rds.XMessage) (queue.Delivery, bool) {
+	raw, _ := msg.Values[streamJobField].(string)
+
+	var payload jobPayload
+	if err := json.Unmarshal([]byte(raw), &payload); err != nil {
+		_ = c.client.XAck(ctx, stream, c.group, msg.ID).Err()
+		return queue.Delivery{}, false
+	}
+
+	receipt := streamReceipt(stream, msg.ID)
+	c.held.hold(receipt)
+
+	return queue.Delivery{
+		Job:     payload.job(),
+		Receipt: receipt,
+	}, true
+}
+
+// requeueMessages nacks entries read from the group that were never handed
+// out; a failed nack leaves the entry pending for XAUTOCLAIM.
+func (c *streamConsumer) requeueMessages(ctx context.Context, stream string, messages []rds.XMessage) {
+	for _, msg := range messages {
+		if delivery, ok := c.delivery(ctx, stream, msg); ok {
+			c.requeue(ctx, delivery)
+		}
+	}
+}
+
+func (c *streamConsumer) requeue(ctx context.Context, d queue.Delivery) {
+	if err := c.Nack(ctx, d); err != nil {
+		log.Printf("requeue job %s: %v", d.ExecutionID, err)
+	}
+}
+
+func (c *streamConsumer) Ack(ctx context.Context, d queue.Delivery) error {
+	if !c.held.release(d.Receipt) {
+		return errUnknownReceipt
+	}
+
+	stream, id := splitStreamReceipt(d.Receipt)
+	if err := c.client.XAck(ctx, stream, c.group, id).Err(); err != nil {
+		return fmt.Errorf("redis xack: %w", err)
+	}
+
+	return nil
+}
+
+// Nack adds the job to the stream again, as entries cannot be moved back.
+func (c *streamConsumer) Nack(ctx context.Context, d queue.Delivery) error {
+	if !c.held.release(d.Receipt) {
+		return errUnknownReceipt
+	}
+
+	data, err := marshalJob(d.Job)
+	if err != nil {
+		return err
+	}
+
+	stream, id := splitStreamReceipt(d.Receipt)
+	_, err = c.client.TxPipelined(ctx, func(pipe rds.Pipeliner) error {
+		addJob(ctx, pipe, stream, 0, data)
+		pipe.XAck(ctx, stream, c.group, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis nack: %w", err)
+	}
+
+	return nil
+}
+
+func (c *streamConsumer) Retry(ctx context.Context, d queue.Delivery, delay time.Duration) error {
+	if !c.held.release(d.Receipt) {
+		return errUnknownReceipt
+	}
+
+	job := d.Job
+	job.Attempts++
+	data, err := marshalJob(job)
+	if err != nil {
+		return err
+	}
+
+	due := float64(time.Now().Add(delay).UnixMilli())
+	stream, id := splitStreamReceipt(d.Receipt)
+	_, err = c.client.TxPipelined(ctx, func(pipe rds.Pipeliner) error {
+		pipe.ZAdd(ctx, c.retryKey, rds.Z{Score: due, Member: data})
+		pipe.XAck(ctx, stream, c.group, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis retry: %w", err)
+	}
+
+	return nil
+}
+
+func (c *streamConsumer) DeadLetter(ctx context.Context, d queue.Delivery, reason string) error {
+	if !c.held.release(d.Receipt) {
+		return errUnknownReceipt
+	}
+
+	job := d.Job
+	job.Attempts++
+	data, err := json.Marshal(deadLetterPayload{
+		Job:      newJobPayload(job),
+		Reason:   reason,
+		FailedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal dead letter: %w", err)
+	}
+
+	stream, id := splitStreamReceipt(d.Receipt)
+	_, err = c.client.TxPipelined(ctx, func(pipe rds.Pipeliner) error {
+		pipe.HSet(ctx, c.deadKey, d.ExecutionID, data)
+		pipe.XAck(ctx, stream, c.group, id)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("redis dead letter: %w", err)
+	}
+
+	return nil
+}
+
+// keepAlive resets the idle time of held entries so they are not claimed by
+// other consumers. Like the list consumer's, it outlives ctx while entries
+// are held or the reader is still running, and then removes the consumer from
+// the group.
+func (c *streamConsumer) keepAlive(ctx context.Context, reading <-chan struct{}) {
+	ticker := time.NewTicker(c.visibilityTimeout / 3)
+	defer ticker.Stop()
+
+	bg := context.WithoutCancel(ctx)
+
+	for range ticker.C {
+		if ctx.Err() != nil && c.held.count() == 0 && isClosed(reading) {
+			break
+		}
+
+		held := make(map[string][]string)
+		for _, receipt := range c.held.list() {
+			stream, id := splitStreamReceipt(receipt)
+			held[stream] = append(held[stream], id)
+		}
+
+		for stream, ids := range held {
+			err := c.client.XClaimJustID(bg, &rds.XClaimArgs{
+				Stream:   stream,
+				Group:    c.group,
+				Consumer: c.name,
+				Messages: ids,
+			}).Err()
+			if err != nil {
+				log.Printf("extend pending jobs: %v", err)
+			}
+		}
+	}
+
+	// Deleting a consumer drops its pending entries. Some may be left, e.g.
+	// when an ack failed or a read was cut short by the shutdown; then the
+	// consumer stays for other consumers to claim them with XAUTOCLAIM.
+	for _, stream := range c.streams {
+		pending, err := c.client.XPendingExt(bg, &rds.XPendingExtArgs{
+			Stream:   stream.key,
+			Group:    c.group,
+			Start:    "-",
+			End:      "+",
+			Count:    1,
+			Consumer: c.name,
+		}).Result()
+		if err != nil {
+			log.Printf("check pending jobs of stream consumer %s: %v", c.name, err)
+			continue
+		}
+		if len(pending) > 0 {
+			log.Printf("keeping stream consumer %s with pending jobs on %s", c.name, stream.key)
+			continue
+		}
+
+		if err := c.client.XGroupDelConsumer(bg, stream.key, c.group, c.name).Err(); err != nil {
+			log.Printf("remove stream consumer %s: %v", c.name, err)
+		}
+	}
+}
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// jobStream is the stream of job's language and priority.
+func jobStream(stream string, job queue.Job) string {
+	return priorityKey(languageKey(stream, job.Language), job.Priority)
+}
+
+func addJob(ctx context.Context, client rds.Cmdable, stream string, maxLen int64, payload string) *rds.StringCmd {
+	return client.XAdd(ctx, &rds.XAddArgs{
+		Stream: stream,
+		MaxLen: maxLen,
+		Approx: maxLen > 0,
+		Values: []string{streamJobField, payload},
+	})
+}
+
+// consumerName is unique per process, so a restarted worker does not inherit
+// the pending entries of its previous run; those are claimed like any dead
+// consumer's.
+func consumerName() string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "worker"
+	}
+
+	return host + "-" + strconv.Itoa(os.Getpid()) + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+}
+
+// streamReceipt identifies an entry by its stream and id, as ids are only
+// unique within a stream.
+func streamReceipt(stream, id string) string {
+	return stream + "/" + id
+}
+
+func splitStreamReceipt(receipt string) (stream, id string) {
+	i := strings.LastIndex(receipt, "/")
+	if i < 0 {
+		return "", receipt
+	}
+
+	return receipt[:i], receipt[i+1:]
+}
+
+func normalizeStreamKey(key string) string {
+	return normalizeQueueKey(key) + ":stream"
+}
@@ -0,0 +1,99 @@
+package redisqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"Code_executor/internal/queue"
+
+	rds "github.com/redis/go-redis/v9"
+)
+
+// workerExpiry is how long a worker that stopped sending heartbeats without
+// deregistering, e.g. because it was killed, stays in the registry.
+const workerExpiry = time.Hour
+
+type workerPayload struct {
+	Languages []string  `json:"languages,omitempty"`
+	SeenAt    time.Time `json:"seen_at"`
+}
+
+type workerRegistry struct {
+	client *rds.Client
+	key    string
+}
+
+// NewWorkerRegistry keeps the workers of the queue at key, whichever
+// backend they consume, in a hash keyed by worker id.
+func NewWorkerRegistry(redisClient *rds.Client, key string) (queue.WorkerRegistry, error) {
+	if redisClient == nil {
+		return nil, errNilRedisClient
+	}
+
+	return &workerRegistry{
+		client: redisClient,
+		key:    normalizeQueueKey(key) + ":workers",
+	}, nil
+}
+
+func (r *workerRegistry) Heartbeat(ctx context.Context, info queue.WorkerInfo) error {
+	if info.ID == "" {
+		return fmt.Errorf("worker id is required")
+	}
+
+	data, err := json.Marshal(workerPayload{
+		Languages: info.Languages,
+		SeenAt:    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal worker: %w", err)
+	}
+
+	if err := r.client.HSet(ctx, r.key, info.ID, data).Err(); err != nil {
+		return fmt.Errorf("redis hset: %w", err)
+	}
+
+	return nil
+}
+
+func (r *workerRegistry) Deregister(ctx context.Context, id string) error {
+	if err := r.client.HDel(ctx, r.key, id).Err(); err != nil {
+		return fmt.Errorf("redis hdel: %w", err)
+	}
+
+	return nil
+}
+
+// ListWorkers also removes the workers that expired.
+func (r *workerRegistry) ListWorkers(ctx context.Context) ([]queue.WorkerInfo, error) {
+	values, err := r.client.HGetAll(ctx, r.key).Result()
+	if err != nil {
+		return nil, fmt.Errorf("redis hgetall: %w", err)
+	}
+
+	var (
+		workers []queue.WorkerInfo
+		expired []string
+	)
+	for id, raw := range values {
+		var payload workerPayload
+		if err := json.Unmarshal([]byte(raw), &payload); err != nil || time.Since(payload.SeenAt) > workerExpiry {
+			expired = append(expired, id)
+			continue
+		}
+
+		workers = append(workers, queue.WorkerInfo{
+			ID:        id,
+			Languages: payload.Languages,
+			SeenAt:    payload.SeenAt,
+		})
+	}
+
+	if len(expired) > 0 {
+		_ = r.client.HDel(ctx, r.key, expired...).Err()
+	}
+
+	return workers, nil
+}
@@ -5,6 +5,7 @@ import (
 	"Code_executor/internal/repository"
 	"context"
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -43,6 +44,27 @@ func (r *ExecutionRepository) UpdateExecution(_ context.Context, exec *domain.Ex
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	stored, exists := r.store[exec.ID]
+	if !exists {
+		return repository.ErrExecutionNotFound
+	}
+
+	if stored.IsFinal() {
+		return repository.ErrExecutionFinished
+	}
+
+	r.store[exec.ID] = cloneExecution(exec)
+	return nil
+}
+
+func (r *ExecutionRepository) ReopenExecution(_ context.Context, exec *domain.Execution) error {
+	if exec == nil {
+		return fmt.Errorf("execution is nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	if _, exists := r.store[exec.ID]; !exists {
 		return repository.ErrExecutionNotFound
 	}
@@ -63,6 +85,50 @@ func (r *ExecutionRepository) GetExecutionByID(_ context.Context, id string) (*d
 	return cloneExecution(exec), nil
 }
 
+func (r *ExecutionRepository) ListExecutions(_ context.Context, filter repository.ExecutionFilter) ([]*domain.Execution, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var execs []*domain.Execution
+	for _, exec := range r.store {
+		if filter.UserID != "" && exec.UserID != filter.UserID {
+			continue
+		}
+		if filter.ScheduleID != "" && exec.ScheduleID != filter.ScheduleID {
+			continue
+		}
+		if filter.Status != "" && exec.Status != filter.Status {
+			continue
+		}
+		if !filter.After.IsZero() && !before(exec, filter.After) {
+			continue
+		}
+
+		execs = append(execs, cloneExecution(exec))
+	}
+
+	sort.Slice(execs, func(i, j int) bool {
+		if !execs[i].CreatedAt.Equal(execs[j].CreatedAt) {
+			return execs[i].CreatedAt.After(execs[j].CreatedAt)
+		}
+		return execs[i].ID > execs[j].ID
+	})
+
+	if filter.Limit > 0 && len(execs) > filter.Limit {
+		execs = execs[:filter.Limit]
+	}
+
+	return execs, nil
+}
+
+// before reports whether exec comes after cursor in the newest-first order.
+func before(exec *domain.Execution, cursor repository.ExecutionCursor) bool {
+	if !exec.CreatedAt.Equal(cursor.CreatedAt) {
+		return exec.CreatedAt.Before(cursor.CreatedAt)
+	}
+	return exec.ID < cursor.ID
+}
+
 func cloneExecution(src *domain.Execution) *domain.Execution {
 	if src == nil {
 		return nil
@@ -70,11 +136,28 @@ func cloneExecution(src *domain.Execution) *domain.Execution {
 
 	clone := *src
 
+	if src.Files != nil {
+		clone.Files = make(map[string]string, len(src.Files))
+		for name, content := range src.Files {
+			clone.Files[name] = content
+		}
+	}
+
 	if src.ExitCode != nil {
 		exitCode := *src.ExitCode
 		clone.ExitCode = &exitCode
 	}
 
+	if src.CompileExitCode != nil {
+		compileExitCode := *src.CompileExitCode
+		clone.CompileExitCode = &compileExitCode
+	}
+
+	if src.RunAt != nil {
+		runAt := *src.RunAt
+		clone.RunAt = &runAt
+	}
+
 	if src.StartedAt != nil {
 		startedAt := *src.StartedAt
 		clone.StartedAt = &startedAt
@@ -85,5 +168,31 @@ func cloneExecution(src *domain.Execution) *domain.Execution {
 		clone.FinishedAt = &finishedAt
 	}
 
+	if src.TestCases != nil {
+		clone.TestCases = append([]domain.TestCase(nil), src.TestCases...)
+	}
+
+	if src.Checker.Files != nil {
+		clone.Checker.Files = make(map[string]string, len(src.Checker.Files))
+		for name, content := range src.Checker.Files {
+			clone.Checker.Files[name] = content
+		}
+	}
+
+	if src.TestResults != nil {
+		clone.TestResults = make([]domain.TestCaseResult, len(src.TestResults))
+		for i, result := range src.TestResults {
+			if result.ExitCode != nil {
+				exitCode := *result.ExitCode
+				result.ExitCode = &exitCode
+			}
+			clone.TestResults[i] = result
+		}
+	}
+
+	if src.Violations != nil {
+		clone.Violations = append([]domain.ResourceViolation(nil), src.Violations...)
+	}
+
 	return &clone
 }
@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"Code_executor/internal/repository"
+	"Code_executor/internal/repository/repositorytest"
+	"testing"
+)
+
+func TestExecutionRepository(t *testing.T) {
+	repositorytest.TestExecutionRepository(t, func(t *testing.T) repository.ExecutionRepository {
+		return NewExecutionRepository()
+	})
+}
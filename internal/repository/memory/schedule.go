@@ -0,0 +1,159 @@
+package memory
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/repository"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+type ScheduleRepository struct {
+	mu    sync.RWMutex
+	store map[string]*domain.Schedule
+}
+
+func NewScheduleRepository() *ScheduleRepository {
+	return &ScheduleRepository{
+		store: make(map[string]*domain.Schedule),
+	}
+}
+
+func (r *ScheduleRepository) CreateSchedule(_ context.Context, schedule *domain.Schedule) error {
+	if schedule == nil {
+		return fmt.Errorf("schedule is nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.store[schedule.ID]; exists {
+		return fmt.Errorf("schedule with id %s already exists", schedule.ID)
+	}
+
+	r.store[schedule.ID] = cloneSchedule(schedule)
+	return nil
+}
+
+func (r *ScheduleRepository) UpdateSchedule(_ context.Context, schedule *domain.Schedule) error {
+	if schedule == nil {
+		return fmt.Errorf("schedule is nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.store[schedule.ID]; !exists {
+		return repository.ErrScheduleNotFound
+	}
+
+	r.store[schedule.ID] = cloneSchedule(schedule)
+	return nil
+}
+
+func (r *ScheduleRepository) GetScheduleByID(_ context.Context, id string) (*domain.Schedule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	schedule, exists := r.store[id]
+	if !exists {
+		return nil, repository.ErrScheduleNotFound
+	}
+
+	return cloneSchedule(schedule), nil
+}
+
+func (r *ScheduleRepository) ListSchedules(_ context.Context, userID string) ([]*domain.Schedule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var schedules []*domain.Schedule
+	for _, schedule := range r.store {
+		if userID != "" && schedule.UserID != userID {
+			continue
+		}
+
+		schedules = append(schedules, cloneSchedule(schedule))
+	}
+
+	sort.Slice(schedules, func(i, j int) bool {
+		if !schedules[i].CreatedAt.Equal(schedules[j].CreatedAt) {
+			return schedules[i].CreatedAt.Before(schedules[j].CreatedAt)
+		}
+		return schedules[i].ID < schedules[j].ID
+	})
+
+	return schedules, nil
+}
+
+func (r *ScheduleRepository) DeleteSchedule(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.store[id]; !exists {
+		return repository.ErrScheduleNotFound
+	}
+
+	delete(r.store, id)
+	return nil
+}
+
+func (r *ScheduleRepository) ListDueSchedules(_ context.Context, now time.Time, limit int) ([]*domain.Schedule, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var due []*domain.Schedule
+	for _, schedule := range r.store {
+		if schedule.Enabled && !schedule.NextRunAt.After(now) {
+			due = append(due, cloneSchedule(schedule))
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextRunAt.Before(due[j].NextRunAt)
+	})
+
+	if limit > 0 && len(due) > limit {
+		due = due[:limit]
+	}
+
+	return due, nil
+}
+
+func (r *ScheduleRepository) AdvanceSchedule(_ context.Context, schedule *domain.Schedule, previousNextRunAt time.Time) (bool, error) {
+	if schedule == nil {
+		return false, fmt.Errorf("schedule is nil")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, exists := r.store[schedule.ID]
+	if !exists {
+		return false, repository.ErrScheduleNotFound
+	}
+
+	if !stored.NextRunAt.Equal(previousNextRunAt) {
+		return false, nil
+	}
+
+	advanced := cloneSchedule(stored)
+	advanced.NextRunAt = schedule.NextRunAt
+	advanced.LastRunAt = schedule.LastRunAt
+	advanced.UpdatedAt = schedule.UpdatedAt
+	r.store[schedule.ID] = cloneSchedule(advanced)
+	return true, nil
+}
+
+func cloneSchedule(src *domain.Schedule) *domain.Schedule {
+	clone := *src
+
+	if src.LastRunAt != nil {
+		lastRunAt := *src.LastRunAt
+		clone.LastRunAt = &lastRunAt
+	}
+
+	return &clone
+}
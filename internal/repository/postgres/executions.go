@@ -0,0 +1,330 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"Code_executor/internal/domain"
+	"Code_executor/internal/pgtx"
+	"Code_executor/internal/repository"
+	"Code_executor/internal/repository/schema"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// uniqueViolation is the SQLSTATE of a duplicate primary key.
+const uniqueViolation = "23505"
+
+// ExecutionRepository stores executions in the executions table. It gets its
+// connection from pgtx.Conn, so it takes part in the caller's transaction.
+type ExecutionRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewExecutionRepository(pool *pgxpool.Pool) (*ExecutionRepository, error) {
+	if pool == nil {
+		return nil, pgtx.ErrNilPool
+	}
+
+	return &ExecutionRepository{pool: pool}, nil
+}
+
+const executionColumns = `
+	id, language, files, entrypoint, stdin, timeout_ms, status,
+	stdout, stderr, exit_code, stdout_truncated, stderr_truncated,
+	compile_stdout, compile_stderr, compile_exit_code,
+	test_cases, checker, test_results, verdict,
+	created_at, run_at, started_at, finished_at,
+	user_id, schedule_id, priority,
+	memory_limit_bytes, pids_limit, cpu_limit_millis, violations,
+	wall_time_ms, user_cpu_time_ms, system_cpu_time_ms,
+	peak_memory_bytes, io_read_bytes, io_write_bytes,
+	attempts, failure_reason, failure_detail, worker_id`
+
+const insertExecutionQuery = `
+INSERT INTO executions (` + executionColumns + `)
+VALUES (
+	$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17,
+	$18, $19, $20, $21, $22, $23, $24, $25, $26, $27, $28, $29, $30, $31, $32,
+	$33, $34, $35, $36, $37, $38, $39, $40
+)`
+
+// executionAssignments sets every column but id from the parameters of
+// insertExecutionQuery.
+const executionAssignments = `
+	language = $2, files = $3, entrypoint = $4, stdin = $5, timeout_ms = $6,
+	status = $7, stdout = $8, stderr = $9, exit_code = $10,
+	stdout_truncated = $11, stderr_truncated = $12,
+	compile_stdout = $13, compile_stderr = $14, compile_exit_code = $15,
+	test_cases = $16, checker = $17, test_results = $18, verdict = $19,
+	created_at = $20, run_at = $21, started_at = $22, finished_at = $23,
+	user_id = $24, schedule_id = $25, priority = $26,
+	memory_limit_bytes = $27, pids_limit = $28, cpu_limit_millis = $29,
+	violations = $30, wall_time_ms = $31, user_cpu_time_ms = $32,
+	system_cpu_time_ms = $33, peak_memory_bytes = $34, io_read_bytes = $35,
+	io_write_bytes = $36, attempts = $37, failure_reason = $38,
+	failure_detail = $39, worker_id = $40`
+
+// updateExecutionQuery leaves rows in a final status alone; $41 lists the
+// final statuses.
+const updateExecutionQuery = `UPDATE executions SET` + executionAssignments + `
+WHERE id = $1 AND NOT (status = ANY($41))`
+
+const reopenExecutionQuery = `UPDATE executions SET` + executionAssignments + `
+WHERE id = $1`
+
+// listExecutionsQuery treats an empty filter field as matching everything;
+// LIMIT NULL does not cap the result. $5 and $6 are the cursor, with $6 empty
+// for none.
+const listExecutionsQuery = `
+SELECT ` + executionColumns + `
+FROM executions
+WHERE ($1 = '' OR user_id = $1) AND ($2 = '' OR schedule_id = $2) AND ($3 = '' OR status = $3)
+	AND ($6 = '' OR (created_at, id) < ($5::timestamptz, $6))
+ORDER BY created_at DESC, id DESC
+LIMIT NULLIF($4, 0)`
+
+func (r *ExecutionRepository) CreateExecution(ctx context.Context, exec *domain.Execution) error {
+	if exec == nil {
+		return fmt.Errorf("execution is nil")
+	}
+
+	args, err := executionArgs(exec)
+	if err != nil {
+		return err
+	}
+
+	_, err = pgtx.Conn(ctx, r.pool).Exec(ctx, insertExecutionQuery, args...)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return fmt.Errorf("execution with id %s already exists", exec.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("insert execution: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ExecutionRepository) UpdateExecution(ctx context.Context, exec *domain.Execution) error {
+	if exec == nil {
+		return fmt.Errorf("execution is nil")
+	}
+
+	args, err := executionArgs(exec)
+	if err != nil {
+		return err
+	}
+
+	conn := pgtx.Conn(ctx, r.pool)
+	tag, err := conn.Exec(ctx, updateExecutionQuery, append(args, finalStatuses())...)
+	if err != nil {
+		return fmt.Errorf("update execution: %w", err)
+	}
+
+	if tag.RowsAffected() > 0 {
+		return nil
+	}
+
+	exists, err := executionExists(ctx, conn, exec.ID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return repository.ErrExecutionNotFound
+	}
+
+	return repository.ErrExecutionFinished
+}
+
+func (r *ExecutionRepository) ReopenExecution(ctx context.Context, exec *domain.Execution) error {
+	if exec == nil {
+		return fmt.Errorf("execution is nil")
+	}
+
+	args, err := executionArgs(exec)
+	if err != nil {
+		return err
+	}
+
+	tag, err := pgtx.Conn(ctx, r.pool).Exec(ctx, reopenExecutionQuery, args...)
+	if err != nil {
+		return fmt.Errorf("reopen execution: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repository.ErrExecutionNotFound
+	}
+
+	return nil
+}
+
+func (r *ExecutionRepository) GetExecutionByID(ctx context.Context, id string) (*domain.Execution, error) {
+	row := pgtx.Conn(ctx, r.pool).QueryRow(ctx, `SELECT `+executionColumns+` FROM executions WHERE id = $1`, id)
+
+	exec, err := scanExecution(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.ErrExecutionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get execution: %w", err)
+	}
+
+	return exec, nil
+}
+
+func (r *ExecutionRepository) ListExecutions(ctx context.Context, filter repository.ExecutionFilter) ([]*domain.Execution, error) {
+	rows, err := pgtx.Conn(ctx, r.pool).Query(ctx, listExecutionsQuery,
+		filter.UserID, filter.ScheduleID, string(filter.Status), max(filter.Limit, 0),
+		filter.After.CreatedAt, filter.After.ID)
+	if err != nil {
+		return nil, fmt.Errorf("list executions: %w", err)
+	}
+	defer rows.Close()
+
+	var execs []*domain.Execution
+	for rows.Next() {
+		exec, err := scanExecution(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list executions: %w", err)
+		}
+		execs = append(execs, exec)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list executions: %w", err)
+	}
+
+	return execs, nil
+}
+
+func executionExists(ctx context.Context, conn pgtx.Querier, id string) (bool, error) {
+	var exists bool
+	err := conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM executions WHERE id = $1)`, id).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check execution: %w", err)
+	}
+
+	return exists, nil
+}
+
+// executionArgs returns the values of executionColumns for exec, in order.
+// Program output is stored as schema.ValidText.
+func executionArgs(exec *domain.Execution) ([]any, error) {
+	files, err := schema.EncodeFiles(exec.Files)
+	if err != nil {
+		return nil, fmt.Errorf("encode files: %w", err)
+	}
+
+	testCases, err := schema.EncodeTestCases(exec.TestCases)
+	if err != nil {
+		return nil, fmt.Errorf("encode test cases: %w", err)
+	}
+
+	checker, err := schema.EncodeChecker(exec.Checker)
+	if err != nil {
+		return nil, fmt.Errorf("encode checker: %w", err)
+	}
+
+	testResults, err := schema.EncodeTestResults(exec.TestResults)
+	if err != nil {
+		return nil, fmt.Errorf("encode test results: %w", err)
+	}
+
+	violations := make([]string, len(exec.Violations))
+	for i, violation := range exec.Violations {
+		violations[i] = string(violation)
+	}
+
+	return []any{
+		exec.ID, exec.Language, files, exec.Entrypoint, exec.Stdin, exec.TimeoutMs, string(exec.Status),
+		schema.ValidText(exec.Stdout), schema.ValidText(exec.Stderr), exec.ExitCode, exec.StdoutTruncated, exec.StderrTruncated,
+		schema.ValidText(exec.CompileStdout), schema.ValidText(exec.CompileStderr), exec.CompileExitCode,
+		testCases, checker, testResults, string(exec.Verdict),
+		exec.CreatedAt, exec.RunAt, exec.StartedAt, exec.FinishedAt,
+		exec.UserID, exec.ScheduleID, exec.Priority,
+		exec.Limits.MemoryBytes, exec.Limits.Pids, exec.Limits.CPUMillis, violations,
+		exec.Usage.WallTimeMs, exec.Usage.UserCPUTimeMs, exec.Usage.SystemCPUTimeMs,
+		exec.Usage.PeakMemoryBytes, exec.Usage.IOReadBytes, exec.Usage.IOWriteBytes,
+		exec.Attempts, string(exec.FailureReason), schema.ValidText(exec.FailureDetail), exec.WorkerID,
+	}, nil
+}
+
+func scanExecution(row pgx.Row) (*domain.Execution, error) {
+	var (
+		exec                               domain.Execution
+		status, verdict, failureReason     string
+		files, testCases, checker, results []byte
+		violations                         []string
+	)
+
+	err := row.Scan(
+		&exec.ID, &exec.Language, &files, &exec.Entrypoint, &exec.Stdin, &exec.TimeoutMs, &status,
+		&exec.Stdout, &exec.Stderr, &exec.ExitCode, &exec.StdoutTruncated, &exec.StderrTruncated,
+		&exec.CompileStdout, &exec.CompileStderr, &exec.CompileExitCode,
+		&testCases, &checker, &results, &verdict,
+		&exec.CreatedAt, &exec.RunAt, &exec.StartedAt, &exec.FinishedAt,
+		&exec.UserID, &exec.ScheduleID, &exec.Priority,
+		&exec.Limits.MemoryBytes, &exec.Limits.Pids, &exec.Limits.CPUMillis, &violations,
+		&exec.Usage.WallTimeMs, &exec.Usage.UserCPUTimeMs, &exec.Usage.SystemCPUTimeMs,
+		&exec.Usage.PeakMemoryBytes, &exec.Usage.IOReadBytes, &exec.Usage.IOWriteBytes,
+		&exec.Attempts, &failureReason, &exec.FailureDetail, &exec.WorkerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	exec.Status = domain.ExecutionStatus(status)
+	exec.Verdict = domain.Verdict(verdict)
+	exec.FailureReason = domain.FailureReason(failureReason)
+
+	if exec.Files, err = schema.DecodeFiles(files); err != nil {
+		return nil, fmt.Errorf("decode files: %w", err)
+	}
+
+	if exec.TestCases, err = schema.DecodeTestCases(testCases); err != nil {
+		return nil, fmt.Errorf("decode test cases: %w", err)
+	}
+
+	if exec.Checker, err = schema.DecodeChecker(checker); err != nil {
+		return nil, fmt.Errorf("decode checker: %w", err)
+	}
+
+	if exec.TestResults, err = schema.DecodeTestResults(results); err != nil {
+		return nil, fmt.Errorf("decode test results: %w", err)
+	}
+
+	if len(violations) > 0 {
+		exec.Violations = make([]domain.ResourceViolation, len(violations))
+		for i, violation := range violations {
+			exec.Violations[i] = domain.ResourceViolation(violation)
+		}
+	}
+
+	exec.CreatedAt = exec.CreatedAt.UTC()
+	exec.RunAt = utc(exec.RunAt)
+	exec.StartedAt = utc(exec.StartedAt)
+	exec.FinishedAt = utc(exec.FinishedAt)
+
+	return &exec, nil
+}
+
+func finalStatuses() []string {
+	var statuses []string
+	for _, status := range domain.FinalStatuses() {
+		statuses = append(statuses, string(status))
+	}
+	return statuses
+}
+
+func utc(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
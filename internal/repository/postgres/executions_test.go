@@ -0,0 +1,51 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"Code_executor/internal/repository"
+	"Code_executor/internal/repository/repositorytest"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// testDSNEnv names the database the tests migrate and empty; they are skipped
+// without it.
+const testDSNEnv = "TEST_POSTGRES_DSN"
+
+func TestExecutionRepository(t *testing.T) {
+	dsn := os.Getenv(testDSNEnv)
+	if dsn == "" {
+		t.Skipf("%s is not set", testDSNEnv)
+	}
+
+	ctx := context.Background()
+
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	migrator, err := NewMigrator(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	repo, err := NewExecutionRepository(pool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repositorytest.TestExecutionRepository(t, func(t *testing.T) repository.ExecutionRepository {
+		if _, err := pool.Exec(ctx, `TRUNCATE executions`); err != nil {
+			t.Fatal(err)
+		}
+		return repo
+	})
+}
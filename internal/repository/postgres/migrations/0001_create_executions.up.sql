@@ -0,0 +1,45 @@
+CREATE TABLE executions (
+	id                 TEXT PRIMARY KEY,
+	language           TEXT NOT NULL,
+	files              JSONB NOT NULL DEFAULT '{}',
+	entrypoint         TEXT NOT NULL,
+	stdin              TEXT NOT NULL DEFAULT '',
+	timeout_ms         INTEGER NOT NULL,
+	status             TEXT NOT NULL,
+	stdout             TEXT NOT NULL DEFAULT '',
+	stderr             TEXT NOT NULL DEFAULT '',
+	exit_code          INTEGER,
+	stdout_truncated   BOOLEAN NOT NULL DEFAULT false,
+	stderr_truncated   BOOLEAN NOT NULL DEFAULT false,
+	compile_stdout     TEXT NOT NULL DEFAULT '',
+	compile_stderr     TEXT NOT NULL DEFAULT '',
+	compile_exit_code  INTEGER,
+	test_cases         JSONB NOT NULL DEFAULT '[]',
+	checker            JSONB NOT NULL DEFAULT '{}',
+	test_results       JSONB NOT NULL DEFAULT '[]',
+	verdict            TEXT NOT NULL DEFAULT '',
+	created_at         TIMESTAMPTZ NOT NULL,
+	run_at             TIMESTAMPTZ,
+	started_at         TIMESTAMPTZ,
+	finished_at        TIMESTAMPTZ,
+	user_id            TEXT NOT NULL,
+	schedule_id        TEXT NOT NULL DEFAULT '',
+	priority           SMALLINT NOT NULL DEFAULT 0,
+	memory_limit_bytes BIGINT NOT NULL DEFAULT 0,
+	pids_limit         INTEGER NOT NULL DEFAULT 0,
+	cpu_limit_millis   INTEGER NOT NULL DEFAULT 0,
+	violations         TEXT[] NOT NULL DEFAULT '{}',
+	wall_time_ms       BIGINT NOT NULL DEFAULT 0,
+	user_cpu_time_ms   BIGINT NOT NULL DEFAULT 0,
+	system_cpu_time_ms BIGINT NOT NULL DEFAULT 0,
+	peak_memory_bytes  BIGINT NOT NULL DEFAULT 0,
+	io_read_bytes      BIGINT NOT NULL DEFAULT 0,
+	io_write_bytes     BIGINT NOT NULL DEFAULT 0,
+	attempts           INTEGER NOT NULL DEFAULT 0,
+	failure_reason     TEXT NOT NULL DEFAULT '',
+	failure_detail     TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX executions_user_id_idx ON executions (user_id, created_at DESC, id DESC);
+CREATE INDEX executions_status_idx ON executions (status, created_at DESC, id DESC);
+CREATE INDEX executions_schedule_id_idx ON executions (schedule_id, created_at DESC, id DESC) WHERE schedule_id <> '';
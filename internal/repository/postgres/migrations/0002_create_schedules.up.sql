@@ -0,0 +1,19 @@
+CREATE TABLE schedules (
+	id                TEXT PRIMARY KEY,
+	user_id           TEXT NOT NULL,
+	language          TEXT NOT NULL,
+	code              TEXT NOT NULL,
+	stdin             TEXT NOT NULL DEFAULT '',
+	timeout_ms        INTEGER NOT NULL,
+	priority          SMALLINT NOT NULL DEFAULT 0,
+	cron              TEXT NOT NULL,
+	missed_run_policy TEXT NOT NULL,
+	enabled           BOOLEAN NOT NULL DEFAULT true,
+	next_run_at       TIMESTAMPTZ NOT NULL,
+	last_run_at       TIMESTAMPTZ,
+	created_at        TIMESTAMPTZ NOT NULL,
+	updated_at        TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX schedules_user_id_idx ON schedules (user_id, created_at, id);
+CREATE INDEX schedules_next_run_at_idx ON schedules (next_run_at) WHERE enabled;
@@ -0,0 +1,4 @@
+DROP TABLE queue_workers;
+DROP TABLE queue_scheduled;
+DROP TABLE queue_dead_letters;
+DROP TABLE queue_jobs;
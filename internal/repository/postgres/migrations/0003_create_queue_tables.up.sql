@@ -0,0 +1,48 @@
+-- A job is claimable when run_at has passed and it is not locked, or its lock
+-- expired because the worker holding it stopped extending it.
+CREATE TABLE queue_jobs (
+	id                 BIGSERIAL PRIMARY KEY,
+	execution_id       TEXT NOT NULL,
+	language           TEXT NOT NULL DEFAULT '',
+	user_id            TEXT NOT NULL DEFAULT '',
+	priority           SMALLINT NOT NULL DEFAULT 0,
+	-- priority plus the levels gained by waiting, raised by the consumers
+	effective_priority INTEGER NOT NULL DEFAULT 0,
+	attempts           INTEGER NOT NULL DEFAULT 0,
+	run_at             TIMESTAMPTZ NOT NULL DEFAULT now(),
+	locked_by          TEXT,
+	locked_until       TIMESTAMPTZ,
+	claim_token        TEXT,
+	created_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX queue_jobs_run_at_idx ON queue_jobs (run_at, id);
+CREATE UNIQUE INDEX queue_jobs_claim_token_idx ON queue_jobs (claim_token);
+CREATE INDEX queue_jobs_claim_idx ON queue_jobs (effective_priority DESC, priority DESC, run_at, id);
+CREATE INDEX queue_jobs_language_claim_idx ON queue_jobs (language, effective_priority DESC, priority DESC, run_at, id);
+
+CREATE TABLE queue_dead_letters (
+	execution_id TEXT PRIMARY KEY,
+	language     TEXT NOT NULL DEFAULT '',
+	user_id      TEXT NOT NULL DEFAULT '',
+	priority     SMALLINT NOT NULL DEFAULT 0,
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	reason       TEXT NOT NULL DEFAULT '',
+	failed_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE queue_scheduled (
+	execution_id TEXT PRIMARY KEY,
+	language     TEXT NOT NULL DEFAULT '',
+	user_id      TEXT NOT NULL DEFAULT '',
+	priority     SMALLINT NOT NULL DEFAULT 0,
+	run_at       TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX queue_scheduled_run_at_idx ON queue_scheduled (run_at);
+
+CREATE TABLE queue_workers (
+	id        TEXT PRIMARY KEY,
+	languages TEXT[] NOT NULL DEFAULT '{}',
+	seen_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
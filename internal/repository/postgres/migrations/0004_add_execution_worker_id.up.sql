@@ -0,0 +1 @@
+ALTER TABLE executions ADD COLUMN worker_id TEXT NOT NULL DEFAULT '';
@@ -0,0 +1 @@
+DROP INDEX executions_created_at_idx;
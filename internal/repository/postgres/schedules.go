@@ -0,0 +1,213 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"Code_executor/internal/domain"
+	"Code_executor/internal/pgtx"
+	"Code_executor/internal/repository"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ScheduleRepository struct {
+	pool *pgxpool.Pool
+}
+
+func NewScheduleRepository(pool *pgxpool.Pool) (*ScheduleRepository, error) {
+	if pool == nil {
+		return nil, pgtx.ErrNilPool
+	}
+
+	return &ScheduleRepository{pool: pool}, nil
+}
+
+const scheduleColumns = `
+	id, user_id, language, code, stdin, timeout_ms, priority,
+	cron, missed_run_policy, enabled,
+	next_run_at, last_run_at, created_at, updated_at`
+
+const insertScheduleQuery = `
+INSERT INTO schedules (` + scheduleColumns + `)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`
+
+const updateScheduleQuery = `
+UPDATE schedules SET
+	user_id = $2, language = $3, code = $4, stdin = $5, timeout_ms = $6,
+	priority = $7, cron = $8, missed_run_policy = $9, enabled = $10,
+	next_run_at = $11, last_run_at = $12, created_at = $13, updated_at = $14
+WHERE id = $1`
+
+// advanceScheduleQuery only moves a schedule whose next run is still the one
+// the caller ran.
+const advanceScheduleQuery = `
+UPDATE schedules SET next_run_at = $2, last_run_at = $3, updated_at = $4
+WHERE id = $1 AND next_run_at = $5`
+
+func (r *ScheduleRepository) CreateSchedule(ctx context.Context, schedule *domain.Schedule) error {
+	if schedule == nil {
+		return fmt.Errorf("schedule is nil")
+	}
+
+	_, err := pgtx.Conn(ctx, r.pool).Exec(ctx, insertScheduleQuery, scheduleArgs(schedule)...)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+		return fmt.Errorf("schedule with id %s already exists", schedule.ID)
+	}
+	if err != nil {
+		return fmt.Errorf("insert schedule: %w", err)
+	}
+
+	return nil
+}
+
+func (r *ScheduleRepository) UpdateSchedule(ctx context.Context, schedule *domain.Schedule) error {
+	if schedule == nil {
+		return fmt.Errorf("schedule is nil")
+	}
+
+	tag, err := pgtx.Conn(ctx, r.pool).Exec(ctx, updateScheduleQuery, scheduleArgs(schedule)...)
+	if err != nil {
+		return fmt.Errorf("update schedule: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repository.ErrScheduleNotFound
+	}
+
+	return nil
+}
+
+func (r *ScheduleRepository) GetScheduleByID(ctx context.Context, id string) (*domain.Schedule, error) {
+	row := pgtx.Conn(ctx, r.pool).QueryRow(ctx, `SELECT `+scheduleColumns+` FROM schedules WHERE id = $1`, id)
+
+	schedule, err := scanSchedule(row)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, repository.ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+func (r *ScheduleRepository) ListSchedules(ctx context.Context, userID string) ([]*domain.Schedule, error) {
+	return r.listSchedules(ctx, `
+SELECT `+scheduleColumns+`
+FROM schedules
+WHERE $1 = '' OR user_id = $1
+ORDER BY created_at, id`, userID)
+}
+
+func (r *ScheduleRepository) DeleteSchedule(ctx context.Context, id string) error {
+	tag, err := pgtx.Conn(ctx, r.pool).Exec(ctx, `DELETE FROM schedules WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete schedule: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return repository.ErrScheduleNotFound
+	}
+
+	return nil
+}
+
+func (r *ScheduleRepository) ListDueSchedules(ctx context.Context, now time.Time, limit int) ([]*domain.Schedule, error) {
+	return r.listSchedules(ctx, `
+SELECT `+scheduleColumns+`
+FROM schedules
+WHERE enabled AND next_run_at <= $1
+ORDER BY next_run_at
+LIMIT NULLIF($2, 0)`, now, max(limit, 0))
+}
+
+func (r *ScheduleRepository) AdvanceSchedule(ctx context.Context, schedule *domain.Schedule, previousNextRunAt time.Time) (bool, error) {
+	if schedule == nil {
+		return false, fmt.Errorf("schedule is nil")
+	}
+
+	conn := pgtx.Conn(ctx, r.pool)
+	tag, err := conn.Exec(ctx, advanceScheduleQuery,
+		schedule.ID, schedule.NextRunAt, schedule.LastRunAt, schedule.UpdatedAt, previousNextRunAt)
+	if err != nil {
+		return false, fmt.Errorf("advance schedule: %w", err)
+	}
+
+	if tag.RowsAffected() > 0 {
+		return true, nil
+	}
+
+	var exists bool
+	err = conn.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM schedules WHERE id = $1)`, schedule.ID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check schedule: %w", err)
+	}
+	if !exists {
+		return false, repository.ErrScheduleNotFound
+	}
+
+	return false, nil
+}
+
+func (r *ScheduleRepository) listSchedules(ctx context.Context, query string, args ...any) ([]*domain.Schedule, error) {
+	rows, err := pgtx.Conn(ctx, r.pool).Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []*domain.Schedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list schedules: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+func scheduleArgs(schedule *domain.Schedule) []any {
+	return []any{
+		schedule.ID, schedule.UserID, schedule.Language, schedule.Code, schedule.Stdin,
+		schedule.TimeoutMs, schedule.Priority,
+		schedule.Cron, string(schedule.MissedRunPolicy), schedule.Enabled,
+		schedule.NextRunAt, schedule.LastRunAt, schedule.CreatedAt, schedule.UpdatedAt,
+	}
+}
+
+func scanSchedule(row pgx.Row) (*domain.Schedule, error) {
+	var (
+		schedule        domain.Schedule
+		missedRunPolicy string
+	)
+
+	err := row.Scan(
+		&schedule.ID, &schedule.UserID, &schedule.Language, &schedule.Code, &schedule.Stdin,
+		&schedule.TimeoutMs, &schedule.Priority,
+		&schedule.Cron, &missedRunPolicy, &schedule.Enabled,
+		&schedule.NextRunAt, &schedule.LastRunAt, &schedule.CreatedAt, &schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.MissedRunPolicy = domain.MissedRunPolicy(missedRunPolicy)
+	schedule.NextRunAt = schedule.NextRunAt.UTC()
+	schedule.LastRunAt = utc(schedule.LastRunAt)
+	schedule.CreatedAt = schedule.CreatedAt.UTC()
+	schedule.UpdatedAt = schedule.UpdatedAt.UTC()
+
+	return &schedule, nil
+}
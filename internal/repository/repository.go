@@ -4,14 +4,67 @@ import (
 	"Code_executor/internal/domain"
 	"context"
 	"errors"
+	"time"
 )
 
 type ExecutionRepository interface {
 	CreateExecution(ctx context.Context, exec *domain.Execution) error
 	UpdateExecution(ctx context.Context, exec *domain.Execution) error
 	GetExecutionByID(ctx context.Context, id string) (*domain.Execution, error)
+	// ReopenExecution stores an execution taken out of a final status, which
+	// UpdateExecution refuses; see domain.Execution.Redrive.
+	ReopenExecution(ctx context.Context, exec *domain.Execution) error
+	// ListExecutions returns the executions matching filter, newest first.
+	ListExecutions(ctx context.Context, filter ExecutionFilter) ([]*domain.Execution, error)
+}
+
+// ExecutionFilter selects executions; zero fields match all of them.
+type ExecutionFilter struct {
+	UserID     string
+	ScheduleID string
+	Status     domain.ExecutionStatus
+	// Limit caps the number of executions returned; 0 means no cap.
+	Limit int
+	// After continues a listing past the execution it names, e.g. the last
+	// one of the previous page.
+	After ExecutionCursor
+}
+
+// ExecutionCursor is the position of an execution in the newest-first order
+// of ListExecutions: by CreatedAt, then by descending ID.
+type ExecutionCursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// IsZero reports whether the cursor names no execution.
+func (c ExecutionCursor) IsZero() bool {
+	return c.ID == ""
+}
+
+type ScheduleRepository interface {
+	CreateSchedule(ctx context.Context, schedule *domain.Schedule) error
+	UpdateSchedule(ctx context.Context, schedule *domain.Schedule) error
+	GetScheduleByID(ctx context.Context, id string) (*domain.Schedule, error)
+	// ListSchedules returns the schedules of a user, or of all users if
+	// userID is empty, oldest first.
+	ListSchedules(ctx context.Context, userID string) ([]*domain.Schedule, error)
+	DeleteSchedule(ctx context.Context, id string) error
+	// ListDueSchedules returns up to limit enabled schedules whose NextRunAt
+	// is not after now, most overdue first.
+	ListDueSchedules(ctx context.Context, now time.Time, limit int) ([]*domain.Schedule, error)
+	// AdvanceSchedule stores the run times of a schedule moved on by
+	// domain.Schedule.Advance if its stored NextRunAt is still
+	// previousNextRunAt, and reports whether it did. Of schedulers racing for the same run, only the one
+	// that advanced the schedule runs it.
+	AdvanceSchedule(ctx context.Context, schedule *domain.Schedule, previousNextRunAt time.Time) (bool, error)
 }
 
 var (
 	ErrExecutionNotFound = errors.New("execution not found")
+	// ErrExecutionFinished is returned by UpdateExecution when the stored
+	// execution already reached a final status, e.g. it was cancelled while
+	// a worker was running it.
+	ErrExecutionFinished = errors.New("execution already finished")
+	ErrScheduleNotFound  = errors.New("schedule not found")
 )
@@ -0,0 +1,334 @@
+// Package repositorytest checks that implementations of the repository
+// interfaces behave alike.
+package repositorytest
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/repository"
+	"Code_executor/internal/repository/schema"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestExecutionRepository runs the contract of repository.ExecutionRepository
+// against the repositories newRepo returns, which must be empty.
+func TestExecutionRepository(t *testing.T, newRepo func(t *testing.T) repository.ExecutionRepository) {
+	t.Run("GetMissing", func(t *testing.T) {
+		_, err := newRepo(t).GetExecutionByID(context.Background(), "missing")
+		if !errors.Is(err, repository.ErrExecutionNotFound) {
+			t.Fatalf("GetExecutionByID = %v, want %v", err, repository.ErrExecutionNotFound)
+		}
+	})
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		exec := fullExecution(t, "exec-1", 1)
+		create(t, repo, exec)
+		assertStored(t, repo, exec)
+
+		if err := repo.CreateExecution(ctx, exec); err == nil {
+			t.Error("CreateExecution of a duplicate id succeeded")
+		}
+
+		// Every field is written by an update too.
+		updated := fullExecution(t, "exec-1", 2)
+		if err := repo.UpdateExecution(ctx, updated); err != nil {
+			t.Fatal(err)
+		}
+		assertStored(t, repo, updated)
+	})
+
+	t.Run("UpdateMissing", func(t *testing.T) {
+		exec := newExecution(t, "missing", "alice", base)
+		err := newRepo(t).UpdateExecution(context.Background(), exec)
+		if !errors.Is(err, repository.ErrExecutionNotFound) {
+			t.Fatalf("UpdateExecution = %v, want %v", err, repository.ErrExecutionNotFound)
+		}
+	})
+
+	t.Run("UpdateFinished", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		exec := newExecution(t, "exec-1", "alice", base)
+		create(t, repo, exec)
+
+		if err := exec.MarkRunning(base.Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.UpdateExecution(ctx, exec); err != nil {
+			t.Fatalf("UpdateExecution of a running execution = %v", err)
+		}
+
+		if err := exec.MarkCompleted("out", "", 0, base.Add(2*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		if err := repo.UpdateExecution(ctx, exec); err != nil {
+			t.Fatalf("UpdateExecution of the completion = %v", err)
+		}
+
+		// A late update, e.g. of a cancelled execution's worker, is refused.
+		late := *exec
+		late.Status = domain.ExecutionStatusCancelled
+		if err := repo.UpdateExecution(ctx, &late); !errors.Is(err, repository.ErrExecutionFinished) {
+			t.Fatalf("UpdateExecution of a finished execution = %v, want %v", err, repository.ErrExecutionFinished)
+		}
+
+		got, err := repo.GetExecutionByID(ctx, exec.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status != domain.ExecutionStatusCompleted || got.Stdout != "out" {
+			t.Errorf("stored execution is %s with stdout %q, want completed with %q", got.Status, got.Stdout, "out")
+		}
+
+		// ReopenExecution overwrites final statuses.
+		got.Status = domain.ExecutionStatusQueued
+		if err := repo.ReopenExecution(ctx, got); err != nil {
+			t.Fatalf("ReopenExecution = %v", err)
+		}
+		if err := repo.UpdateExecution(ctx, got); err != nil {
+			t.Fatalf("UpdateExecution of a reopened execution = %v", err)
+		}
+	})
+
+	t.Run("BinaryOutput", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		exec := newExecution(t, "exec-1", "alice", base)
+		create(t, repo, exec)
+
+		// Output is whatever bytes the program wrote; a repository may store
+		// it as schema.ValidText, but must not refuse it.
+		binary := "out\x00\xff\xfe\x80put"
+		if err := exec.MarkRunning(base.Add(time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		exec.CompileStdout, exec.CompileStderr = binary, binary
+		exec.TestResults = []domain.TestCaseResult{{Verdict: domain.VerdictAccepted, Stdout: binary, Stderr: binary}}
+		if err := exec.MarkRuntimeError(binary, binary, 1, base.Add(2*time.Second)); err != nil {
+			t.Fatal(err)
+		}
+		exec.FailureDetail = binary
+		if err := repo.UpdateExecution(ctx, exec); err != nil {
+			t.Fatalf("UpdateExecution with binary output = %v", err)
+		}
+
+		got, err := repo.GetExecutionByID(ctx, exec.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := schema.ValidText(binary)
+		for name, value := range map[string]string{
+			"stdout":             got.Stdout,
+			"stderr":             got.Stderr,
+			"compile stdout":     got.CompileStdout,
+			"compile stderr":     got.CompileStderr,
+			"failure detail":     got.FailureDetail,
+			"test result stdout": got.TestResults[0].Stdout,
+			"test result stderr": got.TestResults[0].Stderr,
+		} {
+			if schema.ValidText(value) != want {
+				t.Errorf("stored %s = %q, want %q", name, value, want)
+			}
+		}
+	})
+
+	t.Run("List", func(t *testing.T) {
+		ctx := context.Background()
+		repo := newRepo(t)
+
+		// exec-b and exec-c share a creation time, so the id breaks the tie.
+		for _, exec := range []*domain.Execution{
+			newExecution(t, "exec-a", "alice", base),
+			newExecution(t, "exec-c", "bob", base.Add(time.Second)),
+			newExecution(t, "exec-b", "alice", base.Add(time.Second)),
+			newExecution(t, "exec-d", "alice", base.Add(2*time.Second)),
+			newExecution(t, "exec-e", "bob", base.Add(3*time.Second)),
+		} {
+			if exec.ID == "exec-a" || exec.ID == "exec-e" {
+				exec.ScheduleID = "schedule-1"
+			}
+			if exec.ID == "exec-d" {
+				if err := exec.MarkCancelled(base.Add(4 * time.Second)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			create(t, repo, exec)
+		}
+
+		for _, test := range []struct {
+			filter repository.ExecutionFilter
+			want   []string
+		}{
+			{repository.ExecutionFilter{}, []string{"exec-e", "exec-d", "exec-c", "exec-b", "exec-a"}},
+			{repository.ExecutionFilter{Limit: 3}, []string{"exec-e", "exec-d", "exec-c"}},
+			{repository.ExecutionFilter{Limit: 10}, []string{"exec-e", "exec-d", "exec-c", "exec-b", "exec-a"}},
+			{repository.ExecutionFilter{UserID: "alice"}, []string{"exec-d", "exec-b", "exec-a"}},
+			{repository.ExecutionFilter{UserID: "alice", Limit: 2}, []string{"exec-d", "exec-b"}},
+			{repository.ExecutionFilter{ScheduleID: "schedule-1"}, []string{"exec-e", "exec-a"}},
+			{repository.ExecutionFilter{Status: domain.ExecutionStatusQueued}, []string{"exec-e", "exec-c", "exec-b", "exec-a"}},
+			{repository.ExecutionFilter{UserID: "alice", Status: domain.ExecutionStatusCancelled}, []string{"exec-d"}},
+			{repository.ExecutionFilter{UserID: "carol"}, nil},
+			{repository.ExecutionFilter{After: cursor(base.Add(2*time.Second), "exec-d")}, []string{"exec-c", "exec-b", "exec-a"}},
+			{repository.ExecutionFilter{After: cursor(base.Add(time.Second), "exec-c"), Limit: 1}, []string{"exec-b"}},
+			{repository.ExecutionFilter{After: cursor(base.Add(time.Second), "exec-b")}, []string{"exec-a"}},
+			{repository.ExecutionFilter{After: cursor(base, "exec-a")}, nil},
+			{repository.ExecutionFilter{UserID: "alice", After: cursor(base.Add(3*time.Second), "exec-e")}, []string{"exec-d", "exec-b", "exec-a"}},
+			// The cursor need not name a stored execution.
+			{repository.ExecutionFilter{After: cursor(base.Add(time.Second), "exec-bb")}, []string{"exec-b", "exec-a"}},
+		} {
+			t.Run(fmt.Sprintf("%+v", test.filter), func(t *testing.T) {
+				execs, err := repo.ListExecutions(ctx, test.filter)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				var got []string
+				for _, exec := range execs {
+					got = append(got, exec.ID)
+				}
+				if !slices.Equal(got, test.want) {
+					t.Errorf("ListExecutions = %v, want %v", got, test.want)
+				}
+			})
+		}
+	})
+}
+
+// base is whole seconds, which every backend stores exactly.
+var base = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newExecution(t *testing.T, id, userID string, createdAt time.Time) *domain.Execution {
+	t.Helper()
+
+	name := domain.LanguageNames()[0]
+	language, _ := domain.GetLanguage(name)
+
+	exec, err := domain.NewExecution(id, name, map[string]string{language.SourceFile: "code"}, "", "", 1000, userID, createdAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return exec
+}
+
+// fullExecution returns an execution with every field set, to values that
+// differ with n.
+func fullExecution(t *testing.T, id string, n int) *domain.Execution {
+	t.Helper()
+
+	exec := newExecution(t, id, fmt.Sprintf("user-%d", n), base)
+	at := func(seconds int) *time.Time {
+		at := base.Add(time.Duration(n*10+seconds) * time.Second)
+		return &at
+	}
+	exitCode := func(code int) *int { return &code }
+	text := func(name string) string { return fmt.Sprintf("%s %d", name, n) }
+
+	exec.Files[exec.Entrypoint] = text("code")
+	exec.Files["lib/helper.txt"] = text("helper")
+	exec.Stdin = text("stdin")
+	exec.TimeoutMs = 1000 + n
+	exec.Status = domain.ExecutionStatusRunning
+	exec.Stdout = text("stdout")
+	exec.Stderr = text("stderr")
+	exec.ExitCode = exitCode(n)
+	exec.StdoutTruncated = true
+	exec.StderrTruncated = true
+	exec.CompileStdout = text("compile stdout")
+	exec.CompileStderr = text("compile stderr")
+	exec.CompileExitCode = exitCode(n + 1)
+	exec.TestCases = []domain.TestCase{{Stdin: text("case stdin"), ExpectedStdout: text("expected"), TimeoutMs: 500 + n}}
+	exec.Checker = domain.Checker{
+		Kind:         domain.CheckerCustom,
+		AbsTolerance: float64(n) / 8,
+		RelTolerance: float64(n) / 16,
+		Language:     exec.Language,
+		Files:        map[string]string{"checker": text("checker")},
+		Entrypoint:   "checker",
+	}
+	exec.TestResults = []domain.TestCaseResult{{
+		Verdict:    domain.VerdictWrongAnswer,
+		Stdout:     text("case stdout"),
+		Stderr:     text("case stderr"),
+		ExitCode:   exitCode(n + 2),
+		DurationMs: int64(n),
+	}}
+	exec.Verdict = domain.VerdictWrongAnswer
+	exec.RunAt = at(1)
+	exec.StartedAt = at(2)
+	exec.FinishedAt = at(3)
+	exec.ScheduleID = text("schedule")
+	exec.Priority = n
+	exec.Limits = domain.ResourceLimits{MemoryBytes: int64(n) << 20, Pids: n + 10, CPUMillis: n * 100}
+	exec.Violations = []domain.ResourceViolation{domain.ResourceViolationMemory, domain.ResourceViolationPids}
+	exec.Usage = domain.ResourceUsage{
+		WallTimeMs:      int64(n + 1),
+		UserCPUTimeMs:   int64(n + 2),
+		SystemCPUTimeMs: int64(n + 3),
+		PeakMemoryBytes: int64(n + 4),
+		IOReadBytes:     int64(n + 5),
+		IOWriteBytes:    int64(n + 6),
+	}
+	exec.Attempts = n
+	exec.WorkerID = text("worker")
+	exec.FailureReason = domain.FailureReasonInfrastructure
+	exec.FailureDetail = text("failure")
+
+	return exec
+}
+
+// assertStored checks that the repository returns exactly want for its id.
+func assertStored(t *testing.T, repo repository.ExecutionRepository, want *domain.Execution) {
+	t.Helper()
+
+	got, err := repo.GetExecutionByID(context.Background(), want.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := normalizeTimes(*got), normalizeTimes(*want); !reflect.DeepEqual(got, want) {
+		t.Errorf("GetExecutionByID = %+v, want %+v", got, want)
+	}
+}
+
+// normalizeTimes drops the locations and monotonic readings of exec's times,
+// which backends need not keep.
+func normalizeTimes(exec domain.Execution) domain.Execution {
+	normalize := func(at *time.Time) *time.Time {
+		if at == nil {
+			return nil
+		}
+		normalized := at.UTC().Round(0)
+		return &normalized
+	}
+
+	exec.CreatedAt = *normalize(&exec.CreatedAt)
+	exec.RunAt = normalize(exec.RunAt)
+	exec.StartedAt = normalize(exec.StartedAt)
+	exec.FinishedAt = normalize(exec.FinishedAt)
+
+	return exec
+}
+
+func cursor(createdAt time.Time, id string) repository.ExecutionCursor {
+	return repository.ExecutionCursor{CreatedAt: createdAt, ID: id}
+}
+
+func create(t *testing.T, repo repository.ExecutionRepository, exec *domain.Execution) {
+	t.Helper()
+
+	if err := repo.CreateExecution(context.Background(), exec); err != nil {
+		t.Fatal(err)
+	}
+}
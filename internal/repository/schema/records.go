@@ -0,0 +1,172 @@
+package schema
+
+import (
+	"encoding/json"
+
+	"Code_executor/internal/domain"
+)
+
+// The records below are the JSON encoding of the execution fields that are
+// not columns of their own. They keep the stored documents independent of the
+// domain types, which carry no JSON tags.
+
+type testCaseRecord struct {
+	Stdin          string `json:"stdin,omitempty"`
+	ExpectedStdout string `json:"expected_stdout"`
+	TimeoutMs      int    `json:"timeout_ms,omitempty"`
+}
+
+type checkerRecord struct {
+	Kind         string            `json:"kind,omitempty"`
+	AbsTolerance float64           `json:"abs_tolerance,omitempty"`
+	RelTolerance float64           `json:"rel_tolerance,omitempty"`
+	Language     string            `json:"language,omitempty"`
+	Files        map[string]string `json:"files,omitempty"`
+	Entrypoint   string            `json:"entrypoint,omitempty"`
+}
+
+type testResultRecord struct {
+	Verdict    string `json:"verdict"`
+	Stdout     string `json:"stdout,omitempty"`
+	Stderr     string `json:"stderr,omitempty"`
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+func toTestCaseRecords(cases []domain.TestCase) []testCaseRecord {
+	records := make([]testCaseRecord, len(cases))
+	for i, tc := range cases {
+		records[i] = testCaseRecord{
+			Stdin:          tc.Stdin,
+			ExpectedStdout: tc.ExpectedStdout,
+			TimeoutMs:      tc.TimeoutMs,
+		}
+	}
+	return records
+}
+
+func fromTestCaseRecords(records []testCaseRecord) []domain.TestCase {
+	if len(records) == 0 {
+		return nil
+	}
+
+	cases := make([]domain.TestCase, len(records))
+	for i, record := range records {
+		cases[i] = domain.TestCase{
+			Stdin:          record.Stdin,
+			ExpectedStdout: record.ExpectedStdout,
+			TimeoutMs:      record.TimeoutMs,
+		}
+	}
+	return cases
+}
+
+func toCheckerRecord(checker domain.Checker) checkerRecord {
+	return checkerRecord{
+		Kind:         string(checker.Kind),
+		AbsTolerance: checker.AbsTolerance,
+		RelTolerance: checker.RelTolerance,
+		Language:     checker.Language,
+		Files:        checker.Files,
+		Entrypoint:   checker.Entrypoint,
+	}
+}
+
+func fromCheckerRecord(record checkerRecord) domain.Checker {
+	return domain.Checker{
+		Kind:         domain.CheckerKind(record.Kind),
+		AbsTolerance: record.AbsTolerance,
+		RelTolerance: record.RelTolerance,
+		Language:     record.Language,
+		Files:        record.Files,
+		Entrypoint:   record.Entrypoint,
+	}
+}
+
+func toTestResultRecords(results []domain.TestCaseResult) []testResultRecord {
+	records := make([]testResultRecord, len(results))
+	for i, result := range results {
+		records[i] = testResultRecord{
+			Verdict:    string(result.Verdict),
+			Stdout:     ValidText(result.Stdout),
+			Stderr:     ValidText(result.Stderr),
+			ExitCode:   result.ExitCode,
+			DurationMs: result.DurationMs,
+		}
+	}
+	return records
+}
+
+func fromTestResultRecords(records []testResultRecord) []domain.TestCaseResult {
+	if len(records) == 0 {
+		return nil
+	}
+
+	results := make([]domain.TestCaseResult, len(records))
+	for i, record := range records {
+		results[i] = domain.TestCaseResult{
+			Verdict:    domain.Verdict(record.Verdict),
+			Stdout:     record.Stdout,
+			Stderr:     record.Stderr,
+			ExitCode:   record.ExitCode,
+			DurationMs: record.DurationMs,
+		}
+	}
+	return results
+}
+
+func EncodeTestCases(cases []domain.TestCase) ([]byte, error) {
+	return json.Marshal(toTestCaseRecords(cases))
+}
+
+func DecodeTestCases(data []byte) ([]domain.TestCase, error) {
+	var records []testCaseRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return fromTestCaseRecords(records), nil
+}
+
+func EncodeChecker(checker domain.Checker) ([]byte, error) {
+	return json.Marshal(toCheckerRecord(checker))
+}
+
+func DecodeChecker(data []byte) (domain.Checker, error) {
+	var record checkerRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return domain.Checker{}, err
+	}
+	return fromCheckerRecord(record), nil
+}
+
+func EncodeTestResults(results []domain.TestCaseResult) ([]byte, error) {
+	return json.Marshal(toTestResultRecords(results))
+}
+
+func DecodeTestResults(data []byte) ([]domain.TestCaseResult, error) {
+	var records []testResultRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return fromTestResultRecords(records), nil
+}
+
+// EncodeFiles encodes nil files as an empty object, which DecodeFiles turns
+// back into nil.
+func EncodeFiles(files map[string]string) ([]byte, error) {
+	if files == nil {
+		files = map[string]string{}
+	}
+	return json.Marshal(files)
+}
+
+func DecodeFiles(data []byte) (map[string]string, error) {
+	var files map[string]string
+	if err := json.Unmarshal(data, &files); err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+	return files, nil
+}
@@ -0,0 +1,358 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"Code_executor/internal/domain"
+	"Code_executor/internal/repository"
+	"Code_executor/internal/repository/schema"
+)
+
+// ExecutionRepository stores executions in the executions table.
+type ExecutionRepository struct {
+	db *sql.DB
+}
+
+func NewExecutionRepository(db *sql.DB) (*ExecutionRepository, error) {
+	if db == nil {
+		return nil, errNilDB
+	}
+
+	return &ExecutionRepository{db: db}, nil
+}
+
+// executionColumns are in the order of executionArgs; id comes first.
+var executionColumns = []string{
+	"id", "language", "files", "entrypoint", "stdin", "timeout_ms", "status",
+	"stdout", "stderr", "exit_code", "stdout_truncated", "stderr_truncated",
+	"compile_stdout", "compile_stderr", "compile_exit_code",
+	"test_cases", "checker", "test_results", "verdict",
+	"created_at", "run_at", "started_at", "finished_at",
+	"user_id", "schedule_id", "priority",
+	"memory_limit_bytes", "pids_limit", "cpu_limit_millis", "violations",
+	"wall_time_ms", "user_cpu_time_ms", "system_cpu_time_ms",
+	"peak_memory_bytes", "io_read_bytes", "io_write_bytes",
+	"attempts", "failure_reason", "failure_detail", "worker_id",
+}
+
+var (
+	selectExecutionQuery = `SELECT ` + strings.Join(executionColumns, ", ") + ` FROM executions`
+
+	// ON CONFLICT turns a duplicate id into zero rows affected, which does not
+	// depend on the driver's error codes.
+	insertExecutionQuery = `INSERT INTO executions (` + strings.Join(executionColumns, ", ") + `)
+VALUES (` + placeholders(len(executionColumns)) + `)
+ON CONFLICT (id) DO NOTHING`
+
+	reopenExecutionQuery = `UPDATE executions SET ` + assignments(executionColumns[1:]) + ` WHERE id = ?`
+
+	// updateExecutionQuery leaves rows in a final status alone.
+	updateExecutionQuery = reopenExecutionQuery + ` AND status NOT IN (` + placeholders(len(domain.FinalStatuses())) + `)`
+)
+
+func (r *ExecutionRepository) CreateExecution(ctx context.Context, exec *domain.Execution) error {
+	if exec == nil {
+		return fmt.Errorf("execution is nil")
+	}
+
+	args, err := executionArgs(exec)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.db.ExecContext(ctx, insertExecutionQuery, args...)
+	if err != nil {
+		return fmt.Errorf("insert execution: %w", err)
+	}
+
+	if inserted, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("insert execution: %w", err)
+	} else if inserted == 0 {
+		return fmt.Errorf("execution with id %s already exists", exec.ID)
+	}
+
+	return nil
+}
+
+func (r *ExecutionRepository) UpdateExecution(ctx context.Context, exec *domain.Execution) error {
+	if exec == nil {
+		return fmt.Errorf("execution is nil")
+	}
+
+	args, err := executionArgs(exec)
+	if err != nil {
+		return err
+	}
+
+	args = append(args[1:], exec.ID)
+	for _, status := range domain.FinalStatuses() {
+		args = append(args, string(status))
+	}
+
+	res, err := r.db.ExecContext(ctx, updateExecutionQuery, args...)
+	if err != nil {
+		return fmt.Errorf("update execution: %w", err)
+	}
+
+	updated, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("update execution: %w", err)
+	}
+	if updated > 0 {
+		return nil
+	}
+
+	var exists bool
+	err = r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM executions WHERE id = ?)`, exec.ID).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("check execution: %w", err)
+	}
+	if !exists {
+		return repository.ErrExecutionNotFound
+	}
+
+	return repository.ErrExecutionFinished
+}
+
+func (r *ExecutionRepository) ReopenExecution(ctx context.Context, exec *domain.Execution) error {
+	if exec == nil {
+		return fmt.Errorf("execution is nil")
+	}
+
+	args, err := executionArgs(exec)
+	if err != nil {
+		return err
+	}
+
+	res, err := r.db.ExecContext(ctx, reopenExecutionQuery, append(args[1:], exec.ID)...)
+	if err != nil {
+		return fmt.Errorf("reopen execution: %w", err)
+	}
+
+	if updated, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("reopen execution: %w", err)
+	} else if updated == 0 {
+		return repository.ErrExecutionNotFound
+	}
+
+	return nil
+}
+
+func (r *ExecutionRepository) GetExecutionByID(ctx context.Context, id string) (*domain.Execution, error) {
+	row := r.db.QueryRowContext(ctx, selectExecutionQuery+` WHERE id = ?`, id)
+
+	exec, err := scanExecution(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrExecutionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get execution: %w", err)
+	}
+
+	return exec, nil
+}
+
+func (r *ExecutionRepository) ListExecutions(ctx context.Context, filter repository.ExecutionFilter) ([]*domain.Execution, error) {
+	// Only the set fields become conditions, so SQLite can pick the index of
+	// the filter.
+	var (
+		conditions []string
+		args       []any
+	)
+	if filter.UserID != "" {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.ScheduleID != "" {
+		conditions = append(conditions, "schedule_id = ?")
+		args = append(args, filter.ScheduleID)
+	}
+	if filter.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, string(filter.Status))
+	}
+	if !filter.After.IsZero() {
+		conditions = append(conditions, "(created_at, id) < (?, ?)")
+		args = append(args, filter.After.CreatedAt.UnixNano(), filter.After.ID)
+	}
+
+	query := selectExecutionQuery
+	if len(conditions) > 0 {
+		query += ` WHERE ` + strings.Join(conditions, " AND ")
+	}
+
+	// A negative LIMIT does not cap the result.
+	limit := -1
+	if filter.Limit > 0 {
+		limit = filter.Limit
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list executions: %w", err)
+	}
+	defer rows.Close()
+
+	var execs []*domain.Execution
+	for rows.Next() {
+		exec, err := scanExecution(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list executions: %w", err)
+		}
+		execs = append(execs, exec)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list executions: %w", err)
+	}
+
+	return execs, nil
+}
+
+// executionArgs returns the values of executionColumns for exec, in order.
+// Program output is stored as schema.ValidText.
+func executionArgs(exec *domain.Execution) ([]any, error) {
+	files, err := schema.EncodeFiles(exec.Files)
+	if err != nil {
+		return nil, fmt.Errorf("encode files: %w", err)
+	}
+
+	testCases, err := schema.EncodeTestCases(exec.TestCases)
+	if err != nil {
+		return nil, fmt.Errorf("encode test cases: %w", err)
+	}
+
+	checker, err := schema.EncodeChecker(exec.Checker)
+	if err != nil {
+		return nil, fmt.Errorf("encode checker: %w", err)
+	}
+
+	testResults, err := schema.EncodeTestResults(exec.TestResults)
+	if err != nil {
+		return nil, fmt.Errorf("encode test results: %w", err)
+	}
+
+	violations := make([]string, len(exec.Violations))
+	for i, violation := range exec.Violations {
+		violations[i] = string(violation)
+	}
+	violationsJSON, err := json.Marshal(violations)
+	if err != nil {
+		return nil, fmt.Errorf("encode violations: %w", err)
+	}
+
+	return []any{
+		exec.ID, exec.Language, string(files), exec.Entrypoint, exec.Stdin, exec.TimeoutMs, string(exec.Status),
+		schema.ValidText(exec.Stdout), schema.ValidText(exec.Stderr), nullInt(exec.ExitCode), exec.StdoutTruncated, exec.StderrTruncated,
+		schema.ValidText(exec.CompileStdout), schema.ValidText(exec.CompileStderr), nullInt(exec.CompileExitCode),
+		string(testCases), string(checker), string(testResults), string(exec.Verdict),
+		exec.CreatedAt.UnixNano(), unixNanos(exec.RunAt), unixNanos(exec.StartedAt), unixNanos(exec.FinishedAt),
+		exec.UserID, exec.ScheduleID, exec.Priority,
+		exec.Limits.MemoryBytes, exec.Limits.Pids, exec.Limits.CPUMillis, string(violationsJSON),
+		exec.Usage.WallTimeMs, exec.Usage.UserCPUTimeMs, exec.Usage.SystemCPUTimeMs,
+		exec.Usage.PeakMemoryBytes, exec.Usage.IOReadBytes, exec.Usage.IOWriteBytes,
+		exec.Attempts, string(exec.FailureReason), schema.ValidText(exec.FailureDetail), exec.WorkerID,
+	}, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanExecution(row rowScanner) (*domain.Execution, error) {
+	var (
+		exec                               domain.Execution
+		status, verdict, failureReason     string
+		files, testCases, checker, results string
+		violations                         string
+		exitCode, compileExitCode          sql.NullInt64
+		createdAt                          int64
+		runAt, startedAt, finishedAt       sql.NullInt64
+	)
+
+	err := row.Scan(
+		&exec.ID, &exec.Language, &files, &exec.Entrypoint, &exec.Stdin, &exec.TimeoutMs, &status,
+		&exec.Stdout, &exec.Stderr, &exitCode, &exec.StdoutTruncated, &exec.StderrTruncated,
+		&exec.CompileStdout, &exec.CompileStderr, &compileExitCode,
+		&testCases, &checker, &results, &verdict,
+		&createdAt, &runAt, &startedAt, &finishedAt,
+		&exec.UserID, &exec.ScheduleID, &exec.Priority,
+		&exec.Limits.MemoryBytes, &exec.Limits.Pids, &exec.Limits.CPUMillis, &violations,
+		&exec.Usage.WallTimeMs, &exec.Usage.UserCPUTimeMs, &exec.Usage.SystemCPUTimeMs,
+		&exec.Usage.PeakMemoryBytes, &exec.Usage.IOReadBytes, &exec.Usage.IOWriteBytes,
+		&exec.Attempts, &failureReason, &exec.FailureDetail, &exec.WorkerID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	exec.Status = domain.ExecutionStatus(status)
+	exec.Verdict = domain.Verdict(verdict)
+	exec.FailureReason = domain.FailureReason(failureReason)
+	exec.ExitCode = fromNullInt(exitCode)
+	exec.CompileExitCode = fromNullInt(compileExitCode)
+	exec.CreatedAt = time.Unix(0, createdAt).UTC()
+	exec.RunAt = fromUnixNanos(runAt)
+	exec.StartedAt = fromUnixNanos(startedAt)
+	exec.FinishedAt = fromUnixNanos(finishedAt)
+
+	if exec.Files, err = schema.DecodeFiles([]byte(files)); err != nil {
+		return nil, fmt.Errorf("decode files: %w", err)
+	}
+
+	if exec.TestCases, err = schema.DecodeTestCases([]byte(testCases)); err != nil {
+		return nil, fmt.Errorf("decode test cases: %w", err)
+	}
+
+	if exec.Checker, err = schema.DecodeChecker([]byte(checker)); err != nil {
+		return nil, fmt.Errorf("decode checker: %w", err)
+	}
+
+	if exec.TestResults, err = schema.DecodeTestResults([]byte(results)); err != nil {
+		return nil, fmt.Errorf("decode test results: %w", err)
+	}
+
+	var violationNames []string
+	if err := json.Unmarshal([]byte(violations), &violationNames); err != nil {
+		return nil, fmt.Errorf("decode violations: %w", err)
+	}
+	if len(violationNames) > 0 {
+		exec.Violations = make([]domain.ResourceViolation, len(violationNames))
+		for i, violation := range violationNames {
+			exec.Violations[i] = domain.ResourceViolation(violation)
+		}
+	}
+
+	return &exec, nil
+}
+
+func nullInt(v *int) any {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func fromNullInt(n sql.NullInt64) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int64)
+	return &v
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func assignments(columns []string) string {
+	return strings.Join(columns, " = ?, ") + " = ?"
+}
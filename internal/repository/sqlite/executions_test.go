@@ -0,0 +1,38 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"Code_executor/internal/repository"
+	"Code_executor/internal/repository/repositorytest"
+)
+
+func TestExecutionRepository(t *testing.T) {
+	repositorytest.TestExecutionRepository(t, func(t *testing.T) repository.ExecutionRepository {
+		ctx := context.Background()
+
+		db, err := Open(ctx, filepath.Join(t.TempDir(), "executions.db"), time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = db.Close() })
+
+		migrator, err := NewMigrator(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := migrator.Up(ctx); err != nil {
+			t.Fatal(err)
+		}
+
+		repo, err := NewExecutionRepository(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return repo
+	})
+}
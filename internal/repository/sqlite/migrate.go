@@ -0,0 +1,175 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"slices"
+	"time"
+
+	"Code_executor/internal/repository/schema"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+const migrationsTable = `
+CREATE TABLE IF NOT EXISTS schema_migrations (
+	version    INTEGER PRIMARY KEY,
+	name       TEXT NOT NULL,
+	applied_at INTEGER NOT NULL
+)`
+
+// Migrator applies the embedded migrations in version order, each in its own
+// transaction, and records them in schema_migrations. Transactions take the
+// write lock when they begin (see Open), which serializes migrators.
+type Migrator struct {
+	db         *sql.DB
+	migrations []schema.Migration
+}
+
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	if db == nil {
+		return nil, errNilDB
+	}
+
+	migrations, err := schema.Load(migrationFiles, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	return &Migrator{db: db, migrations: migrations}, nil
+}
+
+func (m *Migrator) Up(ctx context.Context) ([]schema.Migration, error) {
+	if _, err := m.db.ExecContext(ctx, migrationsTable); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var applied []schema.Migration
+	for _, migration := range m.migrations {
+		ran, err := m.inTx(ctx, func(tx *sql.Tx) (bool, error) {
+			var done bool
+			err := tx.QueryRowContext(ctx,
+				`SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = ?)`, migration.Version).Scan(&done)
+			if err != nil || done {
+				return false, err
+			}
+
+			if _, err := tx.ExecContext(ctx, migration.Up); err != nil {
+				return false, err
+			}
+			_, err = tx.ExecContext(ctx,
+				`INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)`,
+				migration.Version, migration.Name, time.Now().UnixNano())
+			return err == nil, err
+		})
+		if err != nil {
+			return applied, fmt.Errorf("apply migration %s: %w", migration, err)
+		}
+
+		if ran {
+			applied = append(applied, migration)
+		}
+	}
+
+	return applied, nil
+}
+
+func (m *Migrator) Down(ctx context.Context) (*schema.Migration, error) {
+	if _, err := m.db.ExecContext(ctx, migrationsTable); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	var reverted *schema.Migration
+	_, err := m.inTx(ctx, func(tx *sql.Tx) (bool, error) {
+		var latest sql.NullInt64
+		if err := tx.QueryRowContext(ctx, `SELECT MAX(version) FROM schema_migrations`).Scan(&latest); err != nil {
+			return false, err
+		}
+		if !latest.Valid {
+			return false, nil
+		}
+
+		i := slices.IndexFunc(m.migrations, func(migration schema.Migration) bool {
+			return migration.Version == latest.Int64
+		})
+		if i < 0 {
+			return false, fmt.Errorf("%w: version %d", schema.ErrUnknownMigration, latest.Int64)
+		}
+		migration := m.migrations[i]
+
+		if _, err := tx.ExecContext(ctx, migration.Down); err != nil {
+			return false, fmt.Errorf("revert migration %s: %w", migration, err)
+		}
+		if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = ?`, migration.Version); err != nil {
+			return false, fmt.Errorf("revert migration %s: %w", migration, err)
+		}
+
+		reverted = &migration
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return reverted, nil
+}
+
+func (m *Migrator) Status(ctx context.Context) ([]schema.MigrationStatus, error) {
+	if _, err := m.db.ExecContext(ctx, migrationsTable); err != nil {
+		return nil, fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	rows, err := m.db.QueryContext(ctx, `SELECT version, applied_at FROM schema_migrations`)
+	if err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+	defer rows.Close()
+
+	applied := make(map[int64]time.Time)
+	for rows.Next() {
+		var version, appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			return nil, fmt.Errorf("read schema_migrations: %w", err)
+		}
+		applied[version] = time.Unix(0, appliedAt).UTC()
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("read schema_migrations: %w", err)
+	}
+
+	statuses := make([]schema.MigrationStatus, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := schema.MigrationStatus{Migration: migration}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &appliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// inTx runs fn in a transaction that is committed if fn returns nil and
+// rolled back otherwise.
+func (m *Migrator) inTx(ctx context.Context, fn func(tx *sql.Tx) (bool, error)) (bool, error) {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	ok, err := fn(tx)
+	if err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit transaction: %w", err)
+	}
+
+	return ok, nil
+}
@@ -0,0 +1,47 @@
+-- Times are Unix nanoseconds; JSON columns hold the documents of
+-- Code_executor/internal/repository/schema.
+CREATE TABLE executions (
+	id                 TEXT PRIMARY KEY,
+	language           TEXT NOT NULL,
+	files              TEXT NOT NULL DEFAULT '{}',
+	entrypoint         TEXT NOT NULL,
+	stdin              TEXT NOT NULL DEFAULT '',
+	timeout_ms         INTEGER NOT NULL,
+	status             TEXT NOT NULL,
+	stdout             TEXT NOT NULL DEFAULT '',
+	stderr             TEXT NOT NULL DEFAULT '',
+	exit_code          INTEGER,
+	stdout_truncated   INTEGER NOT NULL DEFAULT 0,
+	stderr_truncated   INTEGER NOT NULL DEFAULT 0,
+	compile_stdout     TEXT NOT NULL DEFAULT '',
+	compile_stderr     TEXT NOT NULL DEFAULT '',
+	compile_exit_code  INTEGER,
+	test_cases         TEXT NOT NULL DEFAULT '[]',
+	checker            TEXT NOT NULL DEFAULT '{}',
+	test_results       TEXT NOT NULL DEFAULT '[]',
+	verdict            TEXT NOT NULL DEFAULT '',
+	created_at         INTEGER NOT NULL,
+	run_at             INTEGER,
+	started_at         INTEGER,
+	finished_at        INTEGER,
+	user_id            TEXT NOT NULL,
+	schedule_id        TEXT NOT NULL DEFAULT '',
+	priority           INTEGER NOT NULL DEFAULT 0,
+	memory_limit_bytes INTEGER NOT NULL DEFAULT 0,
+	pids_limit         INTEGER NOT NULL DEFAULT 0,
+	cpu_limit_millis   INTEGER NOT NULL DEFAULT 0,
+	violations         TEXT NOT NULL DEFAULT '[]',
+	wall_time_ms       INTEGER NOT NULL DEFAULT 0,
+	user_cpu_time_ms   INTEGER NOT NULL DEFAULT 0,
+	system_cpu_time_ms INTEGER NOT NULL DEFAULT 0,
+	peak_memory_bytes  INTEGER NOT NULL DEFAULT 0,
+	io_read_bytes      INTEGER NOT NULL DEFAULT 0,
+	io_write_bytes     INTEGER NOT NULL DEFAULT 0,
+	attempts           INTEGER NOT NULL DEFAULT 0,
+	failure_reason     TEXT NOT NULL DEFAULT '',
+	failure_detail     TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX executions_user_id_idx ON executions (user_id, created_at DESC, id DESC);
+CREATE INDEX executions_status_idx ON executions (status, created_at DESC, id DESC);
+CREATE INDEX executions_schedule_id_idx ON executions (schedule_id, created_at DESC, id DESC) WHERE schedule_id <> '';
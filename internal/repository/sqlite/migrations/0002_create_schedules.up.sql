@@ -0,0 +1,19 @@
+CREATE TABLE schedules (
+	id                TEXT PRIMARY KEY,
+	user_id           TEXT NOT NULL,
+	language          TEXT NOT NULL,
+	code              TEXT NOT NULL,
+	stdin             TEXT NOT NULL DEFAULT '',
+	timeout_ms        INTEGER NOT NULL,
+	priority          INTEGER NOT NULL DEFAULT 0,
+	cron              TEXT NOT NULL,
+	missed_run_policy TEXT NOT NULL,
+	enabled           INTEGER NOT NULL DEFAULT 1,
+	next_run_at       INTEGER NOT NULL,
+	last_run_at       INTEGER,
+	created_at        INTEGER NOT NULL,
+	updated_at        INTEGER NOT NULL
+);
+
+CREATE INDEX schedules_user_id_idx ON schedules (user_id, created_at, id);
+CREATE INDEX schedules_next_run_at_idx ON schedules (next_run_at) WHERE enabled;
@@ -0,0 +1 @@
+CREATE INDEX executions_created_at_idx ON executions (created_at DESC, id DESC);
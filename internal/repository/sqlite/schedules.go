@@ -0,0 +1,225 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"Code_executor/internal/domain"
+	"Code_executor/internal/repository"
+)
+
+type ScheduleRepository struct {
+	db *sql.DB
+}
+
+func NewScheduleRepository(db *sql.DB) (*ScheduleRepository, error) {
+	if db == nil {
+		return nil, errNilDB
+	}
+
+	return &ScheduleRepository{db: db}, nil
+}
+
+// scheduleColumns are in the order of scheduleArgs; id comes first.
+var scheduleColumns = []string{
+	"id", "user_id", "language", "code", "stdin", "timeout_ms", "priority",
+	"cron", "missed_run_policy", "enabled",
+	"next_run_at", "last_run_at", "created_at", "updated_at",
+}
+
+var (
+	selectScheduleQuery = `SELECT ` + strings.Join(scheduleColumns, ", ") + ` FROM schedules`
+
+	insertScheduleQuery = `INSERT INTO schedules (` + strings.Join(scheduleColumns, ", ") + `)
+VALUES (` + placeholders(len(scheduleColumns)) + `)
+ON CONFLICT (id) DO NOTHING`
+
+	updateScheduleQuery = `UPDATE schedules SET ` + assignments(scheduleColumns[1:]) + ` WHERE id = ?`
+)
+
+func (r *ScheduleRepository) CreateSchedule(ctx context.Context, schedule *domain.Schedule) error {
+	if schedule == nil {
+		return fmt.Errorf("schedule is nil")
+	}
+
+	res, err := r.db.ExecContext(ctx, insertScheduleQuery, scheduleArgs(schedule)...)
+	if err != nil {
+		return fmt.Errorf("insert schedule: %w", err)
+	}
+
+	if inserted, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("insert schedule: %w", err)
+	} else if inserted == 0 {
+		return fmt.Errorf("schedule with id %s already exists", schedule.ID)
+	}
+
+	return nil
+}
+
+func (r *ScheduleRepository) UpdateSchedule(ctx context.Context, schedule *domain.Schedule) error {
+	if schedule == nil {
+		return fmt.Errorf("schedule is nil")
+	}
+
+	args := scheduleArgs(schedule)
+	res, err := r.db.ExecContext(ctx, updateScheduleQuery, append(args[1:], schedule.ID)...)
+	if err != nil {
+		return fmt.Errorf("update schedule: %w", err)
+	}
+
+	if updated, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("update schedule: %w", err)
+	} else if updated == 0 {
+		return repository.ErrScheduleNotFound
+	}
+
+	return nil
+}
+
+func (r *ScheduleRepository) GetScheduleByID(ctx context.Context, id string) (*domain.Schedule, error) {
+	row := r.db.QueryRowContext(ctx, selectScheduleQuery+` WHERE id = ?`, id)
+
+	schedule, err := scanSchedule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, repository.ErrScheduleNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get schedule: %w", err)
+	}
+
+	return schedule, nil
+}
+
+func (r *ScheduleRepository) ListSchedules(ctx context.Context, userID string) ([]*domain.Schedule, error) {
+	if userID == "" {
+		return r.listSchedules(ctx, selectScheduleQuery+` ORDER BY created_at, id`)
+	}
+
+	return r.listSchedules(ctx, selectScheduleQuery+` WHERE user_id = ? ORDER BY created_at, id`, userID)
+}
+
+func (r *ScheduleRepository) DeleteSchedule(ctx context.Context, id string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM schedules WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("delete schedule: %w", err)
+	}
+
+	if deleted, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("delete schedule: %w", err)
+	} else if deleted == 0 {
+		return repository.ErrScheduleNotFound
+	}
+
+	return nil
+}
+
+func (r *ScheduleRepository) ListDueSchedules(ctx context.Context, now time.Time, limit int) ([]*domain.Schedule, error) {
+	// A negative LIMIT does not cap the result.
+	if limit <= 0 {
+		limit = -1
+	}
+
+	return r.listSchedules(ctx, selectScheduleQuery+`
+WHERE enabled AND next_run_at <= ?
+ORDER BY next_run_at
+LIMIT ?`, now.UnixNano(), limit)
+}
+
+// AdvanceSchedule compares next_run_at in the UPDATE itself, so of two
+// schedulers racing for the same run only one matches the row.
+func (r *ScheduleRepository) AdvanceSchedule(ctx context.Context, schedule *domain.Schedule, previousNextRunAt time.Time) (bool, error) {
+	if schedule == nil {
+		return false, fmt.Errorf("schedule is nil")
+	}
+
+	res, err := r.db.ExecContext(ctx,
+		`UPDATE schedules SET next_run_at = ?, last_run_at = ?, updated_at = ? WHERE id = ? AND next_run_at = ?`,
+		schedule.NextRunAt.UnixNano(), unixNanos(schedule.LastRunAt), schedule.UpdatedAt.UnixNano(),
+		schedule.ID, previousNextRunAt.UnixNano())
+	if err != nil {
+		return false, fmt.Errorf("advance schedule: %w", err)
+	}
+
+	advanced, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("advance schedule: %w", err)
+	}
+	if advanced > 0 {
+		return true, nil
+	}
+
+	var exists bool
+	err = r.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM schedules WHERE id = ?)`, schedule.ID).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("check schedule: %w", err)
+	}
+	if !exists {
+		return false, repository.ErrScheduleNotFound
+	}
+
+	return false, nil
+}
+
+func (r *ScheduleRepository) listSchedules(ctx context.Context, query string, args ...any) ([]*domain.Schedule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list schedules: %w", err)
+	}
+	defer rows.Close()
+
+	var schedules []*domain.Schedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("list schedules: %w", err)
+		}
+		schedules = append(schedules, schedule)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("list schedules: %w", err)
+	}
+
+	return schedules, nil
+}
+
+func scheduleArgs(schedule *domain.Schedule) []any {
+	return []any{
+		schedule.ID, schedule.UserID, schedule.Language, schedule.Code, schedule.Stdin,
+		schedule.TimeoutMs, schedule.Priority,
+		schedule.Cron, string(schedule.MissedRunPolicy), schedule.Enabled,
+		schedule.NextRunAt.UnixNano(), unixNanos(schedule.LastRunAt),
+		schedule.CreatedAt.UnixNano(), schedule.UpdatedAt.UnixNano(),
+	}
+}
+
+func scanSchedule(row rowScanner) (*domain.Schedule, error) {
+	var (
+		schedule                        domain.Schedule
+		missedRunPolicy                 string
+		nextRunAt, createdAt, updatedAt int64
+		lastRunAt                       sql.NullInt64
+	)
+
+	err := row.Scan(
+		&schedule.ID, &schedule.UserID, &schedule.Language, &schedule.Code, &schedule.Stdin,
+		&schedule.TimeoutMs, &schedule.Priority,
+		&schedule.Cron, &missedRunPolicy, &schedule.Enabled,
+		&nextRunAt, &lastRunAt, &createdAt, &updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.MissedRunPolicy = domain.MissedRunPolicy(missedRunPolicy)
+	schedule.NextRunAt = time.Unix(0, nextRunAt).UTC()
+	schedule.LastRunAt = fromUnixNanos(lastRunAt)
+	schedule.CreatedAt = time.Unix(0, createdAt).UTC()
+	schedule.UpdatedAt = time.Unix(0, updatedAt).UTC()
+
+	return &schedule, nil
+}
@@ -0,0 +1,68 @@
+// Package sqlite stores executions and schedules in a SQLite database file,
+// for single-node installs and local development without a Postgres server.
+// The api and the workers of a node share the file.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	_ "github.com/glebarez/go-sqlite"
+)
+
+const driverName = "sqlite"
+
+var errNilDB = errors.New("sqlite db is nil")
+
+// Open opens the database file at path, creating it if needed.
+//
+// Connections wait up to busyTimeout for the write lock held by another
+// connection or process instead of failing with SQLITE_BUSY. Transactions
+// take the write lock when they begin, since a transaction that reads first
+// and upgrades later can fail with SQLITE_BUSY without waiting. The WAL
+// journal lets readers run while a write is in progress.
+func Open(ctx context.Context, path string, busyTimeout time.Duration) (*sql.DB, error) {
+	if path == "" {
+		return nil, fmt.Errorf("sqlite path is empty")
+	}
+	if busyTimeout < 0 {
+		return nil, fmt.Errorf("sqlite busy timeout must not be negative")
+	}
+
+	params := url.Values{}
+	params.Add("_pragma", fmt.Sprintf("busy_timeout(%d)", busyTimeout.Milliseconds()))
+	params.Add("_pragma", "journal_mode(WAL)")
+	params.Add("_pragma", "synchronous(NORMAL)")
+	params.Add("_txlock", "immediate")
+
+	db, err := sql.Open(driverName, "file:"+path+"?"+params.Encode())
+	if err != nil {
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	if err := db.PingContext(ctx); err != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("open sqlite: %w", err)
+	}
+
+	return db, nil
+}
+
+func unixNanos(t *time.Time) any {
+	if t == nil {
+		return nil
+	}
+	return t.UnixNano()
+}
+
+func fromUnixNanos(n sql.NullInt64) *time.Time {
+	if !n.Valid {
+		return nil
+	}
+	t := time.Unix(0, n.Int64).UTC()
+	return &t
+}
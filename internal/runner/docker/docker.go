@@ -0,0 +1,167 @@
+package dockerrunner
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/runner"
+	"Code_executor/internal/seccomp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	ContainerDir = "/workspace"
+
+	// nobody:nogroup exists in both the debian and alpine based images.
+	containerUser = "65534:65534"
+
+	removeTimeout = 10 * time.Second
+)
+
+var (
+	ErrNilEngine = errors.New("container engine is nil")
+)
+
+// Runner starts a throwaway container from Language.DockerImage for every
+// phase of an execution. The workspace is mounted read-only except while
+// compiling.
+type Runner struct {
+	engine  Engine
+	baseDir string
+	pool    *Pool
+}
+
+// NewRunner creates a docker runner. pool may be nil, in which case every
+// phase cold-starts its container.
+func NewRunner(engine Engine, baseDir string, pool *Pool) (*Runner, error) {
+	if engine == nil {
+		return nil, ErrNilEngine
+	}
+
+	return &Runner{
+		engine:  engine,
+		baseDir: baseDir,
+		pool:    pool,
+	}, nil
+}
+
+func (r *Runner) Prepare(ctx context.Context, req runner.Request) (runner.Session, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err := seccomp.Resolve(req.Language.SeccompProfile, req.Language.SeccompSyscalls); err != nil {
+		return nil, err
+	}
+
+	if r.pool != nil {
+		if c, ok := r.pool.acquire(req.Execution.Language, req.Execution.Limits); ok {
+			ws, err := runner.FillWorkspace(c.dir, req)
+			if err != nil {
+				r.pool.release(c)
+				return nil, err
+			}
+
+			return &pooledSession{
+				Session:   runner.NewSession(req, ws, &pooledExecutor{runner: r, container: c}),
+				pool:      r.pool,
+				container: c,
+			}, nil
+		}
+	}
+
+	return runner.PrepareSession(r.baseDir, req, r)
+}
+
+func (r *Runner) Execute(ctx context.Context, req runner.Request, ws *runner.Workspace, phase runner.Phase) (*runner.Result, error) {
+	var syscalls []string
+	if phase.Filtered {
+		var err error
+		syscalls, err = seccomp.Resolve(req.Language.SeccompProfile, req.Language.SeccompSyscalls)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, phase.Timeout)
+	defer cancel()
+
+	// Cancelled early when either stream exceeds the output limit.
+	procCtx, stop := context.WithCancel(runCtx)
+	defer stop()
+
+	stdout := runner.NewOutputBuffer(req.MaxOutputBytes(), stop)
+	stderr := runner.NewOutputBuffer(req.MaxOutputBytes(), stop)
+
+	spec := ContainerSpec{
+		Name:     containerName(req, phase),
+		Image:    req.Language.DockerImage,
+		Command:  phase.Command,
+		HostDir:  ws.Dir,
+		Writable: phase.Writable,
+		Stdin:    phase.Stdin,
+		Env:      []string{"HOME=/tmp"},
+		Limits:   phase.Limits,
+		Syscalls: syscalls,
+		Stdout:   stdout,
+		Stderr:   stderr,
+	}
+
+	startedAt := time.Now()
+	containerResult, runErr := r.engine.Run(procCtx, spec)
+	finishedAt := time.Now()
+
+	// Only wall time: the CLI client's rusage says nothing about the
+	// container.
+	result := &runner.Result{
+		Stdout:          stdout.String(),
+		Stderr:          stderr.String(),
+		StdoutTruncated: stdout.Truncated(),
+		StderrTruncated: stderr.Truncated(),
+		Usage:           domain.ResourceUsage{WallTimeMs: finishedAt.Sub(startedAt).Milliseconds()},
+		StartedAt:       startedAt,
+		FinishedAt:      finishedAt,
+	}
+
+	if procCtx.Err() != nil {
+		// Killing the CLI client does not stop the container itself.
+		r.remove(spec.Name)
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		result.TimedOut = runCtx.Err() != nil
+		return result, nil
+	}
+
+	if runErr != nil {
+		return nil, fmt.Errorf("run container %s: %w", spec.Name, runErr)
+	}
+
+	result.ExitCode = containerResult.ExitCode
+	if len(syscalls) > 0 && result.ExitCode == seccomp.KilledExitCode {
+		result.BlockedSyscall = seccomp.UnreportedSyscall
+	}
+
+	if containerResult.OOMKilled {
+		result.Violations = append(result.Violations, domain.ResourceViolationMemory)
+	}
+
+	return result, nil
+}
+
+func (r *Runner) remove(name string) {
+	ctx, cancel := context.WithTimeout(context.Background(), removeTimeout)
+	defer cancel()
+
+	if err := r.engine.Remove(ctx, name); err != nil {
+		log.Printf("remove container %s: %v", name, err)
+	}
+}
+
+func containerName(req runner.Request, phase runner.Phase) string {
+	return "code-executor-" + req.ResourceName(phase)
+}
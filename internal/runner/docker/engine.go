@@ -0,0 +1,310 @@
+package dockerrunner
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/seccomp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// dockerRunErrorCode is returned by `docker run` itself (not the container)
+// when the daemon failed to create or start the container.
+const dockerRunErrorCode = 125
+
+const engineErrorTail = 4096
+
+var (
+	ErrEngineFailure = errors.New("container engine failure")
+)
+
+type ContainerSpec struct {
+	Name     string
+	Image    string
+	Command  []string
+	HostDir  string // mounted at ContainerDir
+	Writable bool   // mount HostDir read-write instead of read-only
+	Stdin    string
+	Env      []string
+	Limits   domain.ResourceLimits
+	Syscalls []string // seccomp allowlist; empty => the engine's default profile
+	Stdout   io.Writer
+	Stderr   io.Writer
+}
+
+// ExecSpec is a command started in an already running container, as the
+// container's user and in ContainerDir. It inherits the container's seccomp
+// profile.
+type ExecSpec struct {
+	Command []string
+	Stdin   string
+	Env     []string
+	Stdout  io.Writer
+	Stderr  io.Writer
+}
+
+type ContainerResult struct {
+	ExitCode  int
+	OOMKilled bool
+}
+
+// Engine is the small slice of a container runtime the runner needs. It is an
+// interface so tests and hosts without a daemon can substitute a fake.
+type Engine interface {
+	Run(ctx context.Context, spec ContainerSpec) (*ContainerResult, error)
+	// Start runs spec.Command detached; its streams and Stdin are ignored.
+	Start(ctx context.Context, spec ContainerSpec) error
+	// Exec reports OOMKilled for an OOM kill anywhere in the container, so
+	// it is only accurate for containers that run a single Exec.
+	Exec(ctx context.Context, name string, spec ExecSpec) (*ContainerResult, error)
+	Remove(ctx context.Context, name string) error
+}
+
+// CLIEngine drives any docker-compatible CLI (docker, podman, nerdctl).
+type CLIEngine struct {
+	binary string
+}
+
+func NewCLIEngine(binary string) *CLIEngine {
+	if binary == "" {
+		binary = "docker"
+	}
+
+	return &CLIEngine{binary: binary}
+}
+
+func (e *CLIEngine) Run(ctx context.Context, spec ContainerSpec) (*ContainerResult, error) {
+	profile, err := writeSeccompProfile(spec.Syscalls)
+	if err != nil {
+		return nil, err
+	}
+	defer removeSeccompProfile(profile)
+
+	// No --rm: the container has to outlive the run so we can inspect its
+	// final state before removing it.
+	args := append([]string{"run", "-i"}, containerArgs(spec, profile)...)
+
+	cmd := exec.CommandContext(ctx, e.binary, args...)
+	cmd.Stdin = strings.NewReader(spec.Stdin)
+
+	// Daemon errors are written to the same stderr as the program's, keep
+	// a short tail for the error message.
+	engineErr := &tailBuffer{max: engineErrorTail}
+	cmd.Stdout = spec.Stdout
+	cmd.Stderr = io.MultiWriter(spec.Stderr, engineErr)
+
+	err = cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	result := &ContainerResult{}
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr) && exitErr.ExitCode() == dockerRunErrorCode:
+		_ = e.Remove(ctx, spec.Name)
+		return nil, fmt.Errorf("%w: %s", ErrEngineFailure, strings.TrimSpace(engineErr.String()))
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", ErrEngineFailure, err)
+	}
+
+	oomKilled, inspectErr := e.oomKilled(ctx, spec.Name)
+	removeErr := e.Remove(ctx, spec.Name)
+	if inspectErr != nil {
+		return nil, inspectErr
+	}
+	if removeErr != nil {
+		return nil, removeErr
+	}
+	result.OOMKilled = oomKilled
+
+	return result, nil
+}
+
+func (e *CLIEngine) Start(ctx context.Context, spec ContainerSpec) error {
+	profile, err := writeSeccompProfile(spec.Syscalls)
+	if err != nil {
+		return err
+	}
+	defer removeSeccompProfile(profile)
+
+	args := append([]string{"run", "-d"}, containerArgs(spec, profile)...)
+
+	out, err := exec.CommandContext(ctx, e.binary, args...).CombinedOutput()
+	if err != nil {
+		_ = e.Remove(ctx, spec.Name)
+		return fmt.Errorf("%w: start %s: %s", ErrEngineFailure, spec.Name, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
+
+func (e *CLIEngine) Exec(ctx context.Context, name string, spec ExecSpec) (*ContainerResult, error) {
+	args := []string{"exec", "-i", "--user", containerUser, "-w", ContainerDir}
+	for _, env := range spec.Env {
+		args = append(args, "-e", env)
+	}
+	args = append(args, name)
+	args = append(args, spec.Command...)
+
+	cmd := exec.CommandContext(ctx, e.binary, args...)
+	cmd.Stdin = strings.NewReader(spec.Stdin)
+	cmd.Stdout = spec.Stdout
+	cmd.Stderr = spec.Stderr
+
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	result := &ContainerResult{}
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", ErrEngineFailure, err)
+	}
+
+	// The daemon records OOM kills of exec'd processes on the container too.
+	result.OOMKilled, err = e.oomKilled(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (e *CLIEngine) oomKilled(ctx context.Context, name string) (bool, error) {
+	out, err := exec.CommandContext(ctx, e.binary, "inspect", "--format", "{{.State.OOMKilled}}", name).Output()
+	if err != nil {
+		return false, fmt.Errorf("%w: inspect %s: %v", ErrEngineFailure, name, err)
+	}
+
+	return strings.TrimSpace(string(out)) == "true", nil
+}
+
+type tailBuffer struct {
+	buf []byte
+	max int
+}
+
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > t.max {
+		t.buf = t.buf[len(t.buf)-t.max:]
+	}
+
+	return len(p), nil
+}
+
+func (t *tailBuffer) String() string {
+	return string(t.buf)
+}
+
+// writeSeccompProfile writes the profile for an allowlist to a temporary file,
+// which the CLI reads when it creates the container. It returns "" for an
+// empty allowlist.
+func writeSeccompProfile(syscalls []string) (string, error) {
+	if len(syscalls) == 0 {
+		return "", nil
+	}
+
+	data, err := seccomp.DockerProfile(syscalls)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.CreateTemp("", "code-executor-seccomp-*.json")
+	if err != nil {
+		return "", fmt.Errorf("create seccomp profile: %w", err)
+	}
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", fmt.Errorf("write seccomp profile: %w", err)
+	}
+
+	return f.Name(), nil
+}
+
+func removeSeccompProfile(path string) {
+	if path != "" {
+		_ = os.Remove(path)
+	}
+}
+
+// containerArgs are the `run` arguments shared by Run and Start, from the
+// container name up to its command. profile is the path of a seccomp profile,
+// or "" for the engine's default.
+func containerArgs(spec ContainerSpec, profile string) []string {
+	mount := spec.HostDir + ":" + ContainerDir + ":ro"
+	if spec.Writable {
+		mount = spec.HostDir + ":" + ContainerDir
+	}
+
+	args := []string{
+		"--name", spec.Name,
+		"--network", "none",
+		"--read-only",
+		"--tmpfs", "/tmp",
+		"--cap-drop", "ALL",
+		"--security-opt", "no-new-privileges",
+		"--user", containerUser,
+		"-v", mount,
+		"-w", ContainerDir,
+	}
+	if profile != "" {
+		args = append(args, "--security-opt", "seccomp="+profile)
+	}
+	for _, env := range spec.Env {
+		args = append(args, "-e", env)
+	}
+	args = append(args, limitArgs(spec.Limits)...)
+	args = append(args, spec.Image)
+	args = append(args, spec.Command...)
+
+	return args
+}
+
+func limitArgs(limits domain.ResourceLimits) []string {
+	var args []string
+
+	if limits.MemoryBytes > 0 {
+		memory := strconv.FormatInt(limits.MemoryBytes, 10)
+		// Same value for memory-swap disables swap for the container.
+		args = append(args, "--memory", memory, "--memory-swap", memory)
+	}
+
+	if limits.Pids > 0 {
+		args = append(args, "--pids-limit", strconv.Itoa(limits.Pids))
+	}
+
+	if limits.CPUMillis > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(float64(limits.CPUMillis)/1000, 'f', 3, 64))
+	}
+
+	return args
+}
+
+func (e *CLIEngine) Remove(ctx context.Context, name string) error {
+	out, err := exec.CommandContext(ctx, e.binary, "rm", "-f", name).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%w: remove %s: %s", ErrEngineFailure, name, strings.TrimSpace(string(out)))
+	}
+
+	return nil
+}
@@ -0,0 +1,342 @@
+package dockerrunner
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/seccomp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	// keepAliveSeconds keeps a pooled container running until it is used;
+	// a plain number works with both GNU and busybox sleep.
+	keepAliveSeconds = "2147483647"
+
+	startTimeout = time.Minute
+)
+
+var (
+	ErrInvalidPool = errors.New("invalid container pool")
+)
+
+// warmContainer is a pre-started container of one language whose read-only
+// workspace is an empty host directory until it is handed out.
+type warmContainer struct {
+	name     string
+	language string
+	dir      string
+	limits   domain.ResourceLimits
+	// filtered tells that the container runs under the language's seccomp
+	// profile.
+	filtered  bool
+	idleSince time.Time
+	// removed tells that the container was removed early, e.g. to stop a
+	// program that timed out, and only its workspace is left.
+	removed bool
+}
+
+type PoolStats struct {
+	Idle      map[string]int
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+}
+
+// Pool keeps up to a configured number of started containers per language so
+// short programs do not pay for container start-up. Containers are used for
+// a single phase and destroyed afterwards; they are started with the
+// language's default limits and only serve executions with those limits.
+//
+// Containers idle for longer than idleTimeout are evicted and the language is
+// not refilled until the next miss.
+type Pool struct {
+	engine      Engine
+	baseDir     string
+	sizes       map[string]int
+	idleTimeout time.Duration
+
+	mu       sync.Mutex
+	idle     map[string][]*warmContainer
+	starting map[string]int
+	dormant  map[string]bool
+	pending  map[string]bool
+	stats    PoolStats
+
+	refill chan struct{}
+}
+
+// NewPool creates a pool with sizes containers per language name. Compiled
+// languages cannot be pooled: their workspace must be writable while
+// compiling, which a pre-started read-only mount cannot provide.
+func NewPool(engine Engine, baseDir string, sizes map[string]int, idleTimeout time.Duration) (*Pool, error) {
+	if engine == nil {
+		return nil, ErrNilEngine
+	}
+
+	if idleTimeout <= 0 {
+		return nil, fmt.Errorf("%w: idle timeout must be positive", ErrInvalidPool)
+	}
+
+	for name, size := range sizes {
+		lang, ok := domain.GetLanguage(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown language %q", ErrInvalidPool, name)
+		}
+		if lang.Compiled() {
+			return nil, fmt.Errorf("%w: compiled language %q cannot be pooled", ErrInvalidPool, name)
+		}
+		if size <= 0 {
+			return nil, fmt.Errorf("%w: size for %q must be positive", ErrInvalidPool, name)
+		}
+	}
+
+	return &Pool{
+		engine:      engine,
+		baseDir:     baseDir,
+		sizes:       sizes,
+		idleTimeout: idleTimeout,
+		idle:        make(map[string][]*warmContainer),
+		starting:    make(map[string]int),
+		dormant:     make(map[string]bool),
+		pending:     make(map[string]bool),
+		refill:      make(chan struct{}, 1),
+	}, nil
+}
+
+// Run fills the pool and keeps it filled until ctx is done, then destroys the
+// idle containers.
+func (p *Pool) Run(ctx context.Context) {
+	for name := range p.sizes {
+		p.fill(ctx, name)
+	}
+
+	ticker := time.NewTicker(p.idleTimeout / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			p.drain()
+			return
+		case <-p.refill:
+			for _, name := range p.takePending() {
+				p.fill(ctx, name)
+			}
+		case <-ticker.C:
+			p.evictIdle()
+		}
+	}
+}
+
+// acquire hands out a warm container of the language, if one with matching
+// limits is idle. Languages without a pool are neither hits nor misses.
+func (p *Pool) acquire(language string, limits domain.ResourceLimits) (*warmContainer, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, pooled := p.sizes[language]; !pooled {
+		return nil, false
+	}
+
+	idle := p.idle[language]
+	if len(idle) == 0 || idle[len(idle)-1].limits != limits {
+		p.stats.Misses++
+		if p.dormant[language] {
+			p.dormant[language] = false
+			p.requestRefill(language)
+		}
+		return nil, false
+	}
+
+	c := idle[len(idle)-1]
+	p.idle[language] = idle[:len(idle)-1]
+	p.stats.Hits++
+	p.requestRefill(language)
+
+	return c, true
+}
+
+// release destroys a container handed out by acquire together with its
+// workspace.
+func (p *Pool) release(c *warmContainer) {
+	p.destroy(c)
+}
+
+func (p *Pool) Stats() PoolStats {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	stats := p.stats
+	stats.Idle = make(map[string]int, len(p.sizes))
+	for name := range p.sizes {
+		stats.Idle[name] = len(p.idle[name])
+	}
+
+	return stats
+}
+
+// requestRefill must be called with mu held.
+func (p *Pool) requestRefill(language string) {
+	p.pending[language] = true
+
+	select {
+	case p.refill <- struct{}{}:
+	default:
+	}
+}
+
+func (p *Pool) takePending() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	names := make([]string, 0, len(p.pending))
+	for name := range p.pending {
+		names = append(names, name)
+		delete(p.pending, name)
+	}
+
+	return names
+}
+
+func (p *Pool) fill(ctx context.Context, language string) {
+	p.mu.Lock()
+	missing := p.sizes[language] - len(p.idle[language]) - p.starting[language]
+	if p.dormant[language] || missing <= 0 {
+		p.mu.Unlock()
+		return
+	}
+	p.starting[language] += missing
+	p.mu.Unlock()
+
+	for i := 0; i < missing; i++ {
+		go func() {
+			c, err := p.start(ctx, language)
+			if err == nil && ctx.Err() != nil {
+				// Started while the pool was draining.
+				p.destroy(c)
+				err = ctx.Err()
+			}
+
+			p.mu.Lock()
+			p.starting[language]--
+			if err == nil {
+				p.idle[language] = append(p.idle[language], c)
+			}
+			p.mu.Unlock()
+
+			if err != nil && ctx.Err() == nil {
+				log.Printf("start warm %s container: %v", language, err)
+			}
+		}()
+	}
+}
+
+func (p *Pool) start(ctx context.Context, language string) (*warmContainer, error) {
+	lang, ok := domain.GetLanguage(language)
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown language %q", ErrInvalidPool, language)
+	}
+
+	// Warm containers only serve run phases, which are filtered.
+	syscalls, err := seccomp.Resolve(lang.SeccompProfile, lang.SeccompSyscalls)
+	if err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(p.baseDir, "pool-"+language+"-")
+	if err != nil {
+		return nil, fmt.Errorf("create pool workspace: %w", err)
+	}
+
+	c := &warmContainer{
+		name:     "code-executor-" + filepath.Base(dir),
+		language: language,
+		dir:      dir,
+		limits:   lang.Limits(),
+		filtered: len(syscalls) > 0,
+	}
+
+	startCtx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+
+	err = p.engine.Start(startCtx, ContainerSpec{
+		Name:     c.name,
+		Image:    lang.DockerImage,
+		Command:  []string{"sleep", keepAliveSeconds},
+		HostDir:  dir,
+		Env:      []string{"HOME=/tmp"},
+		Limits:   c.limits,
+		Syscalls: syscalls,
+	})
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	c.idleSince = time.Now()
+	return c, nil
+}
+
+func (p *Pool) evictIdle() {
+	deadline := time.Now().Add(-p.idleTimeout)
+
+	var evicted []*warmContainer
+
+	p.mu.Lock()
+	for name, idle := range p.idle {
+		kept := idle[:0]
+		for _, c := range idle {
+			if c.idleSince.Before(deadline) {
+				evicted = append(evicted, c)
+			} else {
+				kept = append(kept, c)
+			}
+		}
+
+		if len(kept) < len(idle) {
+			p.dormant[name] = true
+		}
+		p.idle[name] = kept
+	}
+	p.stats.Evictions += uint64(len(evicted))
+	p.mu.Unlock()
+
+	for _, c := range evicted {
+		p.destroy(c)
+	}
+}
+
+func (p *Pool) drain() {
+	p.mu.Lock()
+	var all []*warmContainer
+	for name, idle := range p.idle {
+		all = append(all, idle...)
+		delete(p.idle, name)
+	}
+	p.mu.Unlock()
+
+	for _, c := range all {
+		p.destroy(c)
+	}
+}
+
+func (p *Pool) destroy(c *warmContainer) {
+	ctx, cancel := context.WithTimeout(context.Background(), removeTimeout)
+	defer cancel()
+
+	if !c.removed {
+		if err := p.engine.Remove(ctx, c.name); err != nil {
+			log.Printf("remove warm container %s: %v", c.name, err)
+		}
+	}
+
+	if err := os.RemoveAll(c.dir); err != nil {
+		log.Printf("remove warm workspace %s: %v", c.dir, err)
+	}
+}
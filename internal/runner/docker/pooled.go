@@ -0,0 +1,98 @@
+package dockerrunner
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/runner"
+	"Code_executor/internal/seccomp"
+	"context"
+	"fmt"
+	"time"
+)
+
+// pooledSession runs on a warm container and destroys it on Close.
+type pooledSession struct {
+	runner.Session
+	pool      *Pool
+	container *warmContainer
+}
+
+func (s *pooledSession) Close() error {
+	s.pool.release(s.container)
+	return nil
+}
+
+// pooledExecutor runs the first phase that fits the warm container in it and
+// every other phase in a cold container, so phases never share processes or
+// /tmp.
+type pooledExecutor struct {
+	runner    *Runner
+	container *warmContainer
+	used      bool
+}
+
+func (e *pooledExecutor) Execute(ctx context.Context, req runner.Request, ws *runner.Workspace, phase runner.Phase) (*runner.Result, error) {
+	if e.used || phase.Writable || phase.Limits != e.container.limits {
+		return e.runner.Execute(ctx, req, ws, phase)
+	}
+	e.used = true
+
+	runCtx, cancel := context.WithTimeout(ctx, phase.Timeout)
+	defer cancel()
+
+	// Cancelled early when either stream exceeds the output limit.
+	procCtx, stop := context.WithCancel(runCtx)
+	defer stop()
+
+	stdout := runner.NewOutputBuffer(req.MaxOutputBytes(), stop)
+	stderr := runner.NewOutputBuffer(req.MaxOutputBytes(), stop)
+
+	startedAt := time.Now()
+	containerResult, execErr := e.runner.engine.Exec(procCtx, e.container.name, ExecSpec{
+		Command: phase.Command,
+		Stdin:   phase.Stdin,
+		Env:     []string{"HOME=/tmp"},
+		Stdout:  stdout,
+		Stderr:  stderr,
+	})
+	finishedAt := time.Now()
+
+	// Only wall time: the CLI client's rusage says nothing about the
+	// container.
+	result := &runner.Result{
+		Stdout:          stdout.String(),
+		Stderr:          stderr.String(),
+		StdoutTruncated: stdout.Truncated(),
+		StderrTruncated: stderr.Truncated(),
+		Usage:           domain.ResourceUsage{WallTimeMs: finishedAt.Sub(startedAt).Milliseconds()},
+		StartedAt:       startedAt,
+		FinishedAt:      finishedAt,
+	}
+
+	if procCtx.Err() != nil {
+		// Killing the exec client leaves the process running.
+		e.runner.remove(e.container.name)
+		e.container.removed = true
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		result.TimedOut = runCtx.Err() != nil
+		return result, nil
+	}
+
+	if execErr != nil {
+		return nil, fmt.Errorf("exec in container %s: %w", e.container.name, execErr)
+	}
+
+	result.ExitCode = containerResult.ExitCode
+	if e.container.filtered && result.ExitCode == seccomp.KilledExitCode {
+		result.BlockedSyscall = seccomp.UnreportedSyscall
+	}
+
+	if containerResult.OOMKilled {
+		result.Violations = append(result.Violations, domain.ResourceViolationMemory)
+	}
+
+	return result, nil
+}
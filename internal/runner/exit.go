@@ -0,0 +1,16 @@
+package runner
+
+import (
+	"os"
+	"syscall"
+)
+
+// ExitCode is the exit status of a finished process; death by signal N is
+// reported the way shells and container engines do, as 128+N.
+func ExitCode(state *os.ProcessState) int {
+	if status, ok := state.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return state.ExitCode()
+}
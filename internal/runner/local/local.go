@@ -0,0 +1,146 @@
+package localrunner
+
+import (
+	"Code_executor/internal/cgroup"
+	"Code_executor/internal/runner"
+	"Code_executor/internal/seccomp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// waitDelay bounds how long we wait for stdout/stderr to drain after the
+// process was killed, e.g. when a grandchild still holds the pipes open.
+const waitDelay = time.Second
+
+var (
+	ErrSeccompUnsupported = errors.New("local runner cannot enforce a seccomp profile")
+)
+
+// Runner executes submissions as plain child processes of the worker using
+// the interpreters installed on the host. It offers no isolation beyond the
+// optional cgroup limits, so it refuses languages with a syscall allowlist
+// unless it was created to run them unfiltered.
+type Runner struct {
+	baseDir    string
+	cgroups    *cgroup.Manager
+	unfiltered bool
+}
+
+// NewRunner creates a local runner. cgroups may be nil, in which case the
+// execution's resource limits are not enforced. unfiltered runs languages
+// with a syscall allowlist without it instead of refusing them.
+func NewRunner(baseDir string, cgroups *cgroup.Manager, unfiltered bool) *Runner {
+	return &Runner{
+		baseDir:    baseDir,
+		cgroups:    cgroups,
+		unfiltered: unfiltered,
+	}
+}
+
+func (r *Runner) Prepare(ctx context.Context, req runner.Request) (runner.Session, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	syscalls, err := seccomp.Resolve(req.Language.SeccompProfile, req.Language.SeccompSyscalls)
+	if err != nil {
+		return nil, err
+	}
+	if len(syscalls) > 0 && !r.unfiltered {
+		// Running the program unfiltered would silently drop the
+		// restriction, so the execution fails instead.
+		return nil, fmt.Errorf("%w: %w for %s", runner.ErrInvalidRunRequest, ErrSeccompUnsupported, req.Language.Name)
+	}
+
+	return runner.PrepareSession(r.baseDir, req, r)
+}
+
+func (r *Runner) Execute(ctx context.Context, req runner.Request, ws *runner.Workspace, phase runner.Phase) (*runner.Result, error) {
+	runCtx, cancel := context.WithTimeout(ctx, phase.Timeout)
+	defer cancel()
+
+	// Cancelled early when either stream exceeds the output limit.
+	procCtx, stop := context.WithCancel(runCtx)
+	defer stop()
+
+	argv := phase.Command
+	cmd := exec.CommandContext(procCtx, argv[0], argv[1:]...)
+	cmd.Dir = ws.Dir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "HOME=" + ws.Dir}
+	cmd.Stdin = strings.NewReader(phase.Stdin)
+	cmd.WaitDelay = waitDelay
+
+	stdout := runner.NewOutputBuffer(req.MaxOutputBytes(), stop)
+	stderr := runner.NewOutputBuffer(req.MaxOutputBytes(), stop)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	var cg *cgroup.Cgroup
+	var err error
+	if r.cgroups != nil {
+		cg, err = r.cgroups.Create("exec-"+req.ResourceName(phase), phase.Limits)
+		if err != nil {
+			return nil, err
+		}
+		defer cg.Close()
+
+		cg.Attach(cmd)
+		cmd.Cancel = func() error {
+			_ = cg.Kill()
+			return cmd.Process.Kill()
+		}
+	}
+
+	startedAt := time.Now()
+	runErr := cmd.Run()
+	finishedAt := time.Now()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	result := &runner.Result{
+		Stdout:          stdout.String(),
+		Stderr:          stderr.String(),
+		StdoutTruncated: stdout.Truncated(),
+		StderrTruncated: stderr.Truncated(),
+		Usage:           runner.ProcessUsage(cmd.ProcessState, finishedAt.Sub(startedAt)),
+		StartedAt:       startedAt,
+		FinishedAt:      finishedAt,
+	}
+
+	if cg != nil {
+		result.Violations, err = cg.Violations()
+		if err != nil {
+			return nil, err
+		}
+
+		usage, err := cg.Usage()
+		if err != nil {
+			return nil, err
+		}
+		result.Usage = runner.PreferUsage(usage, result.Usage)
+	}
+
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		result.TimedOut = true
+		return result, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		result.ExitCode = runner.ExitCode(exitErr.ProcessState)
+		return result, nil
+	}
+
+	if runErr != nil {
+		return nil, fmt.Errorf("run %s: %w", argv[0], runErr)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,63 @@
+package runner
+
+import (
+	"bytes"
+	"sync"
+)
+
+// OutputBuffer collects a stream of program output up to a limit. Once the
+// limit is crossed the rest is discarded, Truncated reports true and
+// onExceed is called once, typically to stop the program.
+type OutputBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+	onExceed  func()
+}
+
+// NewOutputBuffer creates a buffer; limit <= 0 means unlimited and onExceed
+// may be nil.
+func NewOutputBuffer(limit int, onExceed func()) *OutputBuffer {
+	return &OutputBuffer{
+		limit:    limit,
+		onExceed: onExceed,
+	}
+}
+
+// Write never fails, so the program is not disturbed by EPIPE before the
+// runner gets around to killing it.
+func (b *OutputBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.truncated {
+		return len(p), nil
+	}
+
+	if b.limit > 0 && b.buf.Len()+len(p) > b.limit {
+		b.buf.Write(p[:b.limit-b.buf.Len()])
+		b.truncated = true
+		if b.onExceed != nil {
+			b.onExceed()
+		}
+		return len(p), nil
+	}
+
+	b.buf.Write(p)
+	return len(p), nil
+}
+
+func (b *OutputBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func (b *OutputBuffer) Truncated() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.truncated
+}
@@ -0,0 +1,123 @@
+package runner
+
+import (
+	"Code_executor/internal/domain"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidRunRequest = errors.New("invalid run request")
+	ErrNotCompiled       = errors.New("language has no compile phase")
+)
+
+// Runner prepares isolated sessions for executions.
+type Runner interface {
+	Prepare(ctx context.Context, req Request) (Session, error)
+}
+
+// Session owns the workspace of one execution. Compile, when the language
+// needs it, must come before Run; Run may be called several times.
+type Session interface {
+	Compile(ctx context.Context) (*Result, error)
+	Run(ctx context.Context, in RunInput) (*Result, error)
+	Close() error
+}
+
+// Executor is what a runner backend implements: start one process for a
+// phase inside the workspace and collect its result.
+type Executor interface {
+	Execute(ctx context.Context, req Request, ws *Workspace, phase Phase) (*Result, error)
+}
+
+type Request struct {
+	Execution *domain.Execution
+	Language  *domain.Language
+}
+
+type RunInput struct {
+	Stdin     string
+	TimeoutMs int
+	// Args are appended to the run command; Files are written into the
+	// workspace first, replacing files of earlier runs with the same name.
+	Args  []string
+	Files map[string]string
+}
+
+// Phase describes one process started inside a session.
+type Phase struct {
+	// Name is unique within the session, e.g. "compile" or "run-1", and
+	// is used to name per-phase resources such as cgroups and containers.
+	Name    string
+	Command []string
+	Stdin   string
+	Timeout time.Duration
+	Limits  domain.ResourceLimits
+	// Writable lets the phase write into the workspace (compilers need to
+	// emit binaries); runs always see it read-only.
+	Writable bool
+	// Filtered applies the language's seccomp allowlist.
+	Filtered bool
+}
+
+type Result struct {
+	Stdout          string
+	Stderr          string
+	StdoutTruncated bool
+	StderrTruncated bool
+	ExitCode        int
+	TimedOut        bool
+	Violations      []domain.ResourceViolation
+	// BlockedSyscall names the syscall that made the seccomp filter kill the
+	// program, if any; seccomp.UnreportedSyscall if the runner cannot tell.
+	BlockedSyscall string
+	Usage          domain.ResourceUsage
+	StartedAt      time.Time
+	FinishedAt     time.Time
+}
+
+func (r Request) Validate() error {
+	if r.Execution == nil || r.Language == nil {
+		return fmt.Errorf("%w: execution and language are required", ErrInvalidRunRequest)
+	}
+
+	if r.Execution.Language != r.Language.Name {
+		return fmt.Errorf("%w: execution language %q does not match %q", ErrInvalidRunRequest, r.Execution.Language, r.Language.Name)
+	}
+
+	if r.Execution.TimeoutMs <= 0 {
+		return fmt.Errorf("%w: timeout must be positive", ErrInvalidRunRequest)
+	}
+
+	return nil
+}
+
+// ResourceName is a per-phase identifier safe for cgroup and container names.
+func (r Request) ResourceName(phase Phase) string {
+	return r.Execution.ID + "-" + phase.Name
+}
+
+func (r Request) MaxOutputBytes() int {
+	if r.Language.MaxOutputBytes == nil {
+		return 0
+	}
+
+	return *r.Language.MaxOutputBytes
+}
+
+// OutputLimitExceeded reports whether the program was stopped for writing
+// more than the language allows.
+func (r *Result) OutputLimitExceeded() bool {
+	return r.StdoutTruncated || r.StderrTruncated
+}
+
+// Failed reports whether the phase did not finish cleanly on its own.
+func (r *Result) Failed() bool {
+	return r.TimedOut || r.ExitCode != 0 || r.OutputLimitExceeded() || r.BlockedSyscall != "" || len(r.Violations) > 0
+}
+
+func (r *Result) Duration() time.Duration {
+	return r.FinishedAt.Sub(r.StartedAt)
+}
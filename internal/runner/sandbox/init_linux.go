@@ -0,0 +1,408 @@
+//go:build linux
+
+package sandboxrunner
+
+import (
+	"Code_executor/internal/seccomp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// runInit runs as PID 1 of the new namespaces. It builds the mount tree,
+// pivots into the read-only rootfs and starts the payload child, which drops
+// every privilege before exec'ing the submission. With a syscall allowlist
+// init also traces the child.
+func runInit(rawConfig string) {
+	reports := os.NewFile(reportFD, "report")
+	syscall.CloseOnExec(reportFD)
+
+	exitCode, err := initSandbox(rawConfig, reports)
+	if err != nil {
+		writeReport(reports, report{Error: err.Error()})
+		os.Exit(1)
+	}
+
+	os.Exit(exitCode)
+}
+
+func initSandbox(rawConfig string, reports *os.File) (int, error) {
+	var cfg initConfig
+	if err := json.Unmarshal([]byte(rawConfig), &cfg); err != nil {
+		return 0, fmt.Errorf("decode config: %w", err)
+	}
+
+	if len(cfg.Command) == 0 {
+		return 0, fmt.Errorf("empty command")
+	}
+
+	// The worker binary is not reachable after pivot_root; keep a handle
+	// for starting the payload child.
+	self, err := os.Open("/proc/self/exe")
+	if err != nil {
+		return 0, fmt.Errorf("open self: %w", err)
+	}
+	defer self.Close()
+
+	if err := setupMounts(cfg.Rootfs, cfg.Workspace, cfg.Writable); err != nil {
+		return 0, err
+	}
+
+	if err := pivotRoot(cfg.Rootfs); err != nil {
+		return 0, err
+	}
+
+	if err := syscall.Sethostname([]byte(hostname)); err != nil {
+		return 0, fmt.Errorf("sethostname: %w", err)
+	}
+
+	if err := os.Chdir(workspaceDir); err != nil {
+		return 0, fmt.Errorf("chdir workspace: %w", err)
+	}
+
+	// LookPath consults PATH of this process, so use the sandbox one.
+	if err := os.Setenv("PATH", sandboxPath[len("PATH="):]); err != nil {
+		return 0, fmt.Errorf("set PATH: %w", err)
+	}
+
+	cmd, err := payloadCommand(self, cfg, reports)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(cfg.Syscalls) > 0 {
+		return traceFiltered(cmd, reports)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("start payload: %w", err)
+	}
+
+	err = cmd.Wait()
+	// Leftover background processes would otherwise keep the sandbox alive
+	// until the timeout.
+	killSandbox()
+
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return 0, fmt.Errorf("wait payload: %w", err)
+	}
+
+	return exitStatus(cmd.ProcessState.Sys().(syscall.WaitStatus)), nil
+}
+
+// payloadCommand re-executes the worker binary as the payload child. The
+// child gets its own user namespace, where only payloadID is mapped (to the
+// sandbox root), and its own mount namespace. Mounts copied into a less
+// privileged user namespace are locked by the kernel, so not even a root of
+// the child namespace could remount them writable or uncover what is beneath.
+// CAP_SETPCAP survives the switch to payloadID only to empty the bounding set.
+func payloadCommand(self *os.File, cfg initConfig, reports *os.File) (*exec.Cmd, error) {
+	rawConfig, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("encode payload config: %w", err)
+	}
+
+	cmd := exec.Command(fmt.Sprintf("/proc/self/fd/%d", self.Fd()), execArg, string(rawConfig))
+	cmd.Env = cfg.Env
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{reports}
+	cmd.SysProcAttr = &syscall.SysProcAttr{
+		Cloneflags: syscall.CLONE_NEWUSER | syscall.CLONE_NEWNS,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: payloadID, HostID: 0, Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: payloadID, HostID: 0, Size: 1},
+		},
+		GidMappingsEnableSetgroups: false,
+		Credential:                 &syscall.Credential{Uid: payloadID, Gid: payloadID, NoSetGroups: true},
+		AmbientCaps:                []uintptr{capSetPCap},
+		Pdeathsig:                  syscall.SIGKILL,
+	}
+
+	return cmd, nil
+}
+
+// runExec is the payload child. It drops its remaining capabilities, installs
+// the seccomp filter if there is an allowlist and replaces itself with the
+// submission.
+func runExec(rawConfig string) {
+	reports := os.NewFile(reportFD, "report")
+	syscall.CloseOnExec(reportFD)
+
+	if err := execPayload(rawConfig); err != nil {
+		writeReport(reports, report{Error: err.Error()})
+		os.Exit(1)
+	}
+}
+
+func execPayload(rawConfig string) error {
+	var cfg initConfig
+	if err := json.Unmarshal([]byte(rawConfig), &cfg); err != nil {
+		return fmt.Errorf("decode config: %w", err)
+	}
+
+	path, err := exec.LookPath(cfg.Command[0])
+	if err != nil {
+		return fmt.Errorf("lookup %s: %w", cfg.Command[0], err)
+	}
+
+	// Credentials are per thread; the one that drops them must also exec.
+	runtime.LockOSThread()
+
+	if err := dropCapabilities(); err != nil {
+		return err
+	}
+
+	if len(cfg.Syscalls) > 0 {
+		if err := seccomp.Install(cfg.Syscalls); err != nil {
+			return err
+		}
+	}
+
+	if err := syscall.Exec(path, cfg.Command, cfg.Env); err != nil {
+		return fmt.Errorf("exec %s: %w", path, err)
+	}
+
+	return nil
+}
+
+// dropCapabilities empties the bounding, ambient, inheritable, permitted and
+// effective sets of the calling thread and sets no_new_privs, so neither the
+// submission nor anything it runs can gain a capability.
+func dropCapabilities() error {
+	for c := uintptr(0); ; c++ {
+		_, _, errno := syscall.RawSyscall(syscall.SYS_PRCTL, prCapBSetDrop, c, 0)
+		if errno == syscall.EINVAL {
+			// past the last capability the kernel knows
+			break
+		}
+		if errno != 0 {
+			return fmt.Errorf("drop capability %d from bounding set: %w", c, errno)
+		}
+	}
+
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prCapAmbient, prCapAmbientClearAll, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("clear ambient capabilities: %w", errno)
+	}
+
+	hdr := capHeader{version: linuxCapabilityVersion3}
+	var data [2]capData
+	if _, _, errno := syscall.RawSyscall(syscall.SYS_CAPSET, uintptr(unsafe.Pointer(&hdr)), uintptr(unsafe.Pointer(&data[0])), 0); errno != 0 {
+		return fmt.Errorf("capset: %w", errno)
+	}
+
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS): %w", errno)
+	}
+
+	return nil
+}
+
+// setupMounts leaves the workspace writable only for compile phases, which
+// also get a larger /tmp for toolchain caches.
+func setupMounts(rootfs, workspace string, writable bool) error {
+	// Keep our mounts from propagating back to the host namespace.
+	if err := syscall.Mount("", "/", "", syscall.MS_REC|syscall.MS_PRIVATE, ""); err != nil {
+		return fmt.Errorf("make mounts private: %w", err)
+	}
+
+	if err := syscall.Mount(rootfs, rootfs, "", syscall.MS_BIND|syscall.MS_REC, ""); err != nil {
+		return fmt.Errorf("bind rootfs: %w", err)
+	}
+
+	// MS_REC copies the mounts below the rootfs too, and a remount only
+	// changes the one it is pointed at. Later mounts on top of the read-only
+	// tree keep their own flags.
+	if err := remountTreeReadOnly(rootfs); err != nil {
+		return err
+	}
+
+	target := filepath.Join(rootfs, workspaceDir)
+	if err := syscall.Mount(workspace, target, "", syscall.MS_BIND, ""); err != nil {
+		return fmt.Errorf("bind workspace: %w", err)
+	}
+	if !writable {
+		if err := remountReadOnly(target); err != nil {
+			return err
+		}
+	}
+
+	// A fresh procfs only shows processes of the new PID namespace.
+	if err := syscall.Mount("proc", filepath.Join(rootfs, "proc"), "proc", syscall.MS_NOSUID|syscall.MS_NODEV|syscall.MS_NOEXEC, ""); err != nil {
+		return fmt.Errorf("mount proc: %w", err)
+	}
+
+	tmpSize := "size=64m"
+	if writable {
+		tmpSize = "size=256m"
+	}
+	if err := syscall.Mount("tmpfs", filepath.Join(rootfs, "tmp"), "tmpfs", syscall.MS_NOSUID|syscall.MS_NODEV, tmpSize+",mode=1777"); err != nil {
+		return fmt.Errorf("mount tmp: %w", err)
+	}
+
+	return nil
+}
+
+// remountTreeReadOnly remounts root and every mount below it read-only.
+func remountTreeReadOnly(root string) error {
+	root, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", root, err)
+	}
+
+	mountPoints, err := mountPointsUnder(root)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range mountPoints {
+		if err := remountReadOnly(target); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mountPointsUnder lists the mount points of /proc/self/mountinfo at or below
+// root, parents before children.
+func mountPointsUnder(root string) ([]string, error) {
+	data, err := os.ReadFile("/proc/self/mountinfo")
+	if err != nil {
+		return nil, fmt.Errorf("read mountinfo: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	var mountPoints []string
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 5 {
+			continue
+		}
+
+		target := unescapeMountPath(fields[4])
+		if target != root && !strings.HasPrefix(target, root+"/") || seen[target] {
+			continue
+		}
+		seen[target] = true
+		mountPoints = append(mountPoints, target)
+	}
+
+	slices.Sort(mountPoints)
+	return mountPoints, nil
+}
+
+// unescapeMountPath undoes the octal escapes (\040 for a space and so on) of
+// mountinfo paths.
+func unescapeMountPath(path string) string {
+	if !strings.Contains(path, "\\") {
+		return path
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		if path[i] == '\\' && i+3 < len(path) {
+			if n, err := strconv.ParseUint(path[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(path[i])
+	}
+
+	return b.String()
+}
+
+// remountReadOnly turns a bind mount read-only. Flags the kernel locked when
+// the mount was inherited into our user namespace must be preserved or the
+// remount is rejected with EPERM.
+func remountReadOnly(target string) error {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(target, &st); err != nil {
+		return fmt.Errorf("statfs %s: %w", target, err)
+	}
+
+	flags := uintptr(syscall.MS_BIND | syscall.MS_REMOUNT | syscall.MS_RDONLY)
+	for stFlag, msFlag := range map[int64]uintptr{
+		stNoSuid:     syscall.MS_NOSUID,
+		stNoDev:      syscall.MS_NODEV,
+		stNoExec:     syscall.MS_NOEXEC,
+		stNoAtime:    syscall.MS_NOATIME,
+		stNoDirAtime: syscall.MS_NODIRATIME,
+		stRelAtime:   syscall.MS_RELATIME,
+	} {
+		if int64(st.Flags)&stFlag != 0 {
+			flags |= msFlag
+		}
+	}
+
+	if err := syscall.Mount("", target, "", flags, ""); err != nil {
+		return fmt.Errorf("remount %s read-only: %w", target, err)
+	}
+
+	return nil
+}
+
+func pivotRoot(rootfs string) error {
+	if err := os.Chdir(rootfs); err != nil {
+		return fmt.Errorf("chdir rootfs: %w", err)
+	}
+
+	// Stack the new root on top of the old one, then lazily detach the old
+	// root, so no writable directory is needed for it.
+	if err := syscall.PivotRoot(".", "."); err != nil {
+		return fmt.Errorf("pivot_root: %w", err)
+	}
+
+	if err := syscall.Unmount(".", syscall.MNT_DETACH); err != nil {
+		return fmt.Errorf("detach old root: %w", err)
+	}
+
+	return os.Chdir("/")
+}
+
+// Capability constants of <linux/prctl.h> and <linux/capability.h>.
+const (
+	prCapBSetDrop        = 24
+	prSetNoNewPrivs      = 38
+	prCapAmbient         = 47
+	prCapAmbientClearAll = 4
+
+	capSetPCap              = 8
+	linuxCapabilityVersion3 = 0x20080522
+)
+
+type capHeader struct {
+	version uint32
+	pid     int32
+}
+
+type capData struct {
+	effective   uint32
+	permitted   uint32
+	inheritable uint32
+}
+
+// statfs f_flags values (ST_* in <sys/statvfs.h>).
+const (
+	stNoSuid     = 0x2
+	stNoDev      = 0x4
+	stNoExec     = 0x8
+	stNoAtime    = 0x400
+	stNoDirAtime = 0x800
+	stRelAtime   = 0x1000
+)
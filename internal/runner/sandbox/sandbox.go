@@ -0,0 +1,95 @@
+package sandboxrunner
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+)
+
+const (
+	// initArg is argv[1] of the re-executed worker binary that sets up the
+	// namespaces' mounts before exec'ing the submission.
+	initArg = "__sandbox_init"
+	// execArg starts the payload child, which drops its privileges, installs
+	// the seccomp filter when the language has a syscall allowlist and execs
+	// the submission.
+	execArg = "__sandbox_exec"
+
+	// reportFD is the first ExtraFiles entry of the init process.
+	reportFD = 3
+)
+
+const (
+	workspaceDir = "/workspace"
+	sandboxPath  = "PATH=/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+	hostname     = "sandbox"
+)
+
+var (
+	ErrUnsupported    = errors.New("namespace sandbox is not supported on this platform")
+	ErrInvalidRootfs  = errors.New("invalid sandbox rootfs")
+	ErrSandboxSetup   = errors.New("sandbox setup failed")
+	ErrEmptyRootfsDir = errors.New("rootfs directory is empty")
+)
+
+// initConfig is handed from the worker to the init process as argv[2].
+type initConfig struct {
+	Rootfs    string   `json:"rootfs"`
+	Workspace string   `json:"workspace"`
+	Writable  bool     `json:"writable,omitempty"`
+	Command   []string `json:"command"`
+	Env       []string `json:"env"`
+	Syscalls  []string `json:"syscalls,omitempty"`
+}
+
+// report is written as one JSON line to reportFD by the sandbox processes, so
+// sandbox problems are not confused with output of the submission.
+type report struct {
+	Error          string `json:"error,omitempty"`
+	BlockedSyscall string `json:"blocked_syscall,omitempty"`
+}
+
+// MaybeInit must be called first thing in main of every binary that uses the
+// sandbox runner. In the re-executed children it never returns.
+func MaybeInit() {
+	if len(os.Args) < 3 {
+		return
+	}
+
+	switch os.Args[1] {
+	case initArg:
+		runInit(os.Args[2])
+	case execArg:
+		runExec(os.Args[2])
+	}
+}
+
+func writeReport(w io.Writer, rep report) {
+	data, err := json.Marshal(rep)
+	if err != nil {
+		return
+	}
+	_, _ = w.Write(append(data, '\n'))
+}
+
+func readReports(r io.Reader) report {
+	var merged report
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		var rep report
+		if err := json.Unmarshal(scanner.Bytes(), &rep); err != nil {
+			continue
+		}
+		if merged.Error == "" {
+			merged.Error = rep.Error
+		}
+		if merged.BlockedSyscall == "" {
+			merged.BlockedSyscall = rep.BlockedSyscall
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,243 @@
+//go:build linux
+
+package sandboxrunner
+
+import (
+	"Code_executor/internal/cgroup"
+	"Code_executor/internal/runner"
+	"Code_executor/internal/seccomp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	waitDelay = time.Second
+
+	// Host ids the sandbox root is mapped to when the worker runs as root.
+	nobodyID = 65534
+
+	// payloadID is the uid and gid of the submission in its nested user
+	// namespace; it maps to the sandbox root.
+	payloadID = 1000
+
+	cloneFlags = syscall.CLONE_NEWUSER |
+		syscall.CLONE_NEWNS |
+		syscall.CLONE_NEWPID |
+		syscall.CLONE_NEWNET |
+		syscall.CLONE_NEWIPC |
+		syscall.CLONE_NEWUTS
+)
+
+// Runner isolates every execution in fresh user, mount, PID, network, IPC and
+// UTS namespaces, with <rootfsDir>/<language> as a read-only root filesystem.
+// No container daemon is involved.
+type Runner struct {
+	baseDir   string
+	rootfsDir string
+	cgroups   *cgroup.Manager
+	self      string
+}
+
+// NewRunner creates a sandbox runner. cgroups may be nil, in which case the
+// execution's resource limits are not enforced.
+func NewRunner(baseDir, rootfsDir string, cgroups *cgroup.Manager) (*Runner, error) {
+	if rootfsDir == "" {
+		return nil, ErrEmptyRootfsDir
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return nil, fmt.Errorf("resolve worker executable: %w", err)
+	}
+
+	return &Runner{
+		baseDir:   baseDir,
+		rootfsDir: rootfsDir,
+		cgroups:   cgroups,
+		self:      self,
+	}, nil
+}
+
+func (r *Runner) Prepare(ctx context.Context, req runner.Request) (runner.Session, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := checkRootfs(r.rootfs(req)); err != nil {
+		return nil, err
+	}
+
+	if _, err := seccomp.Resolve(req.Language.SeccompProfile, req.Language.SeccompSyscalls); err != nil {
+		return nil, err
+	}
+
+	return runner.PrepareSession(r.baseDir, req, r)
+}
+
+func (r *Runner) Execute(ctx context.Context, req runner.Request, ws *runner.Workspace, phase runner.Phase) (*runner.Result, error) {
+	var syscalls []string
+	if phase.Filtered {
+		var err error
+		syscalls, err = seccomp.Resolve(req.Language.SeccompProfile, req.Language.SeccompSyscalls)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := json.Marshal(initConfig{
+		Rootfs:    r.rootfs(req),
+		Workspace: ws.Dir,
+		Writable:  phase.Writable,
+		Command:   phase.Command,
+		Env:       []string{sandboxPath, "HOME=/tmp"},
+		Syscalls:  syscalls,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("encode sandbox config: %w", err)
+	}
+
+	reportR, reportW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("create report pipe: %w", err)
+	}
+	defer reportR.Close()
+
+	runCtx, cancel := context.WithTimeout(ctx, phase.Timeout)
+	defer cancel()
+
+	// Cancelled early when either stream exceeds the output limit.
+	procCtx, stop := context.WithCancel(runCtx)
+	defer stop()
+
+	cmd := exec.CommandContext(procCtx, r.self, initArg, string(cfg))
+	cmd.Env = []string{}
+	cmd.Stdin = strings.NewReader(phase.Stdin)
+	cmd.ExtraFiles = []*os.File{reportW}
+	cmd.WaitDelay = waitDelay
+	cmd.SysProcAttr = sysProcAttr()
+
+	stdout := runner.NewOutputBuffer(req.MaxOutputBytes(), stop)
+	stderr := runner.NewOutputBuffer(req.MaxOutputBytes(), stop)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	var cg *cgroup.Cgroup
+	if r.cgroups != nil {
+		cg, err = r.cgroups.Create("exec-"+req.ResourceName(phase), phase.Limits)
+		if err != nil {
+			reportW.Close()
+			return nil, err
+		}
+		defer cg.Close()
+
+		cg.Attach(cmd)
+	}
+
+	startedAt := time.Now()
+	runErr := cmd.Start()
+	reportW.Close()
+	if runErr == nil {
+		runErr = cmd.Wait()
+	}
+	finishedAt := time.Now()
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+
+	rep := readReports(reportR)
+	if rep.Error != "" {
+		return nil, fmt.Errorf("%w: %s", ErrSandboxSetup, rep.Error)
+	}
+
+	result := &runner.Result{
+		Stdout:          stdout.String(),
+		Stderr:          stderr.String(),
+		StdoutTruncated: stdout.Truncated(),
+		StderrTruncated: stderr.Truncated(),
+		BlockedSyscall:  rep.BlockedSyscall,
+		Usage:           runner.ProcessUsage(cmd.ProcessState, finishedAt.Sub(startedAt)),
+		StartedAt:       startedAt,
+		FinishedAt:      finishedAt,
+	}
+
+	if cg != nil {
+		result.Violations, err = cg.Violations()
+		if err != nil {
+			return nil, err
+		}
+
+		usage, err := cg.Usage()
+		if err != nil {
+			return nil, err
+		}
+		result.Usage = runner.PreferUsage(usage, result.Usage)
+	}
+
+	if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		result.TimedOut = true
+		return result, nil
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(runErr, &exitErr) {
+		result.ExitCode = runner.ExitCode(exitErr.ProcessState)
+		return result, nil
+	}
+
+	if runErr != nil {
+		return nil, fmt.Errorf("start sandbox: %w", runErr)
+	}
+
+	return result, nil
+}
+
+func (r *Runner) rootfs(req runner.Request) string {
+	return filepath.Join(r.rootfsDir, req.Language.Name)
+}
+
+func sysProcAttr() *syscall.SysProcAttr {
+	uid, gid := os.Getuid(), os.Getgid()
+	if uid == 0 {
+		// Never map the sandbox root to the real root.
+		uid, gid = nobodyID, nobodyID
+	}
+
+	return &syscall.SysProcAttr{
+		Cloneflags: cloneFlags,
+		UidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: uid, Size: 1},
+		},
+		GidMappings: []syscall.SysProcIDMap{
+			{ContainerID: 0, HostID: gid, Size: 1},
+		},
+		GidMappingsEnableSetgroups: false,
+		// Init needs root of the new namespace for the mounts; an unmapped
+		// identity would lose its namespace capabilities on exec. The
+		// submission itself runs as payloadID.
+		Credential: &syscall.Credential{Uid: 0, Gid: 0, NoSetGroups: true},
+		Pdeathsig:  syscall.SIGKILL,
+	}
+}
+
+func checkRootfs(rootfs string) error {
+	for _, dir := range []string{"", workspaceDir, "/proc", "/tmp"} {
+		info, err := os.Stat(filepath.Join(rootfs, dir))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRootfs, err)
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("%w: %s is not a directory", ErrInvalidRootfs, filepath.Join(rootfs, dir))
+		}
+	}
+
+	return nil
+}
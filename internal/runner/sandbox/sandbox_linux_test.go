@@ -0,0 +1,361 @@
+//go:build linux
+
+package sandboxrunner
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/runner"
+	"context"
+	"debug/elf"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// probeArg makes the test binary, copied into the sandbox rootfs, act as the
+// submission.
+const probeArg = "__sandbox_probe"
+
+// sysClone3 is the same on amd64 and arm64.
+const sysClone3 = 435
+
+func TestMain(m *testing.M) {
+	MaybeInit()
+
+	if len(os.Args) > 2 && os.Args[1] == probeArg {
+		os.Exit(probe(os.Args[2], os.Args[3:]))
+	}
+
+	os.Exit(m.Run())
+}
+
+func probe(name string, args []string) int {
+	switch name {
+	case "pids":
+		entries, err := os.ReadDir("/proc")
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+
+		var pids []string
+		for _, entry := range entries {
+			if _, err := strconv.Atoi(entry.Name()); err == nil {
+				pids = append(pids, entry.Name())
+			}
+		}
+		fmt.Println(os.Getpid())
+		fmt.Println(strings.Join(pids, " "))
+	case "connect":
+		conn, err := net.DialTimeout("tcp", args[0], 2*time.Second)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		conn.Close()
+	case "privileges":
+		status, err := os.ReadFile("/proc/self/status")
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		for _, line := range strings.Split(string(status), "\n") {
+			if strings.HasPrefix(line, "Uid:") || strings.HasPrefix(line, "Gid:") || strings.HasPrefix(line, "Cap") {
+				fmt.Println(strings.Join(strings.Fields(line), " "))
+			}
+		}
+
+		for _, target := range []string{"/", workspaceDir} {
+			err := syscall.Mount("", target, "", syscall.MS_REMOUNT|syscall.MS_BIND, "")
+			fmt.Printf("remount %s: %v\n", target, err)
+		}
+		for _, path := range []string{"/probe-file", workspaceDir + "/probe-file"} {
+			fmt.Printf("write %s: %v\n", path, os.WriteFile(path, nil, 0o644))
+		}
+	case "clone":
+		_, _, errno := syscall.RawSyscall(syscall.SYS_CLONE, syscall.CLONE_NEWUSER|uintptr(syscall.SIGCHLD), 0, 0)
+		if errno == 0 {
+			// Only the child of a successful clone gets here with 0.
+			syscall.RawSyscall(syscall.SYS_EXIT, 0, 0, 0)
+		}
+		fmt.Printf("clone: %v\n", errno)
+
+		_, _, errno = syscall.RawSyscall(sysClone3, 0, 0, 0)
+		fmt.Printf("clone3: %v\n", errno)
+	default:
+		fmt.Println("unknown probe", name)
+		return 2
+	}
+
+	return 0
+}
+
+func TestSandboxPIDNamespace(t *testing.T) {
+	for _, profile := range []string{"", "native"} {
+		t.Run("profile="+profile, func(t *testing.T) {
+			result := runProbe(t, profile, "pids")
+			if result.ExitCode != 0 {
+				t.Fatalf("probe exited with %d: %s", result.ExitCode, result.Stdout)
+			}
+
+			lines := strings.Split(strings.TrimSpace(result.Stdout), "\n")
+			if len(lines) != 2 {
+				t.Fatalf("unexpected probe output %q", result.Stdout)
+			}
+
+			// Only init and the probe itself live in the namespace.
+			want := []string{"1", lines[0]}
+			got := strings.Fields(lines[1])
+			slices.Sort(want)
+			want = slices.Compact(want)
+			slices.Sort(got)
+			if !slices.Equal(got, want) {
+				t.Errorf("/proc lists pids %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestSandboxNetwork(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan struct{}, 1)
+	go func() {
+		if conn, err := listener.Accept(); err == nil {
+			conn.Close()
+			accepted <- struct{}{}
+		}
+	}()
+
+	result := runProbe(t, "", "connect", listener.Addr().String())
+	if result.ExitCode == 0 {
+		t.Fatalf("connect to %s succeeded inside the sandbox", listener.Addr())
+	}
+
+	select {
+	case <-accepted:
+		t.Fatal("host listener accepted a connection from the sandbox")
+	default:
+	}
+}
+
+func TestSandboxDropsPrivileges(t *testing.T) {
+	for _, profile := range []string{"", "native"} {
+		t.Run("profile="+profile, func(t *testing.T) {
+			result := runProbe(t, profile, "privileges")
+			if profile != "" {
+				// mount is outside the profile and kills the probe.
+				if result.BlockedSyscall != "mount" {
+					t.Errorf("blocked syscall = %q, want mount", result.BlockedSyscall)
+				}
+			} else if result.ExitCode != 0 {
+				t.Fatalf("probe exited with %d: %s", result.ExitCode, result.Stdout)
+			}
+
+			id := strconv.Itoa(payloadID)
+			for _, want := range []string{
+				"Uid: " + strings.Repeat(id+" ", 3) + id,
+				"Gid: " + strings.Repeat(id+" ", 3) + id,
+			} {
+				if !strings.Contains(result.Stdout, want+"\n") {
+					t.Errorf("probe output lacks %q:\n%s", want, result.Stdout)
+				}
+			}
+
+			caps := 0
+			for _, line := range strings.Split(result.Stdout, "\n") {
+				if !strings.HasPrefix(line, "Cap") {
+					continue
+				}
+				caps++
+				if !strings.HasSuffix(line, " 0000000000000000") {
+					t.Errorf("capability set not empty: %s", line)
+				}
+			}
+			if caps == 0 {
+				t.Errorf("probe output lacks capability sets:\n%s", result.Stdout)
+			}
+
+			if profile != "" {
+				return
+			}
+			for _, line := range strings.Split(strings.TrimSpace(result.Stdout), "\n") {
+				if (strings.HasPrefix(line, "remount ") || strings.HasPrefix(line, "write ")) && strings.HasSuffix(line, ": <nil>") {
+					t.Errorf("sandbox allowed %s", strings.TrimSuffix(line, ": <nil>"))
+				}
+			}
+		})
+	}
+}
+
+func TestSandboxRejectsNamespaceClone(t *testing.T) {
+	result := runProbe(t, "native", "clone")
+	if result.ExitCode != 0 || result.BlockedSyscall != "" {
+		t.Fatalf("probe exited with %d, blocked %q: %s", result.ExitCode, result.BlockedSyscall, result.Stdout)
+	}
+
+	for _, want := range []string{
+		"clone: " + syscall.EPERM.Error(),
+		"clone3: " + syscall.ENOSYS.Error(),
+	} {
+		if !strings.Contains(result.Stdout, want+"\n") {
+			t.Errorf("probe output lacks %q:\n%s", want, result.Stdout)
+		}
+	}
+}
+
+func runProbe(t *testing.T, profile string, args ...string) *runner.Result {
+	t.Helper()
+	requireUserNamespaces(t)
+
+	rootfsDir := tempDir(t)
+	rootfs := filepath.Join(rootfsDir, "probe")
+	for _, dir := range []string{workspaceDir, "/proc", "/tmp"} {
+		if err := os.MkdirAll(filepath.Join(rootfs, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	installProbe(t, rootfs)
+
+	r, err := NewRunner(tempDir(t), rootfsDir, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The sandbox root cannot enter the build directory of the test binary.
+	r.self = filepath.Join(rootfs, "probe")
+
+	lang := &domain.Language{
+		Name:           "probe",
+		RunCommand:     []string{"/probe", probeArg},
+		SeccompProfile: profile,
+	}
+	execution := &domain.Execution{
+		ID:        "probe",
+		Language:  lang.Name,
+		TimeoutMs: 10000,
+	}
+
+	ctx := context.Background()
+	session, err := r.Prepare(ctx, runner.Request{Execution: execution, Language: lang})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+
+	result, err := session.Run(ctx, runner.RunInput{Args: args})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.TimedOut {
+		t.Fatal("probe timed out")
+	}
+
+	return result
+}
+
+// requireUserNamespaces skips the test unless this process can create the
+// namespaces of the sandbox.
+func requireUserNamespaces(t *testing.T) {
+	t.Helper()
+
+	cmd := exec.Command("/proc/self/exe", "-test.run=^$")
+	cmd.SysProcAttr = sysProcAttr()
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Skipf("user namespaces unavailable: %v %s", err, out)
+	}
+}
+
+// installProbe copies the test binary to /probe of rootfs, along with the
+// libraries it loads when it is not statically linked.
+func installProbe(t *testing.T, rootfs string) {
+	t.Helper()
+
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []string{self}
+
+	bin, err := elf.Open(self)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bin.Close()
+
+	if slices.ContainsFunc(bin.Progs, func(prog *elf.Prog) bool { return prog.Type == elf.PT_INTERP }) {
+		out, err := exec.Command("ldd", self).Output()
+		if err != nil {
+			t.Skipf("list libraries of the dynamically linked test binary: %v", err)
+		}
+		for _, field := range strings.Fields(string(out)) {
+			if strings.HasPrefix(field, "/") && field != self {
+				files = append(files, field)
+			}
+		}
+	}
+
+	for _, src := range files {
+		dst := filepath.Join(rootfs, src)
+		if src == self {
+			dst = filepath.Join(rootfs, "probe")
+		}
+		if err := copyFile(src, dst); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
+
+// tempDir creates a directory that the unprivileged ids of the sandbox can
+// enter; t.TempDir only opens it up for the test's own user.
+func tempDir(t *testing.T) string {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "sandbox-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	if err := os.Chmod(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	return dir
+}
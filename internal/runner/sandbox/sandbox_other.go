@@ -0,0 +1,27 @@
+//go:build !linux
+
+package sandboxrunner
+
+import (
+	"Code_executor/internal/cgroup"
+	"Code_executor/internal/runner"
+	"context"
+)
+
+type Runner struct{}
+
+func NewRunner(string, string, *cgroup.Manager) (*Runner, error) {
+	return nil, ErrUnsupported
+}
+
+func (r *Runner) Prepare(context.Context, runner.Request) (runner.Session, error) {
+	return nil, ErrUnsupported
+}
+
+func (r *Runner) Execute(context.Context, runner.Request, *runner.Workspace, runner.Phase) (*runner.Result, error) {
+	return nil, ErrUnsupported
+}
+
+func runInit(string) {}
+
+func runExec(string) {}
@@ -0,0 +1,118 @@
+//go:build linux
+
+package sandboxrunner
+
+import (
+	"Code_executor/internal/seccomp"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"syscall"
+)
+
+const (
+	ptraceEventSeccomp = 7
+
+	ptraceOptions = syscall.PTRACE_O_TRACEFORK |
+		syscall.PTRACE_O_TRACEVFORK |
+		syscall.PTRACE_O_TRACECLONE |
+		syscall.PTRACE_O_TRACEEXEC |
+		ptraceOTraceSeccomp |
+		ptraceOExitKill
+
+	ptraceOTraceSeccomp = 0x80
+	ptraceOExitKill     = 0x100000
+)
+
+// traceFiltered starts the payload child under ptrace and follows it and all
+// of its descendants. When the filter traps a syscall, the name is reported
+// and every process in the sandbox is killed. The returned exit code mirrors
+// the child's, with 128+signal for fatal signals.
+func traceFiltered(cmd *exec.Cmd, reports *os.File) (int, error) {
+	// All ptrace requests must come from the thread that attached.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	cmd.SysProcAttr.Ptrace = true
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("start payload: %w", err)
+	}
+	child := cmd.Process.Pid
+
+	exitCode := 0
+	optionsSet := false
+	seen := map[int]bool{child: true}
+
+	for {
+		var status syscall.WaitStatus
+		pid, err := syscall.Wait4(-1, &status, syscall.WALL, nil)
+		if errors.Is(err, syscall.EINTR) {
+			continue
+		}
+		if errors.Is(err, syscall.ECHILD) {
+			return exitCode, nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("wait: %w", err)
+		}
+
+		switch {
+		case status.Exited() || status.Signaled():
+			if pid == child {
+				exitCode = exitStatus(status)
+				// Leftover background processes would otherwise keep
+				// the sandbox alive until the timeout.
+				killSandbox()
+			}
+			continue
+		case !status.Stopped():
+			continue
+		}
+
+		sig := status.StopSignal()
+		cause := status.TrapCause()
+		contSignal := 0
+
+		switch {
+		case pid == child && !optionsSet:
+			// First stop: the child just exec'd under PTRACE_TRACEME.
+			if err := syscall.PtraceSetOptions(pid, ptraceOptions); err != nil {
+				killSandbox()
+				return 0, fmt.Errorf("ptrace set options: %w", err)
+			}
+			optionsSet = true
+		case cause == ptraceEventSeccomp:
+			nr, err := syscall.PtraceGetEventMsg(pid)
+			if err == nil {
+				writeReport(reports, report{BlockedSyscall: seccomp.SyscallName(uint32(nr))})
+			}
+			killSandbox()
+			continue
+		case cause > 0:
+			// fork, vfork, clone and exec events
+		case sig == syscall.SIGSTOP && !seen[pid]:
+			// Initial stop of an automatically attached descendant.
+		default:
+			contSignal = int(sig)
+		}
+
+		seen[pid] = true
+		_ = syscall.PtraceCont(pid, contSignal)
+	}
+}
+
+// killSandbox kills every other process of the PID namespace; the init
+// process itself is exempt from kill(-1).
+func killSandbox() {
+	_ = syscall.Kill(-1, syscall.SIGKILL)
+}
+
+func exitStatus(status syscall.WaitStatus) int {
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return status.ExitStatus()
+}
@@ -0,0 +1,83 @@
+package runner
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+type session struct {
+	req      Request
+	ws       *Workspace
+	executor Executor
+	runs     int
+}
+
+// PrepareSession lays out the submission in a new workspace under baseDir and
+// returns a session whose phases are started by executor.
+func PrepareSession(baseDir string, req Request, executor Executor) (Session, error) {
+	ws, err := NewWorkspace(baseDir, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewSession(req, ws, executor), nil
+}
+
+// NewSession returns a session over an already laid out workspace, which is
+// removed on Close.
+func NewSession(req Request, ws *Workspace, executor Executor) Session {
+	return &session{
+		req:      req,
+		ws:       ws,
+		executor: executor,
+	}
+}
+
+func (s *session) Compile(ctx context.Context) (*Result, error) {
+	lang := s.req.Language
+	if !lang.Compiled() {
+		return nil, fmt.Errorf("%w: %s", ErrNotCompiled, lang.Name)
+	}
+
+	// The compiler is trusted tooling: it gets the language maximums and
+	// no seccomp filter, but still runs inside the sandbox.
+	return s.executor.Execute(ctx, s.req, s.ws, Phase{
+		Name:     "compile",
+		Command:  lang.Command(lang.CompileCommand, s.req.Execution.Entrypoint),
+		Timeout:  time.Duration(lang.CompileTimeoutMs) * time.Millisecond,
+		Limits:   lang.Limits(),
+		Writable: true,
+	})
+}
+
+// Run starts the program. A zero TimeoutMs means the execution's timeout.
+func (s *session) Run(ctx context.Context, in RunInput) (*Result, error) {
+	timeoutMs := in.TimeoutMs
+	if timeoutMs <= 0 {
+		timeoutMs = s.req.Execution.TimeoutMs
+	}
+
+	for name, content := range in.Files {
+		if err := s.ws.WriteFile(name, content); err != nil {
+			return nil, err
+		}
+	}
+
+	command := s.req.Language.Command(s.req.Language.RunCommand, s.req.Execution.Entrypoint)
+	command = append(command, in.Args...)
+
+	s.runs++
+	return s.executor.Execute(ctx, s.req, s.ws, Phase{
+		Name:     fmt.Sprintf("run-%d", s.runs),
+		Command:  command,
+		Stdin:    in.Stdin,
+		Timeout:  time.Duration(timeoutMs) * time.Millisecond,
+		Limits:   s.req.Execution.Limits,
+		Filtered: true,
+	})
+}
+
+func (s *session) Close() error {
+	return s.ws.Close()
+}
@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"Code_executor/internal/domain"
+	"os"
+	"time"
+)
+
+// ProcessUsage is the usage reported by wait for a finished process, which
+// covers the descendants it waited for. state may be nil if the process
+// never started.
+func ProcessUsage(state *os.ProcessState, wall time.Duration) domain.ResourceUsage {
+	usage := domain.ResourceUsage{WallTimeMs: wall.Milliseconds()}
+	if state == nil {
+		return usage
+	}
+
+	usage.UserCPUTimeMs = state.UserTime().Milliseconds()
+	usage.SystemCPUTimeMs = state.SystemTime().Milliseconds()
+	addRusage(state, &usage)
+
+	return usage
+}
+
+// PreferUsage fills the fields preferred did not measure from fallback.
+func PreferUsage(preferred, fallback domain.ResourceUsage) domain.ResourceUsage {
+	if preferred.WallTimeMs == 0 {
+		preferred.WallTimeMs = fallback.WallTimeMs
+	}
+	if preferred.UserCPUTimeMs == 0 && preferred.SystemCPUTimeMs == 0 {
+		preferred.UserCPUTimeMs = fallback.UserCPUTimeMs
+		preferred.SystemCPUTimeMs = fallback.SystemCPUTimeMs
+	}
+	if preferred.PeakMemoryBytes == 0 {
+		preferred.PeakMemoryBytes = fallback.PeakMemoryBytes
+	}
+	if preferred.IOReadBytes == 0 && preferred.IOWriteBytes == 0 {
+		preferred.IOReadBytes = fallback.IOReadBytes
+		preferred.IOWriteBytes = fallback.IOWriteBytes
+	}
+
+	return preferred
+}
@@ -0,0 +1,24 @@
+//go:build linux
+
+package runner
+
+import (
+	"Code_executor/internal/domain"
+	"os"
+	"syscall"
+)
+
+// blockSize is the unit of ru_inblock and ru_oublock.
+const blockSize = 512
+
+func addRusage(state *os.ProcessState, usage *domain.ResourceUsage) {
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok || rusage == nil {
+		return
+	}
+
+	// ru_maxrss is in KiB on Linux.
+	usage.PeakMemoryBytes = rusage.Maxrss * 1024
+	usage.IOReadBytes = rusage.Inblock * blockSize
+	usage.IOWriteBytes = rusage.Oublock * blockSize
+}
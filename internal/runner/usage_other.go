@@ -0,0 +1,10 @@
+//go:build !linux
+
+package runner
+
+import (
+	"Code_executor/internal/domain"
+	"os"
+)
+
+func addRusage(*os.ProcessState, *domain.ResourceUsage) {}
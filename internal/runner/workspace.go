@@ -0,0 +1,96 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type Workspace struct {
+	Dir string
+}
+
+// NewWorkspace creates a fresh directory under baseDir (os.TempDir when empty)
+// holding the submitted files.
+func NewWorkspace(baseDir string, req Request) (*Workspace, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(baseDir, "exec-"+req.Execution.ID+"-")
+	if err != nil {
+		return nil, fmt.Errorf("create workspace: %w", err)
+	}
+
+	if err := populate(dir, req); err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return &Workspace{Dir: dir}, nil
+}
+
+// FillWorkspace lays the submission out in an existing empty directory, e.g.
+// one already mounted into a pre-started container.
+func FillWorkspace(dir string, req Request) (*Workspace, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	if err := populate(dir, req); err != nil {
+		return nil, err
+	}
+
+	return &Workspace{Dir: dir}, nil
+}
+
+func populate(dir string, req Request) error {
+	// Sandboxed phases use an unprivileged uid that must still read the
+	// code, and write the binary for compiled languages.
+	mode := os.FileMode(0o755)
+	if req.Language.Compiled() {
+		mode = 0o777
+	}
+	if err := os.Chmod(dir, mode); err != nil {
+		return fmt.Errorf("chmod workspace: %w", err)
+	}
+
+	for name, content := range req.Execution.Files {
+		if err := writeFile(dir, name, content, mode); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFile writes one submitted file, creating its parent directories with
+// the workspace's mode. Paths were validated by the domain; the prefix check
+// only guards against writing outside dir.
+func writeFile(dir, name, content string, dirMode os.FileMode) error {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+		return fmt.Errorf("%w: file %q escapes the workspace", ErrInvalidRunRequest, name)
+	}
+
+	for parent := filepath.Dir(path); parent != dir; parent = filepath.Dir(parent) {
+		if err := os.Mkdir(parent, dirMode); err != nil && !os.IsExist(err) {
+			return fmt.Errorf("create directory for %s: %w", name, err)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func (w *Workspace) WriteFile(name, content string) error {
+	return writeFile(w.Dir, name, content, 0o755)
+}
+
+func (w *Workspace) Close() error {
+	return os.RemoveAll(w.Dir)
+}
@@ -0,0 +1,191 @@
+package scheduler
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/repository"
+	"Code_executor/internal/service"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const defaultCronInterval = 5 * time.Second
+
+var (
+	ErrInvalidCron = errors.New("invalid cron scheduler")
+)
+
+// Cron creates the executions of schedules that are due. Any number of
+// instances may run against the same repository; each run is made by the
+// instance that advanced the schedule. With a transactor the advance is
+// committed with the runs; without one, a run that fails moves the schedule
+// back to it, but the runs are lost if the instance dies in between.
+type Cron struct {
+	schedules  repository.ScheduleRepository
+	executions service.ExecutionService
+	transactor service.Transactor
+	interval   time.Duration
+	batchSize  int
+	now        func() time.Time
+}
+
+type CronDeps struct {
+	Schedules  repository.ScheduleRepository
+	Executions service.ExecutionService
+	// Transactor is optional; it must cover both Schedules and Executions.
+	Transactor service.Transactor
+	// Interval is how often due schedules are looked for, and so how late a
+	// run may start.
+	Interval  time.Duration
+	BatchSize int
+	Now       func() time.Time
+}
+
+func NewCron(deps CronDeps) (*Cron, error) {
+	if deps.Schedules == nil || deps.Executions == nil {
+		return nil, fmt.Errorf("%w: missing dependencies", ErrInvalidCron)
+	}
+
+	if deps.Interval < 0 || deps.BatchSize < 0 {
+		return nil, fmt.Errorf("%w: interval and batch size must not be negative", ErrInvalidCron)
+	}
+
+	interval := deps.Interval
+	if interval == 0 {
+		interval = defaultCronInterval
+	}
+
+	batchSize := deps.BatchSize
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+
+	nowFn := deps.Now
+	if nowFn == nil {
+		nowFn = time.Now
+	}
+
+	return &Cron{
+		schedules:  deps.Schedules,
+		executions: deps.Executions,
+		transactor: deps.Transactor,
+		interval:   interval,
+		batchSize:  batchSize,
+		now:        nowFn,
+	}, nil
+}
+
+// Run creates the executions of due schedules until ctx is done.
+func (c *Cron) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		// A full batch means more schedules may be due.
+		if c.runDue(ctx) == c.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runDue runs a batch of due schedules and returns how many were due.
+func (c *Cron) runDue(ctx context.Context) int {
+	due, err := c.schedules.ListDueSchedules(ctx, c.now(), c.batchSize)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("list due schedules: %v", err)
+		}
+		return 0
+	}
+
+	for _, schedule := range due {
+		if err := c.run(ctx, schedule); err != nil {
+			log.Printf("run schedule %s: %v", schedule.ID, err)
+		}
+	}
+
+	return len(due)
+}
+
+func (c *Cron) run(ctx context.Context, schedule *domain.Schedule) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+	defer cancel()
+
+	previous := *schedule
+	ticks, err := schedule.Advance(c.now())
+	if err != nil {
+		return err
+	}
+
+	if c.transactor == nil {
+		return c.advance(ctx, schedule, &previous, ticks)
+	}
+
+	// A failed run rolls back the advance and the runs created before it.
+	return c.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		return c.advance(ctx, schedule, &previous, ticks)
+	})
+}
+
+// advance stores schedule, moved on from previous, and creates the executions
+// of ticks.
+func (c *Cron) advance(ctx context.Context, schedule, previous *domain.Schedule, ticks []time.Time) error {
+	advanced, err := c.schedules.AdvanceSchedule(ctx, schedule, previous.NextRunAt)
+	if err != nil || !advanced {
+		return err
+	}
+
+	if len(ticks) == 0 {
+		log.Printf("schedule %s skipped runs missed since %s", schedule.ID, previous.NextRunAt.Format(time.RFC3339))
+	}
+
+	for i, tick := range ticks {
+		exec, err := c.executions.CreateExecutionAndEnqueue(ctx, service.CreateExecutionParams{
+			Language:   schedule.Language,
+			Code:       schedule.Code,
+			Stdin:      schedule.Stdin,
+			TimeoutMs:  schedule.TimeoutMs,
+			UserID:     schedule.UserID,
+			Priority:   schedule.Priority,
+			ScheduleID: schedule.ID,
+		})
+		if err != nil {
+			if c.transactor == nil {
+				c.rewind(ctx, schedule, previous, tick, i == 0)
+			}
+			return fmt.Errorf("create execution of run %s: %w", tick.Format(time.RFC3339), err)
+		}
+
+		log.Printf("schedule %s created execution %s for %s", schedule.ID, exec.ID, tick.Format(time.RFC3339))
+	}
+
+	return nil
+}
+
+// rewind moves schedule, advanced from previous, back to the run at tick, so
+// that it is made again along with the runs after it. first tells that no run
+// of the advance was made.
+func (c *Cron) rewind(ctx context.Context, schedule, previous *domain.Schedule, tick time.Time, first bool) {
+	// The run may have failed because ctx ran out.
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+	defer cancel()
+
+	rewound := *schedule
+	rewound.NextRunAt = tick
+	rewound.UpdatedAt = c.now().UTC()
+	if first {
+		rewound.LastRunAt = previous.LastRunAt
+	}
+
+	if _, err := c.schedules.AdvanceSchedule(ctx, &rewound, schedule.NextRunAt); err != nil {
+		log.Printf("move schedule %s back to %s: %v", schedule.ID, tick.Format(time.RFC3339), err)
+	}
+}
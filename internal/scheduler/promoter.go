@@ -0,0 +1,167 @@
+// Package scheduler queues scheduled executions once they are due.
+package scheduler
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/queue"
+	"Code_executor/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// saveTimeout bounds every repository and queue call of a promotion.
+const saveTimeout = 10 * time.Second
+
+const (
+	defaultInterval  = time.Second
+	defaultBatchSize = 100
+	defaultLease     = 30 * time.Second
+)
+
+var (
+	ErrInvalidPromoter = errors.New("invalid promoter")
+)
+
+// Promoter moves due jobs from the delayed queue to the work queue and marks
+// their executions queued. Any number of promoters may share a delayed
+// queue: each due job is claimed by one of them, and claimed again if its
+// promoter dies before removing it.
+//
+// An execution is marked queued before its job is enqueued, so workers never
+// see it scheduled. A promoter dying after marking it has the job enqueued
+// by the next claim; one dying after enqueueing it may get it enqueued twice.
+type Promoter struct {
+	repo      repository.ExecutionRepository
+	delayed   queue.DelayedQueue
+	producer  queue.Producer
+	interval  time.Duration
+	batchSize int
+	lease     time.Duration
+}
+
+type Deps struct {
+	Repo     repository.ExecutionRepository
+	Delayed  queue.DelayedQueue
+	Producer queue.Producer
+	// Interval is how often due jobs are looked for, and so how late a
+	// scheduled execution may be queued.
+	Interval  time.Duration
+	BatchSize int
+	// Lease is how long a claimed job stays with its promoter before another
+	// one may claim it.
+	Lease time.Duration
+}
+
+func New(deps Deps) (*Promoter, error) {
+	if deps.Repo == nil || deps.Delayed == nil || deps.Producer == nil {
+		return nil, fmt.Errorf("%w: missing dependencies", ErrInvalidPromoter)
+	}
+
+	if deps.Interval < 0 || deps.BatchSize < 0 || deps.Lease < 0 {
+		return nil, fmt.Errorf("%w: interval, batch size and lease must not be negative", ErrInvalidPromoter)
+	}
+
+	interval := deps.Interval
+	if interval == 0 {
+		interval = defaultInterval
+	}
+
+	batchSize := deps.BatchSize
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+
+	lease := deps.Lease
+	if lease == 0 {
+		lease = defaultLease
+	}
+
+	return &Promoter{
+		repo:      deps.Repo,
+		delayed:   deps.Delayed,
+		producer:  deps.Producer,
+		interval:  interval,
+		batchSize: batchSize,
+		lease:     lease,
+	}, nil
+}
+
+// Run promotes due jobs until ctx is done.
+func (p *Promoter) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		// A full batch means more jobs may be due.
+		if p.promoteDue(ctx) == p.batchSize && ctx.Err() == nil {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// promoteDue promotes a batch of due jobs and returns how many were claimed.
+func (p *Promoter) promoteDue(ctx context.Context) int {
+	due, err := p.delayed.ClaimDue(ctx, p.batchSize, p.lease)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("claim due executions: %v", err)
+		}
+		return 0
+	}
+
+	for _, d := range due {
+		if err := p.promote(ctx, d); err != nil {
+			// The job is claimed again once its lease expired.
+			log.Printf("promote execution %s: %v", d.ExecutionID, err)
+		}
+	}
+
+	return len(due)
+}
+
+func (p *Promoter) promote(ctx context.Context, d queue.Delivery) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+	defer cancel()
+
+	exec, err := p.repo.GetExecutionByID(ctx, d.ExecutionID)
+	if errors.Is(err, repository.ErrExecutionNotFound) {
+		return p.delayed.Remove(ctx, d)
+	}
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case exec.Status == domain.ExecutionStatusScheduled:
+		if err := exec.MarkQueued(); err != nil {
+			return err
+		}
+
+		if err := p.repo.UpdateExecution(ctx, exec); err != nil {
+			if errors.Is(err, repository.ErrExecutionFinished) {
+				return p.delayed.Remove(ctx, d)
+			}
+			return err
+		}
+	case exec.Status == domain.ExecutionStatusQueued && exec.StartedAt == nil && exec.Attempts == 0:
+		// Marked queued by a promoter that died before enqueueing the job.
+	default:
+		// Cancelled, or run since.
+		return p.delayed.Remove(ctx, d)
+	}
+
+	if err := p.producer.Enqueue(ctx, d.Job); err != nil {
+		return err
+	}
+
+	return p.delayed.Remove(ctx, d)
+}
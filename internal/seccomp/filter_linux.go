@@ -0,0 +1,126 @@
+//go:build linux && (amd64 || arm64)
+
+package seccomp
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	prSetNoNewPrivs = 38
+
+	seccompSetModeFilter = 1
+	seccompFlagTsync     = 1
+
+	retKillProcess = 0x80000000
+	retTrace       = 0x7ff00000
+	retErrno       = 0x00050000
+	retAllow       = 0x7fff0000
+
+	// x32 syscalls on amd64 carry this bit; they never match the allowlist
+	// and would lose it in the 16 bit SECCOMP_RET_DATA, so they are killed.
+	x32SyscallBit = 0x40000000
+
+	// offsets in struct seccomp_data; offsetArg0 is the low half of
+	// args[0] on these little-endian architectures
+	offsetNr   = 0
+	offsetArch = 4
+	offsetArg0 = 16
+)
+
+// Install loads an allowlist filter into the calling process; it is inherited
+// across fork and execve. A syscall outside the allowlist stops the process
+// with PTRACE_EVENT_SECCOMP and the syscall number as event message, so the
+// tracer can report it before killing the process. clone with a CLONE_NEW*
+// flag fails with EPERM and clone3 always with ENOSYS. Names unknown on this
+// architecture are ignored.
+func Install(allowed []string) error {
+	var numbers []uint32
+	for _, name := range allowed {
+		if nr, ok := syscallNumbers[name]; ok {
+			numbers = append(numbers, nr)
+		}
+	}
+
+	filter := buildFilter(numbers)
+	prog := syscall.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+
+	if _, _, errno := syscall.RawSyscall6(syscall.SYS_PRCTL, prSetNoNewPrivs, 1, 0, 0, 0, 0); errno != 0 {
+		return fmt.Errorf("prctl(PR_SET_NO_NEW_PRIVS): %w", errno)
+	}
+
+	// TSYNC applies the filter to every thread of the Go runtime, not only
+	// the one executing this call.
+	_, _, errno := syscall.RawSyscall(uintptr(syscallNumbers["seccomp"]), seccompSetModeFilter, seccompFlagTsync, uintptr(unsafe.Pointer(&prog)))
+	if errno != 0 {
+		return fmt.Errorf("seccomp(SECCOMP_SET_MODE_FILTER): %w", errno)
+	}
+
+	return nil
+}
+
+// SyscallName maps a syscall number of this architecture back to its name.
+func SyscallName(nr uint32) string {
+	for name, n := range syscallNumbers {
+		if n == nr {
+			return name
+		}
+	}
+
+	return fmt.Sprintf("syscall %d", nr)
+}
+
+func buildFilter(allowed []uint32) []syscall.SockFilter {
+	filter := []syscall.SockFilter{
+		stmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, offsetArch),
+		jump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, auditArch, 1, 0),
+		stmt(syscall.BPF_RET|syscall.BPF_K, retKillProcess),
+		stmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, offsetNr),
+		jump(syscall.BPF_JMP|syscall.BPF_JSET|syscall.BPF_K, x32SyscallBit, 0, 1),
+		stmt(syscall.BPF_RET|syscall.BPF_K, retKillProcess),
+	}
+
+	for _, nr := range allowed {
+		switch nr {
+		case syscallNumbers["clone"]:
+			// The flags are the first argument; loading it ends the
+			// comparisons, so both branches return.
+			filter = append(filter,
+				jump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, nr, 0, 4),
+				stmt(syscall.BPF_LD|syscall.BPF_W|syscall.BPF_ABS, offsetArg0),
+				jump(syscall.BPF_JMP|syscall.BPF_JSET|syscall.BPF_K, cloneNamespaceFlags, 0, 1),
+				stmt(syscall.BPF_RET|syscall.BPF_K, retErrno|errnoEPERM),
+				stmt(syscall.BPF_RET|syscall.BPF_K, retAllow),
+			)
+		case syscallNumbers["clone3"]:
+			filter = append(filter,
+				jump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, nr, 0, 1),
+				stmt(syscall.BPF_RET|syscall.BPF_K, retErrno|errnoENOSYS),
+			)
+		default:
+			filter = append(filter,
+				jump(syscall.BPF_JMP|syscall.BPF_JEQ|syscall.BPF_K, nr, 0, 1),
+				stmt(syscall.BPF_RET|syscall.BPF_K, retAllow),
+			)
+		}
+	}
+
+	// The accumulator still holds the syscall number: return it as data.
+	return append(filter,
+		stmt(syscall.BPF_ALU|syscall.BPF_OR|syscall.BPF_K, retTrace),
+		stmt(syscall.BPF_RET|syscall.BPF_A, 0),
+	)
+}
+
+func stmt(code uint16, k uint32) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, K: k}
+}
+
+func jump(code uint16, k uint32, jt, jf uint8) syscall.SockFilter {
+	return syscall.SockFilter{Code: code, Jt: jt, Jf: jf, K: k}
+}
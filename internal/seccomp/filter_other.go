@@ -0,0 +1,13 @@
+//go:build !linux || !(amd64 || arm64)
+
+package seccomp
+
+import "fmt"
+
+func Install([]string) error {
+	return ErrUnsupported
+}
+
+func SyscallName(nr uint32) string {
+	return fmt.Sprintf("syscall %d", nr)
+}
@@ -0,0 +1,182 @@
+package seccomp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+var (
+	ErrUnsupported    = errors.New("seccomp is not supported on this platform")
+	ErrUnknownProfile = errors.New("unknown seccomp profile")
+)
+
+// interpreterSyscalls is what CPython and Node need for ordinary scripts:
+// file I/O inside the sandbox, threads, subprocesses, pipes and socketpairs.
+// Anything touching the network stack, other processes (ptrace,
+// process_vm_*), namespaces, mounts, kernel modules or BPF is left out.
+var interpreterSyscalls = []string{
+	// file I/O
+	"read", "write", "readv", "writev", "pread64", "pwrite64", "preadv", "pwritev", "preadv2", "pwritev2",
+	"open", "openat", "openat2", "close", "close_range", "creat", "lseek",
+	"stat", "fstat", "lstat", "newfstatat", "statx", "statfs", "fstatfs",
+	"access", "faccessat", "faccessat2", "readlink", "readlinkat",
+	"getdents", "getdents64", "getcwd", "chdir", "fchdir",
+	"mkdir", "mkdirat", "rmdir", "unlink", "unlinkat", "rename", "renameat", "renameat2",
+	"link", "linkat", "symlink", "symlinkat", "chmod", "fchmod", "fchmodat", "umask",
+	"truncate", "ftruncate", "fallocate", "fadvise64", "fsync", "fdatasync", "flock",
+	"utimensat", "sendfile", "copy_file_range", "splice",
+	"dup", "dup2", "dup3", "fcntl", "ioctl", "pipe", "pipe2",
+	"poll", "ppoll", "select", "pselect6",
+	"epoll_create", "epoll_create1", "epoll_ctl", "epoll_wait", "epoll_pwait", "epoll_pwait2",
+	"eventfd", "eventfd2", "timerfd_create", "timerfd_settime", "timerfd_gettime",
+	"socketpair", "sendmsg", "recvmsg", "sendto", "recvfrom", "shutdown",
+	"getsockopt", "setsockopt", "getsockname", "getpeername",
+
+	// memory
+	"brk", "mmap", "munmap", "mprotect", "mremap", "madvise", "membarrier", "memfd_create",
+
+	// processes and threads; clone is only allowed without CLONE_NEW* flags
+	// and clone3, whose flags live in memory a filter cannot read, fails
+	// with ENOSYS so libc falls back to clone
+	"clone", "clone3", "fork", "vfork", "execve", "execveat", "wait4", "waitid",
+	"exit", "exit_group", "kill", "tkill", "tgkill",
+	"futex", "set_robust_list", "get_robust_list", "set_tid_address", "rseq",
+	"sched_yield", "sched_getaffinity", "sched_getparam", "sched_getscheduler",
+	"sched_get_priority_max", "sched_get_priority_min",
+	"getpid", "getppid", "gettid", "getpgid", "getpgrp", "getsid", "setpgid", "setsid",
+	"getuid", "geteuid", "getgid", "getegid", "getgroups", "getresuid", "getresgid",
+	"getrlimit", "prlimit64", "getrusage", "getpriority", "arch_prctl", "prctl", "capget",
+
+	// signals and time
+	"rt_sigaction", "rt_sigprocmask", "rt_sigreturn", "rt_sigsuspend", "rt_sigtimedwait",
+	"sigaltstack", "pause", "alarm", "setitimer", "getitimer", "restart_syscall",
+	"clock_gettime", "clock_getres", "clock_nanosleep", "gettimeofday", "nanosleep", "time", "times",
+
+	// system information
+	"uname", "sysinfo", "getrandom", "getcpu",
+}
+
+// jvmExtraSyscalls are used by HotSpot on top of the interpreter set for its
+// thread and memory management.
+var jvmExtraSyscalls = []string{
+	"sched_setaffinity", "mincore", "msync", "mlock", "munlock", "setrlimit",
+}
+
+// cloneNamespaceFlags are the CLONE_NEW* flags of clone(2); CLONE_NEWTIME
+// shares its bit with the exit signal there and only exists for clone3.
+const cloneNamespaceFlags = 0x00020000 | // CLONE_NEWNS
+	0x02000000 | // CLONE_NEWCGROUP
+	0x04000000 | // CLONE_NEWUTS
+	0x08000000 | // CLONE_NEWIPC
+	0x10000000 | // CLONE_NEWUSER
+	0x20000000 | // CLONE_NEWPID
+	0x40000000 // CLONE_NEWNET
+
+const (
+	errnoEPERM  = 1
+	errnoENOSYS = 38
+)
+
+// UnreportedSyscall stands in for the name of a blocked syscall when the
+// runner only learns that the profile killed the program.
+const UnreportedSyscall = "a syscall outside its allowlist"
+
+// KilledExitCode is the exit status a container runtime reports for a program
+// that DockerProfile killed: 128 plus SIGSYS on Linux.
+const KilledExitCode = 128 + 31
+
+var profiles = map[string][]string{
+	"interpreter": interpreterSyscalls,
+	// Compiled C, C++, Go and Rust programs need the same set as the
+	// interpreters themselves.
+	"native": interpreterSyscalls,
+	"jvm":    append(append([]string(nil), interpreterSyscalls...), jvmExtraSyscalls...),
+}
+
+// Resolve returns the allowed syscalls for a language. An inline list wins
+// over a named profile; an empty result means no filter is installed.
+func Resolve(profile string, inline []string) ([]string, error) {
+	if len(inline) > 0 {
+		return append([]string(nil), inline...), nil
+	}
+
+	if profile == "" {
+		return nil, nil
+	}
+
+	syscalls, ok := profiles[profile]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownProfile, profile)
+	}
+
+	return append([]string(nil), syscalls...), nil
+}
+
+func ProfileNames() []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+type dockerProfile struct {
+	DefaultAction string          `json:"defaultAction"`
+	Syscalls      []dockerSyscall `json:"syscalls"`
+}
+
+type dockerSyscall struct {
+	Names    []string    `json:"names"`
+	Action   string      `json:"action"`
+	ErrnoRet int         `json:"errnoRet,omitempty"`
+	Args     []dockerArg `json:"args,omitempty"`
+}
+
+type dockerArg struct {
+	Index    uint   `json:"index"`
+	Value    uint64 `json:"value"`
+	ValueTwo uint64 `json:"valueTwo"`
+	Op       string `json:"op"`
+}
+
+// DockerProfile renders an allowlist as a profile for `docker run
+// --security-opt seccomp=`, with the same clone restrictions as Install. A
+// blocked syscall kills the program like Install does, but the runtime does
+// not say which one it was: the program exits with KilledExitCode.
+func DockerProfile(allowed []string) ([]byte, error) {
+	profile := dockerProfile{DefaultAction: "SCMP_ACT_KILL_PROCESS"}
+
+	var names []string
+	for _, name := range allowed {
+		switch name {
+		case "clone":
+			profile.Syscalls = append(profile.Syscalls, dockerSyscall{
+				Names:  []string{name},
+				Action: "SCMP_ACT_ALLOW",
+				Args:   []dockerArg{{Index: 0, Value: cloneNamespaceFlags, ValueTwo: 0, Op: "SCMP_CMP_MASKED_EQ"}},
+			})
+		case "clone3":
+			profile.Syscalls = append(profile.Syscalls, dockerSyscall{
+				Names:    []string{name},
+				Action:   "SCMP_ACT_ERRNO",
+				ErrnoRet: errnoENOSYS,
+			})
+		default:
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		profile.Syscalls = append(profile.Syscalls, dockerSyscall{Names: names, Action: "SCMP_ACT_ALLOW"})
+	}
+
+	data, err := json.Marshal(profile)
+	if err != nil {
+		return nil, fmt.Errorf("encode docker seccomp profile: %w", err)
+	}
+
+	return data, nil
+}
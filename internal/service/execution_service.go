@@ -5,35 +5,130 @@ import (
 	"Code_executor/internal/queue"
 	"Code_executor/internal/repository"
 	"context"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 )
 
 var (
 	ErrInvalidServiceInput = errors.New("invalid execution service input")
+	ErrNoDeadLetters       = errors.New("dead letters are not configured")
+	ErrPriorityNotAllowed  = errors.New("priority not allowed")
+	ErrNoWorkerRegistry    = errors.New("worker registry is not configured")
+	ErrNoDelayedQueue      = errors.New("scheduled executions are not configured")
+)
+
+// defaultWorkerTimeout is how long after its last heartbeat a worker still
+// counts as alive.
+const defaultWorkerTimeout = 30 * time.Second
+
+const (
+	// defaultListLimit caps the executions ListExecutions returns when the
+	// caller sets no limit, and maxListLimit the limit callers may set.
+	defaultListLimit = 50
+	maxListLimit     = 200
 )
 
 type ExecutionService interface {
 	CreateExecutionAndEnqueue(ctx context.Context, params CreateExecutionParams) (*domain.Execution, error)
 	GetExecution(ctx context.Context, id string) (*domain.Execution, error)
+	// ListExecutions returns a page of the executions matching params,
+	// newest first.
+	ListExecutions(ctx context.Context, params ListExecutionsParams) (*ExecutionPage, error)
 	MarkExecutionCompleted(ctx context.Context, id string, result CompleteExecutionResult) (*domain.Execution, error)
 	MarkExecutionFailed(ctx context.Context, id string, result FailExecutionResult) (*domain.Execution, error)
 	MarkExecutionTimedOut(ctx context.Context, id string, finishedAt time.Time) (*domain.Execution, error)
+	CancelExecution(ctx context.Context, id string) (*domain.Execution, error)
+	ListDeadLetters(ctx context.Context) ([]queue.DeadLetter, error)
+	RedriveExecution(ctx context.Context, id string) (*domain.Execution, error)
+	ListLanguages(ctx context.Context) ([]LanguageStatus, error)
+}
+
+// ListExecutionsParams select executions; empty fields match all of them.
+type ListExecutionsParams struct {
+	UserID string
+	Status domain.ExecutionStatus
+	// Limit defaults to defaultListLimit when 0 and may be up to
+	// maxListLimit.
+	Limit int
+	// Cursor is the NextCursor of the previous page; empty for the first.
+	Cursor string
+}
+
+// ExecutionPage is a page of executions. NextCursor continues the listing
+// after it and is empty on the last page.
+type ExecutionPage struct {
+	Executions []*domain.Execution
+	NextCursor string
+}
+
+// LanguageStatus tells whether the jobs of a language will be picked up:
+// Workers counts the alive workers that take it.
+type LanguageStatus struct {
+	Language string
+	Workers  int
+	// LastHeartbeat is the latest heartbeat of any worker taking the
+	// language, alive or not; zero if none was seen.
+	LastHeartbeat time.Time
+}
+
+// Transactor runs fn in a transaction that the repository and queue calls
+// made with fn's context take part in, if they support it.
+type Transactor interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type noTransactor struct{}
+
+// PriorityCaps bound the priority each user may request. Users are the
+// UserID of requests, which is not authenticated, so the caps only hold for
+// clients that do not claim another user's name.
+type PriorityCaps struct {
+	// Default caps users that are not in Users.
+	Default int
+	Users   map[string]int
 }
 
 type executionService struct {
 	repo        repository.ExecutionRepository
 	producer    queue.Producer
+	cancels     queue.CancelNotifier
+	deadLetters queue.DeadLetters
+	delayed     queue.DelayedQueue
+	transactor  Transactor
+	caps        *PriorityCaps
+	workers     queue.WorkerRegistry
+	workerTTL   time.Duration
 	idGenerator func() (string, error)
 	now         func() time.Time
 }
 
 type ExecutionServiceDeps struct {
-	Repo        repository.ExecutionRepository
-	Producer    queue.Producer
-	IDGenerator func() (string, error)
-	Now         func() time.Time
+	Repo     repository.ExecutionRepository
+	Producer queue.Producer
+	// Cancels is optional; without it cancelled executions that already
+	// run are not stopped, only recorded as cancelled.
+	Cancels queue.CancelNotifier
+	// DeadLetters is optional; without it the dead letter methods fail.
+	DeadLetters queue.DeadLetters
+	// Delayed is optional; without it executions cannot be scheduled.
+	Delayed queue.DelayedQueue
+	// Transactor is optional; without it an execution can be created without
+	// its job being queued if Enqueue fails.
+	Transactor Transactor
+	// PriorityCaps is optional; without it every user may request any
+	// priority.
+	PriorityCaps *PriorityCaps
+	// Workers is optional; without it ListLanguages fails. A worker counts
+	// as alive for WorkerTimeout after its last heartbeat.
+	Workers       queue.WorkerRegistry
+	WorkerTimeout time.Duration
+	IDGenerator   func() (string, error)
+	Now           func() time.Time
 }
 
 func NewExecutionService(deps ExecutionServiceDeps) (ExecutionService, error) {
@@ -46,20 +141,51 @@ func NewExecutionService(deps ExecutionServiceDeps) (ExecutionService, error) {
 		nowFn = time.Now
 	}
 
+	var transactor Transactor = noTransactor{}
+	if deps.Transactor != nil {
+		transactor = deps.Transactor
+	}
+
+	workerTTL := deps.WorkerTimeout
+	if workerTTL <= 0 {
+		workerTTL = defaultWorkerTimeout
+	}
+
 	return &executionService{
 		repo:        deps.Repo,
 		producer:    deps.Producer,
+		cancels:     deps.Cancels,
+		deadLetters: deps.DeadLetters,
+		delayed:     deps.Delayed,
+		transactor:  transactor,
+		caps:        deps.PriorityCaps,
+		workers:     deps.Workers,
+		workerTTL:   workerTTL,
 		idGenerator: deps.IDGenerator,
 		now:         nowFn,
 	}, nil
 }
 
 type CreateExecutionParams struct {
-	Language  string
-	Code      string
-	Stdin     string
-	TimeoutMs int
-	UserID    string
+	Language string
+	// Code is a single-file submission stored under the language's
+	// SourceFile; otherwise Files and Entrypoint are used.
+	Code       string
+	Files      map[string]string
+	Entrypoint string
+	Stdin      string
+	TimeoutMs  int
+	UserID     string
+	Priority   int
+	// RunAt or Delay, which are mutually exclusive, schedule the execution;
+	// one that is already due is queued right away.
+	RunAt time.Time
+	Delay time.Duration
+	// ScheduleID is set when a schedule creates the execution.
+	ScheduleID string
+	Limits     domain.ResourceLimits
+	TestCases  []domain.TestCase
+	Checker    domain.Checker
 }
 
 type CompleteExecutionResult struct {
@@ -80,27 +206,85 @@ func (s *executionService) CreateExecutionAndEnqueue(ctx context.Context, params
 		return nil, fmt.Errorf("%w: user id is required", ErrInvalidServiceInput)
 	}
 
+	files := params.Files
+	if params.Code != "" {
+		if len(files) > 0 {
+			return nil, fmt.Errorf("%w: code and files are mutually exclusive", ErrInvalidServiceInput)
+		}
+
+		lang, ok := domain.GetLanguage(params.Language)
+		if !ok {
+			return nil, fmt.Errorf("%w: language \"%s\" is not supported", domain.ErrInvalidExecution, params.Language)
+		}
+		files = map[string]string{lang.SourceFile: params.Code}
+	}
+
 	execID, err := s.idGenerator()
 	if err != nil {
 		return nil, fmt.Errorf("generate execution id: %w", err)
 	}
 
-	exec, err := domain.NewExecution(execID, params.Language, params.Code, params.Stdin, params.TimeoutMs, params.UserID, s.now())
+	exec, err := domain.NewExecution(execID, params.Language, files, params.Entrypoint, params.Stdin, params.TimeoutMs, params.UserID, s.now())
 	if err != nil {
 		return nil, err
 	}
 
-	if err := s.repo.CreateExecution(ctx, exec); err != nil {
+	if err := exec.RequestLimits(params.Limits); err != nil {
+		return nil, err
+	}
+
+	if err := exec.SetTestCases(params.TestCases); err != nil {
+		return nil, err
+	}
+
+	if err := exec.SetChecker(params.Checker); err != nil {
+		return nil, err
+	}
+
+	if err := exec.SetPriority(params.Priority); err != nil {
+		return nil, err
+	}
+
+	exec.ScheduleID = params.ScheduleID
+
+	if maxPriority := s.maxPriority(exec.UserID); exec.Priority > maxPriority {
+		return nil, fmt.Errorf("%w: user %s may not request a priority above %d", ErrPriorityNotAllowed, exec.UserID, maxPriority)
+	}
+
+	runAt, err := s.runAt(params)
+	if err != nil {
 		return nil, err
 	}
 
-	job := &queue.Job{
-		ExecutionID: exec.ID,
-		Language:    exec.Language,
-		UserID:      exec.UserID,
+	if runAt.After(exec.CreatedAt) {
+		if s.delayed == nil {
+			return nil, ErrNoDelayedQueue
+		}
+
+		if err := exec.Schedule(runAt); err != nil {
+			return nil, err
+		}
 	}
 
-	if err := s.producer.Enqueue(ctx, *job); err != nil {
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if err := s.repo.CreateExecution(ctx, exec); err != nil {
+			return err
+		}
+
+		job := &queue.Job{
+			ExecutionID: exec.ID,
+			Language:    exec.Language,
+			UserID:      exec.UserID,
+			Priority:    exec.Priority,
+		}
+
+		if exec.Status == domain.ExecutionStatusScheduled {
+			return s.delayed.Schedule(ctx, *job, *exec.RunAt)
+		}
+
+		return s.producer.Enqueue(ctx, *job)
+	})
+	if err != nil {
 		return nil, err
 	}
 
@@ -115,6 +299,77 @@ func (s *executionService) GetExecution(ctx context.Context, id string) (*domain
 	return s.repo.GetExecutionByID(ctx, id)
 }
 
+func (s *executionService) ListExecutions(ctx context.Context, params ListExecutionsParams) (*ExecutionPage, error) {
+	if params.Limit < 0 || params.Limit > maxListLimit {
+		return nil, fmt.Errorf("%w: limit must be between 0 and %d", ErrInvalidServiceInput, maxListLimit)
+	}
+
+	if params.Status != "" && !params.Status.IsKnown() {
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidServiceInput, params.Status)
+	}
+
+	var after repository.ExecutionCursor
+	if params.Cursor != "" {
+		var err error
+		after, err = decodeCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	limit := params.Limit
+	if limit == 0 {
+		limit = defaultListLimit
+	}
+
+	// One more than the page tells whether there is a next one.
+	execs, err := s.repo.ListExecutions(ctx, repository.ExecutionFilter{
+		UserID: params.UserID,
+		Status: params.Status,
+		Limit:  limit + 1,
+		After:  after,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ExecutionPage{Executions: execs}
+	if len(execs) > limit {
+		page.Executions = execs[:limit]
+		last := execs[limit-1]
+		page.NextCursor = encodeCursor(repository.ExecutionCursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	return page, nil
+}
+
+// encodeCursor makes an opaque page cursor of the position of an execution.
+func encodeCursor(cursor repository.ExecutionCursor) string {
+	key := strconv.FormatInt(cursor.CreatedAt.UnixNano(), 10) + ":" + cursor.ID
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeCursor(value string) (repository.ExecutionCursor, error) {
+	invalid := fmt.Errorf("%w: invalid cursor", ErrInvalidServiceInput)
+
+	key, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return repository.ExecutionCursor{}, invalid
+	}
+
+	nanos, id, ok := strings.Cut(string(key), ":")
+	if !ok || id == "" {
+		return repository.ExecutionCursor{}, invalid
+	}
+
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return repository.ExecutionCursor{}, invalid
+	}
+
+	return repository.ExecutionCursor{CreatedAt: time.Unix(0, n).UTC(), ID: id}, nil
+}
+
 func (s *executionService) MarkExecutionCompleted(ctx context.Context, id string, result CompleteExecutionResult) (*domain.Execution, error) {
 	if result.FinishedAt.IsZero() {
 		return nil, fmt.Errorf("%w: finished at is required", ErrInvalidServiceInput)
@@ -177,3 +432,150 @@ func (s *executionService) MarkExecutionTimedOut(ctx context.Context, id string,
 
 	return exec, nil
 }
+
+// CancelExecution cancels a scheduled, queued or running execution. Queued
+// ones are skipped by the worker that pops them, and scheduled ones dropped
+// by the promoter; running ones are killed by the worker holding them, which
+// is notified whatever status the execution was read in.
+func (s *executionService) CancelExecution(ctx context.Context, id string) (*domain.Execution, error) {
+	if id == "" {
+		return nil, fmt.Errorf("%w: execution id is required", ErrInvalidServiceInput)
+	}
+
+	exec, err := s.repo.GetExecutionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := exec.MarkCancelled(s.now()); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateExecution(ctx, exec); err != nil {
+		return nil, err
+	}
+
+	// The status read above may be stale: a worker can have picked up a
+	// queued execution since, so every cancellation is announced.
+	if s.cancels != nil {
+		if err := s.cancels.NotifyCancel(ctx, exec.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return exec, nil
+}
+
+func (s *executionService) ListDeadLetters(ctx context.Context) ([]queue.DeadLetter, error) {
+	if s.deadLetters == nil {
+		return nil, ErrNoDeadLetters
+	}
+
+	return s.deadLetters.ListDeadLetters(ctx)
+}
+
+// RedriveExecution queues a dead-lettered execution again. The execution is
+// reopened before its job is queued, so a worker never sees the job of a
+// failed execution; an execution already reopened by an interrupted redrive
+// is only queued.
+func (s *executionService) RedriveExecution(ctx context.Context, id string) (*domain.Execution, error) {
+	if id == "" {
+		return nil, fmt.Errorf("%w: execution id is required", ErrInvalidServiceInput)
+	}
+
+	if s.deadLetters == nil {
+		return nil, ErrNoDeadLetters
+	}
+
+	exec, err := s.repo.GetExecutionByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.transactor.WithinTx(ctx, func(ctx context.Context) error {
+		if exec.Status != domain.ExecutionStatusQueued {
+			if err := exec.Redrive(); err != nil {
+				return err
+			}
+
+			if err := s.repo.ReopenExecution(ctx, exec); err != nil {
+				return err
+			}
+		}
+
+		return s.deadLetters.Redrive(ctx, exec.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return exec, nil
+}
+
+// ListLanguages reports, for every supported language, how many workers that
+// take it are alive.
+func (s *executionService) ListLanguages(ctx context.Context) ([]LanguageStatus, error) {
+	if s.workers == nil {
+		return nil, ErrNoWorkerRegistry
+	}
+
+	workers, err := s.workers.ListWorkers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	names := domain.LanguageNames()
+	statuses := make([]LanguageStatus, 0, len(names))
+	for _, name := range names {
+		status := LanguageStatus{Language: name}
+		for _, worker := range workers {
+			if len(worker.Languages) > 0 && !slices.Contains(worker.Languages, name) {
+				continue
+			}
+
+			if worker.SeenAt.After(status.LastHeartbeat) {
+				status.LastHeartbeat = worker.SeenAt
+			}
+			if now.Sub(worker.SeenAt) <= s.workerTTL {
+				status.Workers++
+			}
+		}
+
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// runAt is when the execution of params is due.
+func (s *executionService) runAt(params CreateExecutionParams) (time.Time, error) {
+	if params.Delay < 0 {
+		return time.Time{}, fmt.Errorf("%w: delay must not be negative", ErrInvalidServiceInput)
+	}
+
+	if params.Delay > 0 {
+		if !params.RunAt.IsZero() {
+			return time.Time{}, fmt.Errorf("%w: run at and delay are mutually exclusive", ErrInvalidServiceInput)
+		}
+		return s.now().Add(params.Delay), nil
+	}
+
+	return params.RunAt, nil
+}
+
+func (s *executionService) maxPriority(userID string) int {
+	if s.caps == nil {
+		return domain.MaxPriority
+	}
+
+	if limit, ok := s.caps.Users[userID]; ok {
+		return limit
+	}
+
+	return s.caps.Default
+}
+
+func (noTransactor) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return fn(ctx)
+}
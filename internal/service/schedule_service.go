@@ -0,0 +1,139 @@
+package service
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/repository"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrInvalidScheduleInput = errors.New("invalid schedule service input")
+)
+
+// defaultHistoryLimit caps the executions ListScheduleExecutions returns
+// when no limit is given.
+const defaultHistoryLimit = 50
+
+type ScheduleService interface {
+	CreateSchedule(ctx context.Context, userID string, def domain.ScheduleDefinition) (*domain.Schedule, error)
+	GetSchedule(ctx context.Context, id string) (*domain.Schedule, error)
+	ListSchedules(ctx context.Context, userID string) ([]*domain.Schedule, error)
+	UpdateSchedule(ctx context.Context, id string, def domain.ScheduleDefinition) (*domain.Schedule, error)
+	DeleteSchedule(ctx context.Context, id string) error
+	// ListScheduleExecutions returns the latest executions a schedule
+	// created, newest first.
+	ListScheduleExecutions(ctx context.Context, id string, limit int) ([]*domain.Execution, error)
+}
+
+type scheduleService struct {
+	schedules   repository.ScheduleRepository
+	executions  repository.ExecutionRepository
+	idGenerator func() (string, error)
+	now         func() time.Time
+}
+
+type ScheduleServiceDeps struct {
+	Schedules   repository.ScheduleRepository
+	Executions  repository.ExecutionRepository
+	IDGenerator func() (string, error)
+	Now         func() time.Time
+}
+
+func NewScheduleService(deps ScheduleServiceDeps) (ScheduleService, error) {
+	if deps.Schedules == nil || deps.Executions == nil || deps.IDGenerator == nil {
+		return nil, fmt.Errorf("%w: missing dependencies", ErrInvalidScheduleInput)
+	}
+
+	nowFn := deps.Now
+	if nowFn == nil {
+		nowFn = time.Now
+	}
+
+	return &scheduleService{
+		schedules:   deps.Schedules,
+		executions:  deps.Executions,
+		idGenerator: deps.IDGenerator,
+		now:         nowFn,
+	}, nil
+}
+
+func (s *scheduleService) CreateSchedule(ctx context.Context, userID string, def domain.ScheduleDefinition) (*domain.Schedule, error) {
+	if userID == "" {
+		return nil, fmt.Errorf("%w: user id is required", ErrInvalidScheduleInput)
+	}
+
+	id, err := s.idGenerator()
+	if err != nil {
+		return nil, fmt.Errorf("generate schedule id: %w", err)
+	}
+
+	schedule, err := domain.NewSchedule(id, userID, def, s.now())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.schedules.CreateSchedule(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (s *scheduleService) GetSchedule(ctx context.Context, id string) (*domain.Schedule, error) {
+	if id == "" {
+		return nil, fmt.Errorf("%w: schedule id is required", ErrInvalidScheduleInput)
+	}
+
+	return s.schedules.GetScheduleByID(ctx, id)
+}
+
+func (s *scheduleService) ListSchedules(ctx context.Context, userID string) ([]*domain.Schedule, error) {
+	return s.schedules.ListSchedules(ctx, userID)
+}
+
+// UpdateSchedule redefines a schedule. Its next run is recomputed from now,
+// so runs missed before the update are dropped.
+func (s *scheduleService) UpdateSchedule(ctx context.Context, id string, def domain.ScheduleDefinition) (*domain.Schedule, error) {
+	schedule, err := s.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := schedule.Define(def, s.now()); err != nil {
+		return nil, err
+	}
+
+	if err := s.schedules.UpdateSchedule(ctx, schedule); err != nil {
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+// DeleteSchedule stops a schedule; the executions it created are kept.
+func (s *scheduleService) DeleteSchedule(ctx context.Context, id string) error {
+	if id == "" {
+		return fmt.Errorf("%w: schedule id is required", ErrInvalidScheduleInput)
+	}
+
+	return s.schedules.DeleteSchedule(ctx, id)
+}
+
+func (s *scheduleService) ListScheduleExecutions(ctx context.Context, id string, limit int) ([]*domain.Execution, error) {
+	if limit < 0 {
+		return nil, fmt.Errorf("%w: limit must not be negative", ErrInvalidScheduleInput)
+	}
+
+	if limit == 0 {
+		limit = defaultHistoryLimit
+	}
+
+	if _, err := s.GetSchedule(ctx, id); err != nil {
+		return nil, err
+	}
+
+	return s.executions.ListExecutions(ctx, repository.ExecutionFilter{ScheduleID: id, Limit: limit})
+}
@@ -0,0 +1,125 @@
+package worker
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/runner"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// execute compiles, when the language needs it, and runs the execution,
+// saving the intermediate statuses, and moves it into a final status which
+// the caller saves. An error is returned when the execution could not be run
+// or updated for infrastructure reasons, or the worker is shutting down.
+func (w *Worker) execute(ctx context.Context, exec *domain.Execution) error {
+	lang, ok := domain.GetLanguage(exec.Language)
+	if !ok {
+		if err := w.saveStatus(ctx, exec, exec.MarkRunning); err != nil {
+			return err
+		}
+		return exec.MarkFailed(fmt.Sprintf("language %q is not supported", exec.Language), nil, time.Now())
+	}
+
+	session, err := w.runner.Prepare(ctx, runner.Request{Execution: exec, Language: lang})
+	if err != nil {
+		if err := w.saveStatus(ctx, exec, exec.MarkRunning); err != nil {
+			return err
+		}
+		return failRun(ctx, exec, err)
+	}
+	defer session.Close()
+
+	if lang.Compiled() {
+		if err := w.saveStatus(ctx, exec, exec.MarkCompiling); err != nil {
+			return err
+		}
+
+		result, err := session.Compile(ctx)
+		if err != nil {
+			return failRun(ctx, exec, err)
+		}
+
+		exec.RecordCompilation(result.Stdout, result.Stderr, result.ExitCode)
+		if result.Failed() {
+			return exec.MarkCompilationError(result.FinishedAt)
+		}
+	}
+
+	if err := w.saveStatus(ctx, exec, exec.MarkRunning); err != nil {
+		return err
+	}
+
+	if exec.IsJudged() {
+		return judge(ctx, w.runner, session, exec)
+	}
+
+	result, err := session.Run(ctx, runner.RunInput{Stdin: exec.Stdin})
+	if err != nil {
+		return failRun(ctx, exec, err)
+	}
+
+	return finishRun(exec, result)
+}
+
+// failRun handles a phase that could not be run. A cancelled execution is
+// marked so and an invalid one failed; otherwise the execution is left as is
+// and an error returned, errShutdown when the worker is shutting down, so the
+// job can be run again.
+func failRun(ctx context.Context, exec *domain.Execution, err error) error {
+	switch cause := context.Cause(ctx); {
+	case errors.Is(cause, errCancelled):
+		return exec.MarkCancelled(time.Now())
+	case errors.Is(cause, errShutdown):
+		return errShutdown
+	}
+
+	if errors.Is(err, runner.ErrInvalidRunRequest) {
+		return exec.MarkFailed(err.Error(), nil, time.Now())
+	}
+
+	return err
+}
+
+// finishRun moves a running execution into its final status.
+func finishRun(exec *domain.Execution, result *runner.Result) error {
+	exec.RecordViolations(result.Violations...)
+	exec.RecordUsage(result.Usage)
+	exec.RecordTruncation(result.StdoutTruncated, result.StderrTruncated)
+
+	if result.TimedOut {
+		return exec.MarkTimedOut(result.FinishedAt)
+	}
+
+	if result.OutputLimitExceeded() {
+		return exec.MarkOutputLimitExceeded(result.Stdout, result.Stderr, result.FinishedAt)
+	}
+
+	if result.BlockedSyscall != "" {
+		exitCode := result.ExitCode
+		detail := fmt.Sprintf("your program tried to call %s", result.BlockedSyscall)
+		return exec.MarkFailedWithReason(domain.FailureReasonSyscallNotAllowed, detail, result.Stderr, &exitCode, result.FinishedAt)
+	}
+
+	if exec.HasViolation(domain.ResourceViolationMemory) {
+		exitCode := result.ExitCode
+		return exec.MarkMemoryLimitExceeded(result.Stdout, result.Stderr, &exitCode, result.FinishedAt)
+	}
+
+	// Hitting the pids limit only shows as a failing fork; the program's
+	// exit status decides.
+	if result.ExitCode != 0 {
+		return exec.MarkRuntimeError(result.Stdout, result.Stderr, result.ExitCode, result.FinishedAt)
+	}
+
+	return exec.MarkCompleted(result.Stdout, result.Stderr, result.ExitCode, result.FinishedAt)
+}
+
+func (w *Worker) saveStatus(ctx context.Context, exec *domain.Execution, mark func(time.Time) error) error {
+	if err := mark(time.Now()); err != nil {
+		return err
+	}
+
+	return w.save(ctx, exec)
+}
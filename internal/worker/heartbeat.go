@@ -0,0 +1,75 @@
+package worker
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/queue"
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+// missedHeartbeats is how many heartbeats a worker may miss before it is
+// considered gone.
+const missedHeartbeats = 3
+
+// heartbeat tells the registry the worker is alive until ctx is done, and
+// then deregisters it.
+func (w *Worker) heartbeat(ctx context.Context) {
+	ticker := time.NewTicker(w.heartbeatInterval)
+	defer ticker.Stop()
+	defer w.deregister(ctx)
+
+	for {
+		beatCtx, cancel := context.WithTimeout(ctx, saveTimeout)
+		err := w.registry.Heartbeat(beatCtx, queue.WorkerInfo{ID: w.id, Languages: w.languages})
+		cancel()
+		if err != nil && ctx.Err() == nil {
+			log.Printf("worker heartbeat: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (w *Worker) deregister(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+	defer cancel()
+
+	if err := w.registry.Deregister(ctx, w.id); err != nil {
+		log.Printf("deregister worker %s: %v", w.id, err)
+	}
+}
+
+// ownerAlive reports whether the worker that started exec may still be
+// running it. Executions without a worker id predate them and are taken over.
+func (w *Worker) ownerAlive(ctx context.Context, exec *domain.Execution) (bool, error) {
+	switch {
+	case exec.WorkerID == "":
+		return false, nil
+	case exec.WorkerID == w.id:
+		return w.running.has(exec.ID), nil
+	case w.registry == nil:
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, saveTimeout)
+	defer cancel()
+
+	workers, err := w.registry.ListWorkers(ctx)
+	if err != nil {
+		return false, fmt.Errorf("list workers: %w", err)
+	}
+
+	for _, info := range workers {
+		if info.ID == exec.WorkerID {
+			return time.Since(info.SeenAt) <= missedHeartbeats*w.heartbeatInterval, nil
+		}
+	}
+
+	return false, nil
+}
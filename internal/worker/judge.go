@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"Code_executor/internal/checker"
+	"Code_executor/internal/domain"
+	"Code_executor/internal/runner"
+	"context"
+	"errors"
+	"time"
+)
+
+// judge runs every test case of a judged execution in its own sandboxed
+// process and completes the execution with the overall verdict.
+func judge(ctx context.Context, run runner.Runner, session runner.Session, exec *domain.Execution) error {
+	check, err := checker.New(ctx, run, exec)
+	if err != nil {
+		return failJudge(ctx, exec, err)
+	}
+	defer check.Close()
+
+	for i, tc := range exec.TestCases {
+		result, err := session.Run(ctx, runner.RunInput{Stdin: tc.Stdin, TimeoutMs: exec.CaseTimeoutMs(i)})
+		if err != nil {
+			return failRun(ctx, exec, err)
+		}
+
+		exec.RecordViolations(result.Violations...)
+		exec.RecordUsage(result.Usage)
+
+		verdict, err := caseVerdict(ctx, check, tc, result)
+		if err != nil {
+			return failJudge(ctx, exec, err)
+		}
+
+		exitCode := result.ExitCode
+		err = exec.RecordTestResult(domain.TestCaseResult{
+			Verdict:    verdict,
+			Stdout:     result.Stdout,
+			Stderr:     result.Stderr,
+			ExitCode:   &exitCode,
+			DurationMs: result.Duration().Milliseconds(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return exec.MarkJudged(time.Now())
+}
+
+func caseVerdict(ctx context.Context, check checker.Checker, tc domain.TestCase, result *runner.Result) (domain.Verdict, error) {
+	switch {
+	case result.TimedOut:
+		return domain.VerdictTimeLimitExceeded, nil
+	case result.Failed():
+		return domain.VerdictRuntimeError, nil
+	default:
+		return check.Check(ctx, tc.Stdin, tc.ExpectedStdout, result.Stdout)
+	}
+}
+
+func failJudge(ctx context.Context, exec *domain.Execution, err error) error {
+	if errors.Is(err, checker.ErrCheckerFailed) {
+		return exec.MarkFailedWithReason(domain.FailureReasonCheckerError, err.Error(), "", nil, time.Now())
+	}
+
+	return failRun(ctx, exec, err)
+}
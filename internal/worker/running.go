@@ -0,0 +1,63 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// runningJobs maps the executions this worker is running to the cancel
+// functions of their contexts.
+type runningJobs struct {
+	mu     sync.Mutex
+	cancel map[string]context.CancelCauseFunc
+}
+
+func newRunningJobs() *runningJobs {
+	return &runningJobs{cancel: make(map[string]context.CancelCauseFunc)}
+}
+
+// start returns the context to run an execution with and the function to
+// call once it is done.
+func (r *runningJobs) start(ctx context.Context, executionID string) (context.Context, func()) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+
+	r.mu.Lock()
+	r.cancel[executionID] = cancel
+	r.mu.Unlock()
+
+	return jobCtx, func() {
+		r.mu.Lock()
+		delete(r.cancel, executionID)
+		r.mu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancelAll cancels every execution id received that this worker is running;
+// others belong to other workers.
+func (r *runningJobs) cancelAll(ids <-chan string) {
+	for id := range ids {
+		r.mu.Lock()
+		cancel, ok := r.cancel[id]
+		r.mu.Unlock()
+
+		if ok {
+			cancel(errCancelled)
+		}
+	}
+}
+
+func (r *runningJobs) has(executionID string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, ok := r.cancel[executionID]
+	return ok
+}
+
+func (r *runningJobs) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.cancel)
+}
@@ -0,0 +1,420 @@
+package worker
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/queue"
+	"Code_executor/internal/repository"
+	"Code_executor/internal/runner"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// saveTimeout bounds every repository write; writes do not depend on the job
+// context so results are stored even after the job was cancelled.
+const saveTimeout = 10 * time.Second
+
+const (
+	defaultRetryBaseDelay    = time.Second
+	defaultRetryMaxDelay     = time.Minute
+	defaultHeartbeatInterval = 10 * time.Second
+)
+
+var (
+	ErrInvalidWorker = errors.New("invalid worker")
+	ErrDrainTimeout  = errors.New("drain timeout exceeded")
+)
+
+var (
+	// errCancelled and errShutdown are the causes a job context is
+	// cancelled with: by the user or by the worker stopping.
+	errCancelled = errors.New("execution cancelled")
+	errShutdown  = errors.New("worker is shutting down")
+)
+
+type Worker struct {
+	repo         repository.ExecutionRepository
+	consumer     queue.Consumer
+	runner       runner.Runner
+	cancels      queue.CancelListener
+	concurrency  int
+	drainTimeout time.Duration
+	retryBase    time.Duration
+	retryMax     time.Duration
+	running      *runningJobs
+
+	registry          queue.WorkerRegistry
+	id                string
+	languages         []string
+	heartbeatInterval time.Duration
+}
+
+type Deps struct {
+	Repo     repository.ExecutionRepository
+	Consumer queue.Consumer
+	Runner   runner.Runner
+	// Cancels is optional; without it cancelled executions run to the end
+	// and their result is discarded.
+	Cancels      queue.CancelListener
+	Concurrency  int
+	DrainTimeout time.Duration
+	// Jobs failing for infrastructure reasons are retried after
+	// RetryBaseDelay, doubled on every further attempt up to RetryMaxDelay.
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	// Registry is optional; with it the worker sends a heartbeat every
+	// HeartbeatInterval, reporting ID and the Languages its consumer takes
+	// (empty for all), and deregisters when it stops. Without it a started
+	// execution of another worker is never taken over, as that worker cannot
+	// be proven gone.
+	Registry          queue.WorkerRegistry
+	ID                string
+	Languages         []string
+	HeartbeatInterval time.Duration
+}
+
+func New(deps Deps) (*Worker, error) {
+	if deps.Repo == nil || deps.Consumer == nil || deps.Runner == nil {
+		return nil, fmt.Errorf("%w: missing dependencies", ErrInvalidWorker)
+	}
+
+	if deps.Concurrency <= 0 {
+		return nil, fmt.Errorf("%w: concurrency must be positive", ErrInvalidWorker)
+	}
+
+	if deps.DrainTimeout < 0 {
+		return nil, fmt.Errorf("%w: drain timeout must not be negative", ErrInvalidWorker)
+	}
+
+	retryBase := deps.RetryBaseDelay
+	if retryBase <= 0 {
+		retryBase = defaultRetryBaseDelay
+	}
+
+	retryMax := deps.RetryMaxDelay
+	if retryMax <= 0 {
+		retryMax = defaultRetryMaxDelay
+	}
+
+	if deps.Registry != nil && deps.ID == "" {
+		return nil, fmt.Errorf("%w: a worker with a registry needs an id", ErrInvalidWorker)
+	}
+
+	heartbeatInterval := deps.HeartbeatInterval
+	if heartbeatInterval <= 0 {
+		heartbeatInterval = defaultHeartbeatInterval
+	}
+
+	return &Worker{
+		repo:              deps.Repo,
+		consumer:          deps.Consumer,
+		runner:            deps.Runner,
+		cancels:           deps.Cancels,
+		concurrency:       deps.Concurrency,
+		drainTimeout:      deps.DrainTimeout,
+		retryBase:         retryBase,
+		retryMax:          retryMax,
+		running:           newRunningJobs(),
+		registry:          deps.Registry,
+		id:                deps.ID,
+		languages:         deps.Languages,
+		heartbeatInterval: heartbeatInterval,
+	}, nil
+}
+
+// Run executes jobs with up to the configured number at a time until ctx is
+// done. It then stops consuming and waits for the running jobs for at most
+// the drain timeout; jobs still running after that are killed and put back
+// in the queue, and ErrDrainTimeout is returned.
+func (w *Worker) Run(ctx context.Context) error {
+	deliveries, err := w.consumer.Consume(ctx)
+	if err != nil {
+		return fmt.Errorf("consume jobs: %w", err)
+	}
+
+	// Running jobs outlive ctx until the drain deadline.
+	jobsCtx, stopJobs := context.WithCancelCause(context.WithoutCancel(ctx))
+	defer stopJobs(nil)
+
+	if w.registry != nil {
+		// The worker counts as alive until it stopped draining.
+		heartbeatCtx, stopHeartbeat := context.WithCancel(context.WithoutCancel(ctx))
+		stopped := make(chan struct{})
+		go func() {
+			defer close(stopped)
+			w.heartbeat(heartbeatCtx)
+		}()
+		defer func() {
+			stopHeartbeat()
+			<-stopped
+		}()
+	}
+
+	if w.cancels != nil {
+		cancelled, err := w.cancels.ListenCancels(jobsCtx)
+		if err != nil {
+			return fmt.Errorf("listen for cancellations: %w", err)
+		}
+		go w.running.cancelAll(cancelled)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < w.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range deliveries {
+				w.process(jobsCtx, d)
+			}
+		}()
+	}
+
+	drained := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+	}
+
+	log.Printf("worker stopping, waiting up to %s for %d running jobs", w.drainTimeout, w.running.count())
+
+	timer := time.NewTimer(w.drainTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-drained:
+		return nil
+	case <-timer.C:
+	}
+
+	stopJobs(errShutdown)
+	<-drained
+
+	return ErrDrainTimeout
+}
+
+// process runs one delivered job and acks it, or nacks it when the worker
+// stopped before the job finished. A job whose execution already started is
+// only run again when its worker is gone; otherwise it is a duplicate and
+// acked. Errors other than the program's own are infrastructure failures: the
+// job is retried, and dead-lettered once the language's attempts are used up.
+// They never stop the worker.
+func (w *Worker) process(ctx context.Context, d queue.Delivery) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("job %s panicked: %v", d.ExecutionID, r)
+			w.retryOrDeadLetter(ctx, d, fmt.Errorf("panic: %v", r))
+		}
+	}()
+
+	exec, err := w.getExecution(ctx, d.ExecutionID)
+	if errors.Is(err, repository.ErrExecutionNotFound) {
+		log.Printf("load execution %s: %v", d.ExecutionID, err)
+		w.ack(ctx, d)
+		return
+	}
+	if err != nil {
+		log.Printf("load execution %s: %v", d.ExecutionID, err)
+		w.retryOrDeadLetter(ctx, d, err)
+		return
+	}
+
+	if exec.IsFinal() {
+		fmt.Printf("⏭️ Skipping job %s with status %s\n", d.ExecutionID, exec.Status)
+		w.ack(ctx, d)
+		return
+	}
+
+	if exec.Status != domain.ExecutionStatusQueued {
+		alive, err := w.ownerAlive(ctx, exec)
+		if err != nil {
+			// Not retryOrDeadLetter: that would requeue the execution.
+			log.Printf("job %s: %v", d.ExecutionID, err)
+			w.retry(ctx, d, w.retryBase)
+			return
+		}
+		if alive {
+			log.Printf("skipping duplicate job %s: execution is %s on worker %s", d.ExecutionID, exec.Status, exec.WorkerID)
+			w.ack(ctx, d)
+			return
+		}
+
+		// A redelivered job whose previous worker died mid-run.
+		log.Printf("restarting job %s left %s", d.ExecutionID, exec.Status)
+		if err := w.requeue(ctx, exec); err != nil {
+			log.Printf("job %s: %v", d.ExecutionID, err)
+			w.retryOrDeadLetter(ctx, d, err)
+			return
+		}
+	}
+
+	fmt.Printf("⚙️ Processing job %s\n", d.ExecutionID)
+
+	exec.WorkerID = w.id
+	jobCtx, done := w.running.start(ctx, d.ExecutionID)
+	err = w.execute(jobCtx, exec)
+	if err == nil {
+		err = w.save(jobCtx, exec)
+	}
+	cause := context.Cause(jobCtx)
+	done()
+
+	switch {
+	case errors.Is(err, repository.ErrExecutionFinished) || errors.Is(cause, errCancelled):
+		fmt.Printf("🛑 Job %s was cancelled\n", d.ExecutionID)
+	case errors.Is(err, errShutdown):
+		fmt.Printf("🛑 Job %s was interrupted by shutdown, requeueing\n", d.ExecutionID)
+		if err := w.requeue(ctx, exec); err != nil {
+			log.Printf("job %s: %v", d.ExecutionID, err)
+		}
+		w.nack(ctx, d)
+		return
+	case err != nil:
+		log.Printf("job %s: %v", d.ExecutionID, err)
+		w.retryOrDeadLetter(ctx, d, err)
+		return
+	default:
+		fmt.Printf("✅ Finished job %s with status %s\n", d.ExecutionID, exec.Status)
+	}
+
+	w.ack(ctx, d)
+}
+
+func (w *Worker) requeue(ctx context.Context, exec *domain.Execution) error {
+	if err := exec.Requeue(); err != nil {
+		return err
+	}
+
+	return w.save(ctx, exec)
+}
+
+func (w *Worker) ack(ctx context.Context, d queue.Delivery) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+	defer cancel()
+
+	if err := w.consumer.Ack(ctx, d); err != nil {
+		log.Printf("ack job %s: %v", d.ExecutionID, err)
+	}
+}
+
+func (w *Worker) nack(ctx context.Context, d queue.Delivery) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+	defer cancel()
+
+	if err := w.consumer.Nack(ctx, d); err != nil {
+		log.Printf("nack job %s: %v", d.ExecutionID, err)
+	}
+}
+
+// retryOrDeadLetter settles a job that failed for infrastructure reasons. It
+// starts from the stored execution, as the job's copy may be in any state.
+// Attempts that failed before the execution was saved only show on the job.
+func (w *Worker) retryOrDeadLetter(ctx context.Context, d queue.Delivery, cause error) {
+	attempts := d.Attempts + 1
+	maxAttempts := 1
+	if lang, ok := domain.GetLanguage(d.Language); ok {
+		maxAttempts = lang.MaxAttempts
+	}
+
+	exec, err := w.getExecution(ctx, d.ExecutionID)
+	if err != nil {
+		log.Printf("load execution %s: %v", d.ExecutionID, err)
+	}
+
+	if exec != nil {
+		if exec.IsFinal() {
+			w.ack(ctx, d)
+			return
+		}
+		if lang, ok := domain.GetLanguage(exec.Language); ok {
+			maxAttempts = lang.MaxAttempts
+		}
+		attempts = max(attempts, exec.Attempts)
+	}
+
+	if attempts < maxAttempts {
+		if exec != nil && exec.Status != domain.ExecutionStatusQueued {
+			if err := w.requeue(ctx, exec); err != nil {
+				log.Printf("requeue execution %s: %v", d.ExecutionID, err)
+			}
+		}
+
+		delay := w.retryDelay(attempts)
+		log.Printf("retrying job %s in %s after attempt %d of %d failed", d.ExecutionID, delay, attempts, maxAttempts)
+		w.retry(ctx, d, delay)
+		return
+	}
+
+	log.Printf("dead-lettering job %s after %d attempts", d.ExecutionID, attempts)
+	if exec != nil {
+		if err := w.failInfrastructure(ctx, exec, cause); err != nil {
+			log.Printf("fail execution %s: %v", d.ExecutionID, err)
+		}
+	}
+	w.deadLetter(ctx, d, cause.Error())
+}
+
+func (w *Worker) failInfrastructure(ctx context.Context, exec *domain.Execution, cause error) error {
+	now := time.Now()
+	if exec.Status == domain.ExecutionStatusQueued {
+		if err := exec.MarkRunning(now); err != nil {
+			return err
+		}
+	}
+
+	if err := exec.MarkFailedWithReason(domain.FailureReasonInfrastructure, cause.Error(), "", nil, now); err != nil {
+		return err
+	}
+
+	return w.save(ctx, exec)
+}
+
+// retryDelay is the backoff before the attempt after the given one.
+func (w *Worker) retryDelay(attempt int) time.Duration {
+	delay := w.retryBase
+	for i := 1; i < attempt && delay < w.retryMax; i++ {
+		delay *= 2
+	}
+
+	return min(delay, w.retryMax)
+}
+
+func (w *Worker) retry(ctx context.Context, d queue.Delivery, delay time.Duration) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+	defer cancel()
+
+	if err := w.consumer.Retry(ctx, d, delay); err != nil {
+		log.Printf("retry job %s: %v", d.ExecutionID, err)
+	}
+}
+
+func (w *Worker) deadLetter(ctx context.Context, d queue.Delivery, reason string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+	defer cancel()
+
+	if err := w.consumer.DeadLetter(ctx, d, reason); err != nil {
+		log.Printf("dead-letter job %s: %v", d.ExecutionID, err)
+	}
+}
+
+func (w *Worker) getExecution(ctx context.Context, id string) (*domain.Execution, error) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+	defer cancel()
+
+	return w.repo.GetExecutionByID(ctx, id)
+}
+
+func (w *Worker) save(ctx context.Context, exec *domain.Execution) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), saveTimeout)
+	defer cancel()
+
+	return w.repo.UpdateExecution(ctx, exec)
+}
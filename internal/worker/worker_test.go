@@ -0,0 +1,395 @@
+package worker
+
+import (
+	"Code_executor/internal/domain"
+	"Code_executor/internal/queue"
+	"Code_executor/internal/repository/memory"
+	"Code_executor/internal/runner"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProcessCompletes(t *testing.T) {
+	w, repo, consumer := newTestWorker(t, &fakeRunner{}, nil)
+	exec := createQueued(t, repo, "exec-1")
+
+	w.process(context.Background(), delivery(exec, 0))
+
+	consumer.assertCalls(t, "ack exec-1")
+	got := stored(t, repo, exec.ID)
+	if got.Status != domain.ExecutionStatusCompleted || got.Stdout != "out" {
+		t.Errorf("execution is %s with stdout %q, want completed with %q", got.Status, got.Stdout, "out")
+	}
+	if got.WorkerID != "worker-1" {
+		t.Errorf("execution ran on %q, want worker-1", got.WorkerID)
+	}
+}
+
+func TestProcessSkips(t *testing.T) {
+	run := &fakeRunner{}
+	w, repo, consumer := newTestWorker(t, run, nil)
+
+	finished := createQueued(t, repo, "finished")
+	if err := finished.MarkCancelled(time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateExecution(context.Background(), finished); err != nil {
+		t.Fatal(err)
+	}
+
+	w.process(context.Background(), delivery(finished, 0))
+	w.process(context.Background(), queue.Delivery{Job: queue.Job{ExecutionID: "missing"}, Receipt: "missing"})
+
+	consumer.assertCalls(t, "ack finished", "ack missing")
+	if run.runs() != 0 {
+		t.Errorf("runner ran %d times, want 0", run.runs())
+	}
+}
+
+func TestProcessInfrastructureFailure(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		attempts   int
+		wantCall   string
+		wantStatus domain.ExecutionStatus
+	}{
+		{"retried", 0, "retry exec-1 1s", domain.ExecutionStatusQueued},
+		{"backed off", 1, "retry exec-1 2s", domain.ExecutionStatusQueued},
+		{"dead-lettered after the last attempt", 2, "dead-letter exec-1", domain.ExecutionStatusFailed},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			w, repo, consumer := newTestWorker(t, &fakeRunner{prepareErr: errors.New("no sandbox")}, nil)
+			exec := createQueued(t, repo, "exec-1")
+
+			w.process(context.Background(), delivery(exec, test.attempts))
+
+			consumer.assertCalls(t, test.wantCall)
+			if got := stored(t, repo, exec.ID); got.Status != test.wantStatus {
+				t.Errorf("execution is %s, want %s", got.Status, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestProcessStartedExecution(t *testing.T) {
+	now := time.Now()
+	for _, test := range []struct {
+		name        string
+		workerID    string
+		workers     []queue.WorkerInfo
+		registryErr error
+		// wantRun is whether the execution is taken over and run again.
+		wantRun  bool
+		wantCall string
+	}{
+		{
+			name:     "no owner",
+			wantRun:  true,
+			wantCall: "ack exec-1",
+		},
+		{
+			name:     "live owner",
+			workerID: "worker-2",
+			workers:  []queue.WorkerInfo{{ID: "worker-2", SeenAt: now}},
+			wantCall: "ack exec-1",
+		},
+		{
+			name:     "owner missed its heartbeats",
+			workerID: "worker-2",
+			workers:  []queue.WorkerInfo{{ID: "worker-2", SeenAt: now.Add(-time.Hour)}},
+			wantRun:  true,
+			wantCall: "ack exec-1",
+		},
+		{
+			name:     "owner deregistered",
+			workerID: "worker-2",
+			workers:  []queue.WorkerInfo{{ID: "worker-3", SeenAt: now}},
+			wantRun:  true,
+			wantCall: "ack exec-1",
+		},
+		{
+			name:     "this worker before it restarted",
+			workerID: "worker-1",
+			wantRun:  true,
+			wantCall: "ack exec-1",
+		},
+		{
+			name:        "registry unavailable",
+			workerID:    "worker-2",
+			registryErr: errors.New("connection refused"),
+			wantCall:    "retry exec-1 1s",
+		},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			run := &fakeRunner{}
+			registry := &fakeRegistry{workers: test.workers, err: test.registryErr}
+			w, repo, consumer := newTestWorker(t, run, registry)
+
+			exec := createQueued(t, repo, "exec-1")
+			if err := exec.MarkRunning(now); err != nil {
+				t.Fatal(err)
+			}
+			exec.WorkerID = test.workerID
+			if err := repo.UpdateExecution(context.Background(), exec); err != nil {
+				t.Fatal(err)
+			}
+
+			w.process(context.Background(), delivery(exec, 0))
+
+			consumer.assertCalls(t, test.wantCall)
+			got := stored(t, repo, exec.ID)
+			if test.wantRun {
+				if run.runs() != 1 || got.Status != domain.ExecutionStatusCompleted || got.WorkerID != "worker-1" {
+					t.Errorf("after %d runs the execution is %s on %q, want completed on worker-1", run.runs(), got.Status, got.WorkerID)
+				}
+			} else if run.runs() != 0 || got.Status != domain.ExecutionStatusRunning || got.WorkerID != test.workerID {
+				t.Errorf("after %d runs the execution is %s on %q, want it left running on %s", run.runs(), got.Status, got.WorkerID, test.workerID)
+			}
+		})
+	}
+}
+
+func TestProcessDuplicateWhileRunning(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	run := &fakeRunner{run: func(ctx context.Context) (*runner.Result, error) {
+		close(started)
+		<-release
+		return &runner.Result{Stdout: "out", FinishedAt: time.Now()}, nil
+	}}
+	w, repo, consumer := newTestWorker(t, run, nil)
+	exec := createQueued(t, repo, "exec-1")
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.process(context.Background(), delivery(exec, 0))
+	}()
+	<-started
+
+	// The same job delivered again, e.g. after its visibility timeout.
+	duplicate := delivery(exec, 0)
+	duplicate.Receipt = "duplicate"
+	w.process(context.Background(), duplicate)
+	consumer.assertCalls(t, "ack duplicate")
+
+	close(release)
+	<-done
+	consumer.assertCalls(t, "ack duplicate", "ack exec-1")
+	if run.runs() != 1 {
+		t.Errorf("runner ran %d times, want 1", run.runs())
+	}
+}
+
+func TestRunRequeuesAfterDrainTimeout(t *testing.T) {
+	started := make(chan struct{})
+	run := &fakeRunner{run: func(ctx context.Context) (*runner.Result, error) {
+		close(started)
+		<-ctx.Done()
+		return nil, context.Cause(ctx)
+	}}
+	w, repo, consumer := newTestWorker(t, run, nil)
+	w.drainTimeout = 10 * time.Millisecond
+	exec := createQueued(t, repo, "exec-1")
+	consumer.deliveries = []queue.Delivery{delivery(exec, 0)}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() { errs <- w.Run(ctx) }()
+
+	<-started
+	cancel()
+	if err := <-errs; !errors.Is(err, ErrDrainTimeout) {
+		t.Fatalf("Run = %v, want %v", err, ErrDrainTimeout)
+	}
+
+	consumer.assertCalls(t, "nack exec-1")
+	if got := stored(t, repo, exec.ID); got.Status != domain.ExecutionStatusQueued || got.WorkerID != "" {
+		t.Errorf("execution is %s on %q, want queued on no worker", got.Status, got.WorkerID)
+	}
+}
+
+func newTestWorker(t *testing.T, run *fakeRunner, registry *fakeRegistry) (*Worker, *memory.ExecutionRepository, *fakeConsumer) {
+	t.Helper()
+
+	repo := memory.NewExecutionRepository()
+	consumer := &fakeConsumer{}
+	deps := Deps{
+		Repo:           repo,
+		Consumer:       consumer,
+		Runner:         run,
+		Concurrency:    1,
+		RetryBaseDelay: time.Second,
+		ID:             "worker-1",
+	}
+	if registry != nil {
+		deps.Registry = registry
+	}
+
+	w, err := New(deps)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return w, repo, consumer
+}
+
+func createQueued(t *testing.T, repo *memory.ExecutionRepository, id string) *domain.Execution {
+	t.Helper()
+
+	exec, err := domain.NewExecution(id, "python", map[string]string{"main.py": "print(1)"}, "", "", 1000, "alice", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateExecution(context.Background(), exec); err != nil {
+		t.Fatal(err)
+	}
+
+	return exec
+}
+
+func stored(t *testing.T, repo *memory.ExecutionRepository, id string) *domain.Execution {
+	t.Helper()
+
+	exec, err := repo.GetExecutionByID(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return exec
+}
+
+func delivery(exec *domain.Execution, attempts int) queue.Delivery {
+	return queue.Delivery{
+		Job:     queue.Job{ExecutionID: exec.ID, Language: exec.Language, Attempts: attempts},
+		Receipt: exec.ID,
+	}
+}
+
+// fakeConsumer hands out deliveries and records how they are settled, by
+// receipt.
+type fakeConsumer struct {
+	deliveries []queue.Delivery
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (c *fakeConsumer) Consume(ctx context.Context) (<-chan queue.Delivery, error) {
+	out := make(chan queue.Delivery)
+	go func() {
+		defer close(out)
+		for _, d := range c.deliveries {
+			select {
+			case out <- d:
+			case <-ctx.Done():
+				return
+			}
+		}
+		<-ctx.Done()
+	}()
+
+	return out, nil
+}
+
+func (c *fakeConsumer) Ack(_ context.Context, d queue.Delivery) error {
+	c.record("ack " + d.Receipt)
+	return nil
+}
+
+func (c *fakeConsumer) Nack(_ context.Context, d queue.Delivery) error {
+	c.record("nack " + d.Receipt)
+	return nil
+}
+
+func (c *fakeConsumer) Retry(_ context.Context, d queue.Delivery, delay time.Duration) error {
+	c.record(fmt.Sprintf("retry %s %s", d.Receipt, delay))
+	return nil
+}
+
+func (c *fakeConsumer) DeadLetter(_ context.Context, d queue.Delivery, _ string) error {
+	c.record("dead-letter " + d.Receipt)
+	return nil
+}
+
+func (c *fakeConsumer) record(call string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.calls = append(c.calls, call)
+}
+
+func (c *fakeConsumer) assertCalls(t *testing.T, want ...string) {
+	t.Helper()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !slices.Equal(c.calls, want) {
+		t.Errorf("consumer calls = %q, want %q", c.calls, want)
+	}
+}
+
+type fakeRegistry struct {
+	workers []queue.WorkerInfo
+	err     error
+}
+
+func (r *fakeRegistry) Heartbeat(context.Context, queue.WorkerInfo) error { return nil }
+
+func (r *fakeRegistry) Deregister(context.Context, string) error { return nil }
+
+func (r *fakeRegistry) ListWorkers(context.Context) ([]queue.WorkerInfo, error) {
+	return r.workers, r.err
+}
+
+// fakeRunner runs every program with run, or prints "out" without it.
+type fakeRunner struct {
+	prepareErr error
+	run        func(ctx context.Context) (*runner.Result, error)
+
+	mu    sync.Mutex
+	count int
+}
+
+func (r *fakeRunner) Prepare(context.Context, runner.Request) (runner.Session, error) {
+	if r.prepareErr != nil {
+		return nil, r.prepareErr
+	}
+
+	return fakeSession{r}, nil
+}
+
+func (r *fakeRunner) runs() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.count
+}
+
+type fakeSession struct {
+	r *fakeRunner
+}
+
+func (s fakeSession) Compile(context.Context) (*runner.Result, error) {
+	return &runner.Result{FinishedAt: time.Now()}, nil
+}
+
+func (s fakeSession) Run(ctx context.Context, _ runner.RunInput) (*runner.Result, error) {
+	s.r.mu.Lock()
+	s.r.count++
+	s.r.mu.Unlock()
+
+	if s.r.run != nil {
+		return s.r.run(ctx)
+	}
+
+	return &runner.Result{Stdout: "out", FinishedAt: time.Now()}, nil
+}
+
+func (s fakeSession) Close() error { return nil }